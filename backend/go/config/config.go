@@ -1,20 +1,47 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
 
 // Config struct holds configuration settings for the application, such as the database URL and port.
 type Config struct {
-	DatabaseURL string // The URL to connect to the database.
-	Port        string // The port number the server will listen on.
-	MainFolder  string
+	DatabaseURL  string // The URL to connect to the database.
+	Port         string // The port number the server will listen on.
+	MainFolder   string
+	JobWorkers   int           // background jobs this instance runs at once
+	JobRetention time.Duration // how long finished jobs and their results are kept
 }
 
 // LoadConfig loads configuration values from environment variables and returns a Config struct.
 func LoadConfig() (*Config, error) {
+	jobWorkers := 2
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("JOB_WORKERS must be a positive number, got %q", v)
+		}
+		jobWorkers = n
+	}
+
+	jobRetention := 7 * 24 * time.Hour
+	if v := os.Getenv("JOB_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("JOB_RETENTION must be a positive duration such as 168h, got %q", v)
+		}
+		jobRetention = d
+	}
+
 	// Create and return a Config instance with values loaded from environment variables.
 	return &Config{
-		DatabaseURL: os.Getenv("DATABASE_URL"), // Load the DATABASE_URL environment variable.
-		Port:        os.Getenv("PORT"),         // Load the PORT environment variable.
-		MainFolder:  os.Getenv("MAIN_FOLDER"),
+		DatabaseURL:  os.Getenv("DATABASE_URL"), // Load the DATABASE_URL environment variable.
+		Port:         os.Getenv("PORT"),         // Load the PORT environment variable.
+		MainFolder:   os.Getenv("MAIN_FOLDER"),
+		JobWorkers:   jobWorkers,
+		JobRetention: jobRetention,
 	}, nil
 }
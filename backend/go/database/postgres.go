@@ -3,10 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"time"
 	"trackify-jobs/models"
 
-	_ "github.com/lib/pq"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // PostgresDB is a wrapper around the *sql.DB type that represents a PostgreSQL database connection.
@@ -162,7 +165,7 @@ func (db *PostgresDB) UpdateReminderStatus(id int, status string) error {
 }
 
 // GetRemindersByUserID retrieves all reminders for a specific user by their user ID.
-func (db *PostgresDB) GetRemindersByUserID(userID int) ([]models.Reminder, error) {
+func (db *PostgresDB) GetRemindersByUserID(userID string) ([]models.Reminder, error) {
 	// Query to fetch all reminders for a specific user.
 	query := `SELECT id, user_id, email, reminder_time, content, status
 			  FROM reminders
@@ -171,7 +174,7 @@ func (db *PostgresDB) GetRemindersByUserID(userID int) ([]models.Reminder, error
 	// Execute the query to retrieve all matching reminders.
 	rows, err := db.Query(query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching reminders for user %d: %w", userID, err)
+		return nil, fmt.Errorf("error fetching reminders for user %s: %w", userID, err)
 	}
 	defer rows.Close()
 
@@ -240,15 +243,126 @@ func (db *PostgresDB) UpdateReminder(reminder *models.Reminder) error {
 //  Resume Logic
 // ===================================
 
-func (db *PostgresDB) CreateResume(resume *models.Resume) (*models.Resume, error) {
-	query := `INSERT INTO resumes (user_id, filename) VALUES ($1, $2) RETURNING id, uploaded_at`
-	err := db.QueryRow(query, resume.UserID, resume.Filename).Scan(&resume.ID, &resume.UploadedAt)
-	return resume, err
+const resumeColumns = `r.id, r.public_id, r.user_id, r.document_id, d.public_id, r.version,
+	r.parent_id, p.public_id, r.label, r.filename, r.storage_key, r.content_type, r.size_bytes, r.content_sha256,
+	COALESCE(b.scan_status, 'pending'), r.uploaded_at, r.extraction_error`
+
+const resumeJoins = `
+	FROM resumes r
+	JOIN resume_documents d ON d.id = r.document_id
+	LEFT JOIN resumes p ON p.id = r.parent_id
+	LEFT JOIN resume_blobs b ON b.storage_key = r.storage_key`
+
+func scanResume(row interface{ Scan(...interface{}) error }) (*models.Resume, error) {
+	var r models.Resume
+	var parentID sql.NullInt64
+	var parentRef, sha sql.NullString
+	err := row.Scan(&r.ID, &r.PublicID, &r.UserID, &r.DocumentID, &r.DocumentRef, &r.Version,
+		&parentID, &parentRef, &r.Label, &r.Filename, &r.StorageKey, &r.ContentType, &r.SizeBytes, &sha,
+		&r.ScanStatus, &r.UploadedAt, &r.ExtractionError)
+	if err != nil {
+		return nil, err
+	}
+	r.SHA256 = sha.String
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		r.ParentID, r.ParentRef = &id, &parentRef.String
+	}
+	return &r, nil
+}
+
+// CreateResume stores a new version. With DocumentID unset a new document named
+// documentName is created; otherwise the version is numbered after the latest
+// one in that document and, unless ParentID is set, derives from it.
+func (db *PostgresDB) CreateResume(resume *models.Resume, documentName string) (*models.Resume, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if resume.DocumentID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO resume_documents (user_id, name) VALUES ($1, $2)
+			RETURNING id
+		`, resume.UserID, documentName).Scan(&resume.DocumentID)
+		if err != nil {
+			return nil, fmt.Errorf("error creating resume document: %w", err)
+		}
+	}
+
+	// Lock the document so concurrent uploads get distinct version numbers.
+	err = tx.QueryRow(`SELECT id FROM resume_documents WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		resume.DocumentID, resume.UserID).Scan(&resume.DocumentID)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking resume document: %w", err)
+	}
+
+	var latestID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT COALESCE(MAX(version), 0) + 1,
+		       (SELECT id FROM resumes WHERE document_id = $1 ORDER BY version DESC LIMIT 1)
+		FROM resumes WHERE document_id = $1
+	`, resume.DocumentID).Scan(&resume.Version, &latestID)
+	if err != nil {
+		return nil, fmt.Errorf("error numbering resume version: %w", err)
+	}
+	if resume.ParentID == nil && latestID.Valid {
+		id := int(latestID.Int64)
+		resume.ParentID = &id
+	}
+
+	// Take a reference on the stored file. If another upload of the same
+	// content won a race to store it first, point at that file instead; the
+	// caller sees the changed key and deletes its own copy.
+	var sha interface{}
+	if resume.SHA256 != "" {
+		sha = resume.SHA256
+	}
+	err = tx.QueryRow(`
+		INSERT INTO resume_blobs (storage_key, user_id, content_sha256, size_bytes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, content_sha256) DO UPDATE SET ref_count = resume_blobs.ref_count + 1
+		RETURNING storage_key
+	`, resume.StorageKey, resume.UserID, sha, resume.SizeBytes).Scan(&resume.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("error referencing resume file: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO resumes (user_id, document_id, version, parent_id, label, filename, storage_key,
+			content_type, size_bytes, content_sha256, extracted_text, extraction_error, extracted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+		RETURNING id
+	`, resume.UserID, resume.DocumentID, resume.Version, resume.ParentID, resume.Label, resume.Filename,
+		resume.StorageKey, resume.ContentType, resume.SizeBytes, sha, resume.ExtractedText, resume.ExtractionError,
+	).Scan(&resume.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating resume: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE resume_documents SET updated_at = NOW() WHERE id = $1`, resume.DocumentID); err != nil {
+		return nil, err
+	}
+
+	created, err := scanResume(tx.QueryRow(`SELECT `+resumeColumns+resumeJoins+` WHERE r.id = $1`, resume.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
 func (db *PostgresDB) GetResumesByUserID(userID string) ([]models.Resume, error) {
-	query := `SELECT id, user_id, filename, uploaded_at FROM resumes WHERE user_id=$1`
-	rows, err := db.Query(query, userID)
+	rows, err := db.Query(`SELECT `+resumeColumns+resumeJoins+`
+		WHERE r.user_id = $1
+		ORDER BY d.updated_at DESC, r.version DESC
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -256,23 +370,231 @@ func (db *PostgresDB) GetResumesByUserID(userID string) ([]models.Resume, error)
 
 	var resumes []models.Resume
 	for rows.Next() {
-		var r models.Resume
-		if err := rows.Scan(&r.ID, &r.UserID, &r.Filename, &r.UploadedAt); err != nil {
+		r, err := scanResume(rows)
+		if err != nil {
 			return nil, err
 		}
-		resumes = append(resumes, r)
+		resumes = append(resumes, *r)
 	}
 	return resumes, nil
 }
 
-func (db *PostgresDB) GetResumeByID(id int) (*models.Resume, error) {
-	query := `SELECT id, user_id, filename, uploaded_at FROM resumes WHERE id=$1`
-	var r models.Resume
-	err := db.QueryRow(query, id).Scan(&r.ID, &r.UserID, &r.Filename, &r.UploadedAt)
+// GetResumeByID looks up a resume by public ID, scoped to its owner. Malformed
+// IDs are reported as sql.ErrNoRows like any other miss.
+func (db *PostgresDB) GetResumeByID(publicID, userID string) (*models.Resume, error) {
+	if !isUUID(publicID) {
+		return nil, sql.ErrNoRows
+	}
+	return scanResume(db.QueryRow(`SELECT `+resumeColumns+resumeJoins+`
+		WHERE r.public_id = $1 AND r.user_id = $2
+	`, publicID, userID))
+}
+
+// FindResumeByHash returns the user's newest version with the given content
+// hash, preferring one in documentID.
+func (db *PostgresDB) FindResumeByHash(userID, sha256 string, documentID int) (*models.Resume, error) {
+	return scanResume(db.QueryRow(`SELECT `+resumeColumns+resumeJoins+`
+		WHERE r.user_id = $1 AND r.content_sha256 = $2
+		ORDER BY r.document_id = $3 DESC, r.id DESC
+		LIMIT 1
+	`, userID, sha256, documentID))
+}
+
+// GetResumeBlobKey returns the storage key of the user's file with the given
+// content hash, or "" when none is stored.
+func (db *PostgresDB) GetResumeBlobKey(userID, sha256 string) (string, error) {
+	var key string
+	err := db.QueryRow(`
+		SELECT storage_key FROM resume_blobs WHERE user_id = $1 AND content_sha256 = $2
+	`, userID, sha256).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error looking up resume file: %w", err)
+	}
+	return key, nil
+}
+
+// SetResumeBlobScan records the malware scan verdict for a stored file.
+func (db *PostgresDB) SetResumeBlobScan(storageKey, status, detail string) error {
+	_, err := db.Exec(`
+		UPDATE resume_blobs SET scan_status = $2, scan_detail = $3, scanned_at = NOW()
+		WHERE storage_key = $1
+	`, storageKey, status, detail)
+	if err != nil {
+		return fmt.Errorf("error recording scan result: %w", err)
+	}
+	return nil
+}
+
+// GetResumeByInternalID is GetResumeByID for foreign keys such as parent_id.
+func (db *PostgresDB) GetResumeByInternalID(id int, userID string) (*models.Resume, error) {
+	return scanResume(db.QueryRow(`SELECT `+resumeColumns+resumeJoins+`
+		WHERE r.id = $1 AND r.user_id = $2
+	`, id, userID))
+}
+
+// SetResumeLabel updates a version's label, returning sql.ErrNoRows if the
+// caller does not own it.
+func (db *PostgresDB) SetResumeLabel(publicID, userID, label string) error {
+	if !isUUID(publicID) {
+		return sql.ErrNoRows
+	}
+	res, err := db.Exec(`UPDATE resumes SET label = $1 WHERE public_id = $2 AND user_id = $3`, label, publicID, userID)
+	if err != nil {
+		return fmt.Errorf("error updating resume label: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetResumeText returns the stored text of a resume and whether extraction
+// has run for it at all.
+func (db *PostgresDB) GetResumeText(id int) (string, bool, error) {
+	var text string
+	var extractedAt sql.NullTime
+	err := db.QueryRow(`SELECT extracted_text, extracted_at FROM resumes WHERE id = $1`, id).Scan(&text, &extractedAt)
+	if err != nil {
+		return "", false, err
+	}
+	return text, extractedAt.Valid, nil
+}
+
+func (db *PostgresDB) SetResumeText(id int, text, extractionError string) error {
+	_, err := db.Exec(`
+		UPDATE resumes SET extracted_text = $1, extraction_error = $2, extracted_at = NOW()
+		WHERE id = $3
+	`, text, extractionError, id)
+	if err != nil {
+		return fmt.Errorf("error storing resume text: %w", err)
+	}
+	return nil
+}
+
+// GetResumeStructured returns the stored structured form of a resume, or
+// sql.ErrNoRows if it has not been parsed yet.
+func (db *PostgresDB) GetResumeStructured(resumeID int) (*models.ResumeStructured, error) {
+	var data, confidence []byte
+	var rs models.ResumeStructured
+	err := db.QueryRow(`
+		SELECT data, confidence, source, prompt_version, model, updated_at
+		FROM resume_structured WHERE resume_id = $1
+	`, resumeID).Scan(&data, &confidence, &rs.Source, &rs.PromptVersion, &rs.Model, &rs.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
-	return &r, nil
+	if err := json.Unmarshal(data, &rs.Data); err != nil {
+		return nil, fmt.Errorf("error decoding structured resume: %w", err)
+	}
+	if err := json.Unmarshal(confidence, &rs.Confidence); err != nil {
+		return nil, fmt.Errorf("error decoding structured resume confidence: %w", err)
+	}
+	return &rs, nil
+}
+
+// SaveResumeStructured replaces the structured form of a resume.
+func (db *PostgresDB) SaveResumeStructured(resumeID int, rs *models.ResumeStructured) error {
+	data, err := json.Marshal(rs.Data)
+	if err != nil {
+		return fmt.Errorf("error encoding structured resume: %w", err)
+	}
+	confidence, err := json.Marshal(rs.Confidence)
+	if err != nil {
+		return fmt.Errorf("error encoding structured resume confidence: %w", err)
+	}
+	err = db.QueryRow(`
+		INSERT INTO resume_structured (resume_id, data, confidence, source, prompt_version, model, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (resume_id) DO UPDATE
+		SET data = EXCLUDED.data, confidence = EXCLUDED.confidence, source = EXCLUDED.source,
+			prompt_version = EXCLUDED.prompt_version, model = EXCLUDED.model, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, resumeID, data, confidence, rs.Source, rs.PromptVersion, rs.Model).Scan(&rs.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error storing structured resume: %w", err)
+	}
+	return nil
+}
+
+const resumeDocumentColumns = `id, public_id, user_id, name, created_at, updated_at`
+
+func scanResumeDocument(row interface{ Scan(...interface{}) error }) (*models.ResumeDocument, error) {
+	var d models.ResumeDocument
+	if err := row.Scan(&d.ID, &d.PublicID, &d.UserID, &d.Name, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (db *PostgresDB) GetResumeDocument(publicID, userID string) (*models.ResumeDocument, error) {
+	if !isUUID(publicID) {
+		return nil, sql.ErrNoRows
+	}
+	doc, err := scanResumeDocument(db.QueryRow(`SELECT `+resumeDocumentColumns+`
+		FROM resume_documents WHERE public_id = $1 AND user_id = $2
+	`, publicID, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT `+resumeColumns+resumeJoins+`
+		WHERE r.document_id = $1
+		ORDER BY r.version DESC
+	`, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanResume(rows)
+		if err != nil {
+			return nil, err
+		}
+		doc.Versions = append(doc.Versions, *r)
+	}
+	return doc, rows.Err()
+}
+
+func (db *PostgresDB) GetResumeDocumentsByUserID(userID string) ([]models.ResumeDocument, error) {
+	rows, err := db.Query(`SELECT `+resumeDocumentColumns+`
+		FROM resume_documents WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []models.ResumeDocument
+	for rows.Next() {
+		d, err := scanResumeDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *d)
+	}
+	return docs, rows.Err()
+}
+
+func (db *PostgresDB) RenameResumeDocument(publicID, userID, name string) error {
+	if !isUUID(publicID) {
+		return sql.ErrNoRows
+	}
+	res, err := db.Exec(`
+		UPDATE resume_documents SET name = $1, updated_at = NOW()
+		WHERE public_id = $2 AND user_id = $3
+	`, name, publicID, userID)
+	if err != nil {
+		return fmt.Errorf("error renaming resume document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 func (db *PostgresDB) CreateNewStripeUser(userID, stripeCustomerID string) error {
@@ -374,6 +696,22 @@ func (db *PostgresDB) GetUserPlan(userID string) (string, error) {
 	return plan, nil
 }
 
+// GetStorageUsage counts the resume versions, the size of the distinct files
+// behind them and the cover letters a user has stored. Plan and limits are
+// left for the caller.
+func (db *PostgresDB) GetStorageUsage(userID string) (*models.Usage, error) {
+	var u models.Usage
+	err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM resumes WHERE user_id = $1),
+		       (SELECT COALESCE(SUM(size_bytes), 0) FROM resume_blobs WHERE user_id = $1),
+		       (SELECT COUNT(*) FROM cover_letters WHERE user_id = $1)
+	`, userID).Scan(&u.Resumes, &u.StorageBytes, &u.CoverLetters)
+	if err != nil {
+		return nil, fmt.Errorf("error loading storage usage: %w", err)
+	}
+	return &u, nil
+}
+
 func (db *PostgresDB) CanUserPerformEvent(userID, eventType string, maxCount int, interval string) (bool, error) {
 	var count int
 	query := `
@@ -389,32 +727,173 @@ func (db *PostgresDB) CanUserPerformEvent(userID, eventType string, maxCount int
 	return count < maxCount, nil
 }
 
-func (db *PostgresDB) DeleteResumeByID(id int, userID string) error {
-	_, err := db.Exec(`
+// DeleteResumeByID removes the version and drops its reference on the stored
+// file. It returns the storage key once no version references the file, or ""
+// when no row matched or the file is still in use. A document left without
+// versions is removed too.
+func (db *PostgresDB) DeleteResumeByID(publicID, userID string) (string, error) {
+	if !isUUID(publicID) {
+		return "", nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var key string
+	var documentID int
+	err = tx.QueryRow(`
 		DELETE FROM resumes
-		WHERE id = $1 AND user_id = $2
-	`, id, userID)
-	return err
+		WHERE public_id = $1 AND user_id = $2
+		RETURNING storage_key, document_id
+	`, publicID, userID).Scan(&key, &documentID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error deleting resume %s: %w", publicID, err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM resume_documents d
+		WHERE d.id = $1 AND NOT EXISTS (SELECT 1 FROM resumes WHERE document_id = d.id)
+	`, documentID)
+	if err != nil {
+		return "", fmt.Errorf("error deleting empty resume document: %w", err)
+	}
+
+	var refs int
+	err = tx.QueryRow(`
+		UPDATE resume_blobs SET ref_count = ref_count - 1 WHERE storage_key = $1
+		RETURNING ref_count
+	`, key).Scan(&refs)
+	if err == sql.ErrNoRows {
+		// Not tracked; leave the file rather than risk deleting a shared one.
+		return "", tx.Commit()
+	}
+	if err != nil {
+		return "", fmt.Errorf("error releasing resume file: %w", err)
+	}
+	if refs <= 0 {
+		if _, err := tx.Exec(`DELETE FROM resume_blobs WHERE storage_key = $1`, key); err != nil {
+			return "", fmt.Errorf("error deleting resume file record: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	if refs > 0 {
+		return "", nil
+	}
+	return key, nil
+}
+
+const coverLetterColumns = `c.id, c.public_id, c.user_id, c.title, c.job_id, COALESCE(j.title, ''),
+	COALESCE(j.company, ''), c.tone, c.source, c.prompt_version, c.model, c.content, c.version, c.created_at, c.updated_at
+	FROM cover_letters c
+	LEFT JOIN jobs j ON j.id = c.job_id`
+
+func scanCoverLetter(row interface{ Scan(...interface{}) error }) (*models.CoverLetter, error) {
+	var cl models.CoverLetter
+	var jobID sql.NullInt64
+	err := row.Scan(&cl.ID, &cl.PublicID, &cl.UserID, &cl.Title, &jobID, &cl.JobTitle, &cl.Company,
+		&cl.Tone, &cl.Source, &cl.PromptVersion, &cl.Model, &cl.Content, &cl.Version, &cl.CreatedAt, &cl.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if jobID.Valid {
+		id := int(jobID.Int64)
+		cl.JobID = &id
+	}
+	return &cl, nil
 }
 
+// CreateCoverLetter stores a letter and its first version.
 func (db *PostgresDB) CreateCoverLetter(cl *models.CoverLetter) (*models.CoverLetter, error) {
-	err := db.QueryRow(`
-		INSERT INTO cover_letters (user_id, content)
-		VALUES ($1, $2)
-		RETURNING id, user_id, content, created_at
-	`, cl.UserID, cl.Content).Scan(&cl.ID, &cl.UserID, &cl.Content, &cl.CreatedAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO cover_letters (user_id, title, job_id, tone, source, prompt_version, model, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, cl.UserID, cl.Title, cl.JobID, cl.Tone, cl.Source, cl.PromptVersion, cl.Model, cl.Content).Scan(&cl.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cover letter: %w", err)
+	}
+	if err := insertCoverLetterVersion(tx, cl.ID); err != nil {
+		return nil, err
+	}
+
+	created, err := scanCoverLetter(tx.QueryRow(`SELECT `+coverLetterColumns+` WHERE c.id = $1`, cl.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateCoverLetter saves cl's fields as the letter's next version. The row is
+// locked so concurrent edits get distinct version numbers.
+func (db *PostgresDB) UpdateCoverLetter(cl *models.CoverLetter) (*models.CoverLetter, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT id FROM cover_letters WHERE id = $1 AND user_id = $2 FOR UPDATE`, cl.ID, cl.UserID)
 	if err != nil {
+		return nil, fmt.Errorf("error locking cover letter: %w", err)
+	}
+	res, err := tx.Exec(`
+		UPDATE cover_letters
+		SET title = $3, job_id = $4, tone = $5, source = $6, prompt_version = $7, model = $8,
+		    content = $9, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+	`, cl.ID, cl.UserID, cl.Title, cl.JobID, cl.Tone, cl.Source, cl.PromptVersion, cl.Model, cl.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error updating cover letter: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := insertCoverLetterVersion(tx, cl.ID); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanCoverLetter(tx.QueryRow(`SELECT `+coverLetterColumns+` WHERE c.id = $1`, cl.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return cl, nil
+	return updated, nil
+}
+
+// insertCoverLetterVersion snapshots the letter's current state.
+func insertCoverLetterVersion(tx *sql.Tx, coverLetterID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO cover_letter_versions (cover_letter_id, version, title, content, tone, job_id, source, prompt_version, model)
+		SELECT id, version, title, content, tone, job_id, source, prompt_version, model FROM cover_letters WHERE id = $1
+	`, coverLetterID)
+	if err != nil {
+		return fmt.Errorf("error saving cover letter version: %w", err)
+	}
+	return nil
 }
 
 func (db *PostgresDB) GetCoverLettersByUserID(userID string) ([]models.CoverLetter, error) {
-	rows, err := db.Query(`
-		SELECT id, user_id, content, created_at
-		FROM cover_letters
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+	rows, err := db.Query(`SELECT `+coverLetterColumns+`
+		WHERE c.user_id = $1
+		ORDER BY c.updated_at DESC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -423,63 +902,203 @@ func (db *PostgresDB) GetCoverLettersByUserID(userID string) ([]models.CoverLett
 
 	var letters []models.CoverLetter
 	for rows.Next() {
-		var cl models.CoverLetter
-		if err := rows.Scan(&cl.ID, &cl.UserID, &cl.Content, &cl.CreatedAt); err != nil {
+		cl, err := scanCoverLetter(rows)
+		if err != nil {
 			return nil, err
 		}
-		letters = append(letters, cl)
+		letters = append(letters, *cl)
 	}
 	return letters, nil
 }
 
-func (db *PostgresDB) GetCoverLetterByIDAndUser(id int, userID string) (*models.CoverLetter, error) {
-	var cl models.CoverLetter
-	err := db.QueryRow(`
-		SELECT id, user_id, content, created_at
-		FROM cover_letters
-		WHERE id = $1 AND user_id = $2
-	`, id, userID).Scan(&cl.ID, &cl.UserID, &cl.Content, &cl.CreatedAt)
+func (db *PostgresDB) GetCoverLetterByIDAndUser(publicID, userID string) (*models.CoverLetter, error) {
+	if !isUUID(publicID) {
+		return nil, sql.ErrNoRows
+	}
+	return scanCoverLetter(db.QueryRow(`SELECT `+coverLetterColumns+`
+		WHERE c.public_id = $1 AND c.user_id = $2
+	`, publicID, userID))
+}
+
+// GetCoverLetterVersions lists a letter's versions, newest first.
+func (db *PostgresDB) GetCoverLetterVersions(coverLetterID int) ([]models.CoverLetterVersion, error) {
+	rows, err := db.Query(`
+		SELECT version, title, job_id, tone, source, prompt_version, model, content, created_at
+		FROM cover_letter_versions
+		WHERE cover_letter_id = $1
+		ORDER BY version DESC
+	`, coverLetterID)
 	if err != nil {
+		return nil, fmt.Errorf("error loading cover letter versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []models.CoverLetterVersion
+	for rows.Next() {
+		v, err := scanCoverLetterVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+	return versions, rows.Err()
+}
+
+func (db *PostgresDB) GetCoverLetterVersion(coverLetterID, version int) (*models.CoverLetterVersion, error) {
+	return scanCoverLetterVersion(db.QueryRow(`
+		SELECT version, title, job_id, tone, source, prompt_version, model, content, created_at
+		FROM cover_letter_versions
+		WHERE cover_letter_id = $1 AND version = $2
+	`, coverLetterID, version))
+}
+
+func scanCoverLetterVersion(row interface{ Scan(...interface{}) error }) (*models.CoverLetterVersion, error) {
+	var v models.CoverLetterVersion
+	var jobID sql.NullInt64
+	if err := row.Scan(&v.Version, &v.Title, &jobID, &v.Tone, &v.Source, &v.PromptVersion, &v.Model, &v.Content, &v.CreatedAt); err != nil {
 		return nil, err
 	}
-	return &cl, nil
+	if jobID.Valid {
+		id := int(jobID.Int64)
+		v.JobID = &id
+	}
+	return &v, nil
 }
 
-func (db *PostgresDB) DeleteCoverLetterByID(id int, userID string) error {
+func (db *PostgresDB) DeleteCoverLetterByID(publicID, userID string) error {
+	if !isUUID(publicID) {
+		return nil
+	}
 	_, err := db.Exec(`
 		DELETE FROM cover_letters
-		WHERE id = $1 AND user_id = $2
-	`, id, userID)
+		WHERE public_id = $1 AND user_id = $2
+	`, publicID, userID)
 	return err
 }
 
-func (db *PostgresDB) CreateJob(job *models.Job) (*models.Job, error) {
-	query := `
-		INSERT INTO jobs (user_id, title, company, location, status, notes, url)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, updated_at;
-	`
-	err := db.QueryRow(
-		query,
-		job.UserID, job.Title, job.Company, job.Location, job.Status, job.Notes, job.URL,
-	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+const coverLetterTemplateColumns = `id, public_id, user_id, name, body, tone, defaults, created_at, updated_at`
+
+func scanCoverLetterTemplate(row interface{ Scan(...interface{}) error }) (*models.CoverLetterTemplate, error) {
+	var t models.CoverLetterTemplate
+	var defaults []byte
+	err := row.Scan(&t.ID, &t.PublicID, &t.UserID, &t.Name, &t.Body, &t.Tone, &defaults, &t.CreatedAt, &t.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
-	return job, nil
+	if err := json.Unmarshal(defaults, &t.Defaults); err != nil {
+		return nil, fmt.Errorf("error decoding template defaults: %w", err)
+	}
+	return &t, nil
 }
 
-func (db *PostgresDB) GetJobsByUserID(userID string) ([]models.Job, error) {
-	rows, err := db.Query(`
-		SELECT id, user_id, title, company, location, status, notes, url, created_at, updated_at
-		FROM jobs
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`, userID)
+func (db *PostgresDB) CreateCoverLetterTemplate(t *models.CoverLetterTemplate) (*models.CoverLetterTemplate, error) {
+	defaults, err := json.Marshal(t.Defaults)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error encoding template defaults: %w", err)
 	}
-	defer rows.Close()
+	created, err := scanCoverLetterTemplate(db.QueryRow(`
+		INSERT INTO cover_letter_templates (user_id, name, body, tone, defaults)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+coverLetterTemplateColumns,
+		t.UserID, t.Name, t.Body, t.Tone, defaults))
+	if err != nil {
+		return nil, fmt.Errorf("error creating cover letter template: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateCoverLetterTemplate replaces a template's fields.
+func (db *PostgresDB) UpdateCoverLetterTemplate(t *models.CoverLetterTemplate) (*models.CoverLetterTemplate, error) {
+	if !isUUID(t.PublicID) {
+		return nil, sql.ErrNoRows
+	}
+	defaults, err := json.Marshal(t.Defaults)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding template defaults: %w", err)
+	}
+	return scanCoverLetterTemplate(db.QueryRow(`
+		UPDATE cover_letter_templates
+		SET name = $3, body = $4, tone = $5, defaults = $6, updated_at = NOW()
+		WHERE public_id = $1 AND user_id = $2
+		RETURNING `+coverLetterTemplateColumns,
+		t.PublicID, t.UserID, t.Name, t.Body, t.Tone, defaults))
+}
+
+func (db *PostgresDB) GetCoverLetterTemplates(userID string) ([]models.CoverLetterTemplate, error) {
+	rows, err := db.Query(`
+		SELECT `+coverLetterTemplateColumns+`
+		FROM cover_letter_templates
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cover letter templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.CoverLetterTemplate
+	for rows.Next() {
+		t, err := scanCoverLetterTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+func (db *PostgresDB) GetCoverLetterTemplate(publicID, userID string) (*models.CoverLetterTemplate, error) {
+	if !isUUID(publicID) {
+		return nil, sql.ErrNoRows
+	}
+	return scanCoverLetterTemplate(db.QueryRow(`
+		SELECT `+coverLetterTemplateColumns+`
+		FROM cover_letter_templates
+		WHERE public_id = $1 AND user_id = $2
+	`, publicID, userID))
+}
+
+func (db *PostgresDB) DeleteCoverLetterTemplate(publicID, userID string) error {
+	if !isUUID(publicID) {
+		return nil
+	}
+	_, err := db.Exec(`DELETE FROM cover_letter_templates WHERE public_id = $1 AND user_id = $2`, publicID, userID)
+	return err
+}
+
+// isUUID guards UUID columns so malformed IDs miss instead of erroring.
+func isUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+func (db *PostgresDB) CreateJob(job *models.Job) (*models.Job, error) {
+	query := `
+		INSERT INTO jobs (user_id, title, company, location, status, notes, url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at;
+	`
+	err := db.QueryRow(
+		query,
+		job.UserID, job.Title, job.Company, job.Location, job.Status, job.Notes, job.URL,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (db *PostgresDB) GetJobsByUserID(userID string) ([]models.Job, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, title, company, location, status, notes, url, created_at, updated_at
+		FROM jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	var jobs []models.Job
 	for rows.Next() {
@@ -495,6 +1114,34 @@ func (db *PostgresDB) GetJobsByUserID(userID string) ([]models.Job, error) {
 	return jobs, nil
 }
 
+// GetJobByID returns one of the user's jobs, or sql.ErrNoRows.
+func (db *PostgresDB) GetJobByID(id int, userID string) (*models.Job, error) {
+	var j models.Job
+	err := db.QueryRow(`
+		SELECT id, user_id, title, company, COALESCE(location, ''), COALESCE(status, ''),
+		       COALESCE(notes, ''), COALESCE(url, ''), created_at, updated_at
+		FROM jobs
+		WHERE id = $1 AND user_id = $2
+	`, id, userID).Scan(
+		&j.ID, &j.UserID, &j.Title, &j.Company, &j.Location,
+		&j.Status, &j.Notes, &j.URL, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// JobExists reports whether the job belongs to the user.
+func (db *PostgresDB) JobExists(id int, userID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1 AND user_id = $2)`, id, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking job: %w", err)
+	}
+	return exists, nil
+}
+
 func (db *PostgresDB) UpdateJob(id int, userID string, job *models.Job) (*models.Job, error) {
 	query := `
 		UPDATE jobs
@@ -530,3 +1177,776 @@ func (db *PostgresDB) DeleteJob(id int, userID string) error {
 	`, id, userID)
 	return err
 }
+
+// ===================================
+//  Email Preferences & Suppression Logic
+// ===================================
+
+// GetEmailPreferences returns the stored notification preferences for a user.
+// Categories without a row are enabled by default.
+func (db *PostgresDB) GetEmailPreferences(userID string) ([]models.EmailPreference, error) {
+	rows, err := db.Query(`
+		SELECT user_id, category, enabled, updated_at
+		FROM email_preferences
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching email preferences: %w", err)
+	}
+	defer rows.Close()
+
+	var prefs []models.EmailPreference
+	for rows.Next() {
+		var p models.EmailPreference
+		if err := rows.Scan(&p.UserID, &p.Category, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning email preference: %w", err)
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// SetEmailPreference enables or disables a notification category for a user.
+func (db *PostgresDB) SetEmailPreference(userID, category string, enabled bool) error {
+	_, err := db.Exec(`
+		INSERT INTO email_preferences (user_id, category, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, category) DO UPDATE
+		SET enabled = EXCLUDED.enabled, updated_at = NOW()
+	`, userID, category, enabled)
+	if err != nil {
+		return fmt.Errorf("error setting email preference: %w", err)
+	}
+	return nil
+}
+
+// IsEmailCategoryEnabled reports whether a user still receives a category.
+func (db *PostgresDB) IsEmailCategoryEnabled(userID, category string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`
+		SELECT enabled FROM email_preferences
+		WHERE user_id = $1 AND category = $2
+	`, userID, category).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking email preference: %w", err)
+	}
+	return enabled, nil
+}
+
+// AddEmailSuppression records that an address must not receive a category.
+// The first reason recorded for an (email, category) pair is kept.
+func (db *PostgresDB) AddEmailSuppression(s *models.EmailSuppression) error {
+	var userID sql.NullString
+	if s.UserID != "" {
+		userID = sql.NullString{String: s.UserID, Valid: true}
+	}
+	_, err := db.Exec(`
+		INSERT INTO email_suppressions (email, category, reason, user_id)
+		VALUES (LOWER($1), $2, $3, $4)
+		ON CONFLICT (email, category) DO NOTHING
+	`, s.Email, s.Category, s.Reason, userID)
+	if err != nil {
+		return fmt.Errorf("error adding email suppression: %w", err)
+	}
+	return nil
+}
+
+// RemoveUnsubscribeSuppression lifts suppressions a user created by unsubscribing
+// from a category. Bounces and complaints are never lifted this way.
+func (db *PostgresDB) RemoveUnsubscribeSuppression(userID, category string) error {
+	_, err := db.Exec(`
+		DELETE FROM email_suppressions
+		WHERE user_id = $1 AND category = $2 AND reason = 'unsubscribe'
+	`, userID, category)
+	if err != nil {
+		return fmt.Errorf("error removing email suppression: %w", err)
+	}
+	return nil
+}
+
+// IsEmailSuppressed reports whether an address is suppressed for a category,
+// either directly or through an "all" suppression.
+func (db *PostgresDB) IsEmailSuppressed(email, category string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM email_suppressions
+			WHERE email = LOWER($1) AND category IN ($2, 'all')
+		)
+	`, email, category).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking email suppression: %w", err)
+	}
+	return exists, nil
+}
+
+// ===================================
+//  Inbound Email Logic
+// ===================================
+
+// GetInboundToken returns the forwarding token for a user, or "" if none exists yet.
+func (db *PostgresDB) GetInboundToken(userID string) (string, error) {
+	var token string
+	err := db.QueryRow(`SELECT token FROM inbound_addresses WHERE user_id = $1`, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return token, err
+}
+
+// CreateInboundToken stores a forwarding token for a user. If one was created
+// concurrently the existing token is returned instead.
+func (db *PostgresDB) CreateInboundToken(userID, token string) (string, error) {
+	err := db.QueryRow(`
+		INSERT INTO inbound_addresses (user_id, token)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING token
+	`, userID, token).Scan(&token)
+	return token, err
+}
+
+// GetUserIDByInboundToken resolves a forwarding token to its owner.
+func (db *PostgresDB) GetUserIDByInboundToken(token string) (string, error) {
+	var userID string
+	err := db.QueryRow(`SELECT user_id FROM inbound_addresses WHERE token = $1`, token).Scan(&userID)
+	return userID, err
+}
+
+// CreateInboundMessage stores a parsed message. It returns (nil, nil) if the
+// message was already received, so redelivered webhooks are harmless.
+func (db *PostgresDB) CreateInboundMessage(m *models.InboundMessage) (*models.InboundMessage, error) {
+	err := db.QueryRow(`
+		INSERT INTO inbound_messages
+			(user_id, job_id, message_id, from_address, from_name, subject, body, classification, confidence, received_at)
+		VALUES ($1, $2, $3, LOWER($4), $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id, message_id) DO NOTHING
+		RETURNING id
+	`, m.UserID, m.JobID, m.MessageID, m.FromAddress, m.FromName, m.Subject, m.Body,
+		m.Classification, m.Confidence, m.ReceivedAt).Scan(&m.ID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating inbound message: %w", err)
+	}
+	return m, nil
+}
+
+// InboundMessageExists reports whether a message was already stored for the user.
+func (db *PostgresDB) InboundMessageExists(userID, messageID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM inbound_messages WHERE user_id = $1 AND message_id = $2)
+	`, userID, messageID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking inbound message: %w", err)
+	}
+	return exists, nil
+}
+
+// GetJobIDsByContact returns the jobs earlier messages from an address were matched to,
+// most frequent first.
+func (db *PostgresDB) GetJobIDsByContact(userID, fromAddress string) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT job_id FROM inbound_messages
+		WHERE user_id = $1 AND from_address = LOWER($2) AND job_id IS NOT NULL
+		GROUP BY job_id
+		ORDER BY COUNT(*) DESC
+	`, userID, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (db *PostgresDB) CreateJobStatusProposal(p *models.JobStatusProposal) (*models.JobStatusProposal, error) {
+	err := db.QueryRow(`
+		INSERT INTO job_status_proposals
+			(user_id, job_id, inbound_message_id, current_status, proposed_status, classification, confidence)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`, p.UserID, p.JobID, p.InboundMessageID, p.CurrentStatus, p.ProposedStatus,
+		p.Classification, p.Confidence).Scan(&p.ID, &p.Status, &p.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating job status proposal: %w", err)
+	}
+	return p, nil
+}
+
+// GetJobStatusProposals lists a user's proposals, optionally filtered by status.
+func (db *PostgresDB) GetJobStatusProposals(userID, status string) ([]models.JobStatusProposal, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.user_id, p.job_id, p.inbound_message_id, COALESCE(p.current_status, ''),
+		       p.proposed_status, p.classification, p.confidence, p.status, p.created_at, p.resolved_at,
+		       COALESCE(m.subject, ''), j.company, j.title
+		FROM job_status_proposals p
+		JOIN jobs j ON j.id = p.job_id
+		LEFT JOIN inbound_messages m ON m.id = p.inbound_message_id
+		WHERE p.user_id = $1 AND ($2 = '' OR p.status = $2)
+		ORDER BY p.created_at DESC
+	`, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proposals []models.JobStatusProposal
+	for rows.Next() {
+		var p models.JobStatusProposal
+		if err := rows.Scan(
+			&p.ID, &p.UserID, &p.JobID, &p.InboundMessageID, &p.CurrentStatus,
+			&p.ProposedStatus, &p.Classification, &p.Confidence, &p.Status, &p.CreatedAt, &p.ResolvedAt,
+			&p.Subject, &p.Company, &p.Title,
+		); err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, p)
+	}
+	return proposals, rows.Err()
+}
+
+// ResolveJobStatusProposal marks a pending proposal accepted or rejected. When
+// accepted, the job's status is updated in the same transaction.
+func (db *PostgresDB) ResolveJobStatusProposal(id int, userID string, accept bool) (*models.JobStatusProposal, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	resolution := "rejected"
+	if accept {
+		resolution = "accepted"
+	}
+
+	var p models.JobStatusProposal
+	err = tx.QueryRow(`
+		UPDATE job_status_proposals
+		SET status = $1, resolved_at = NOW()
+		WHERE id = $2 AND user_id = $3 AND status = 'pending'
+		RETURNING id, user_id, job_id, inbound_message_id, COALESCE(current_status, ''),
+		          proposed_status, classification, confidence, status, created_at, resolved_at
+	`, resolution, id, userID).Scan(
+		&p.ID, &p.UserID, &p.JobID, &p.InboundMessageID, &p.CurrentStatus,
+		&p.ProposedStatus, &p.Classification, &p.Confidence, &p.Status, &p.CreatedAt, &p.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept {
+		if _, err := tx.Exec(`
+			UPDATE jobs SET status = $1, updated_at = NOW()
+			WHERE id = $2 AND user_id = $3
+		`, p.ProposedStatus, p.JobID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ===================================
+//  Mailbox Import Logic
+// ===================================
+
+func (db *PostgresDB) CreateMailboxImport(imp *models.MailboxImport) (*models.MailboxImport, error) {
+	err := db.QueryRow(`
+		INSERT INTO mailbox_imports (user_id, filename)
+		VALUES ($1, $2)
+		RETURNING id, status, created_at
+	`, imp.UserID, imp.Filename).Scan(&imp.ID, &imp.Status, &imp.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mailbox import: %w", err)
+	}
+	return imp, nil
+}
+
+// UpdateMailboxImportProgress records status and counters while an import runs.
+func (db *PostgresDB) UpdateMailboxImportProgress(id, status string, total, processed int) error {
+	_, err := db.Exec(`
+		UPDATE mailbox_imports
+		SET status = $1, total_messages = $2, processed_messages = $3
+		WHERE id = $4
+	`, status, total, processed, id)
+	return err
+}
+
+// FinishMailboxImport marks an import completed, or failed when errMsg is set.
+func (db *PostgresDB) FinishMailboxImport(id string, processed int, errMsg string) error {
+	status := "completed"
+	if errMsg != "" {
+		status = "failed"
+	}
+	_, err := db.Exec(`
+		UPDATE mailbox_imports
+		SET status = $1, processed_messages = $2, error_message = NULLIF($3, ''), finished_at = NOW()
+		WHERE id = $4
+	`, status, processed, errMsg, id)
+	return err
+}
+
+const mailboxImportColumns = `id, user_id, filename, status, total_messages, processed_messages,
+	COALESCE(error_message, ''), created_at, finished_at`
+
+func scanMailboxImport(row interface{ Scan(...interface{}) error }) (*models.MailboxImport, error) {
+	var imp models.MailboxImport
+	err := row.Scan(&imp.ID, &imp.UserID, &imp.Filename, &imp.Status, &imp.TotalMessages,
+		&imp.ProcessedMessages, &imp.Error, &imp.CreatedAt, &imp.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// GetMailboxImport looks up an import scoped to its owner. Malformed IDs are
+// reported as sql.ErrNoRows like any other miss.
+func (db *PostgresDB) GetMailboxImport(id, userID string) (*models.MailboxImport, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+	return scanMailboxImport(db.QueryRow(`
+		SELECT `+mailboxImportColumns+`
+		FROM mailbox_imports
+		WHERE id = $1 AND user_id = $2
+	`, id, userID))
+}
+
+func (db *PostgresDB) GetMailboxImportsByUserID(userID string) ([]models.MailboxImport, error) {
+	rows, err := db.Query(`
+		SELECT `+mailboxImportColumns+`
+		FROM mailbox_imports
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var imports []models.MailboxImport
+	for rows.Next() {
+		imp, err := scanMailboxImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, *imp)
+	}
+	return imports, rows.Err()
+}
+
+// GetUnfinishedMailboxImports returns every user's imports that are still
+// queued or processing.
+func (db *PostgresDB) GetUnfinishedMailboxImports() ([]models.MailboxImport, error) {
+	rows, err := db.Query(`
+		SELECT ` + mailboxImportColumns + `
+		FROM mailbox_imports
+		WHERE status IN ('queued', 'processing')
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var imports []models.MailboxImport
+	for rows.Next() {
+		imp, err := scanMailboxImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, *imp)
+	}
+	return imports, rows.Err()
+}
+
+// UpsertJobDraft stores a draft, replacing an earlier version of the same thread
+// from the same import.
+func (db *PostgresDB) UpsertJobDraft(d *models.JobDraft) error {
+	_, err := db.Exec(`
+		INSERT INTO job_drafts
+			(import_id, user_id, company, title, job_status, applied_at, thread_id, thread_url, subject, from_address, message_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11)
+		ON CONFLICT (import_id, thread_id) DO UPDATE
+		SET company = EXCLUDED.company,
+			title = EXCLUDED.title,
+			job_status = EXCLUDED.job_status,
+			applied_at = EXCLUDED.applied_at,
+			thread_url = EXCLUDED.thread_url,
+			subject = EXCLUDED.subject,
+			from_address = EXCLUDED.from_address,
+			message_count = EXCLUDED.message_count
+	`, d.ImportID, d.UserID, d.Company, d.Title, d.JobStatus, d.AppliedAt, d.ThreadID,
+		d.ThreadURL, d.Subject, d.FromAddress, d.MessageCount)
+	if err != nil {
+		return fmt.Errorf("error saving job draft: %w", err)
+	}
+	return nil
+}
+
+const jobDraftColumns = `id, import_id, user_id, company, title, job_status, applied_at, thread_id,
+	COALESCE(thread_url, ''), COALESCE(subject, ''), COALESCE(from_address, ''), message_count, status, job_id, created_at`
+
+func scanJobDraft(row interface{ Scan(...interface{}) error }) (*models.JobDraft, error) {
+	var d models.JobDraft
+	err := row.Scan(&d.ID, &d.ImportID, &d.UserID, &d.Company, &d.Title, &d.JobStatus, &d.AppliedAt,
+		&d.ThreadID, &d.ThreadURL, &d.Subject, &d.FromAddress, &d.MessageCount, &d.Status, &d.JobID, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (db *PostgresDB) GetJobDraftsByImportID(importID, userID string) ([]models.JobDraft, error) {
+	if !isUUID(importID) {
+		return nil, sql.ErrNoRows
+	}
+	rows, err := db.Query(`
+		SELECT `+jobDraftColumns+`
+		FROM job_drafts
+		WHERE import_id = $1 AND user_id = $2
+		ORDER BY applied_at DESC NULLS LAST, id
+	`, importID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []models.JobDraft
+	for rows.Next() {
+		d, err := scanJobDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *d)
+	}
+	return drafts, rows.Err()
+}
+
+func (db *PostgresDB) GetPendingJobDraft(id int, importID, userID string) (*models.JobDraft, error) {
+	if !isUUID(importID) {
+		return nil, sql.ErrNoRows
+	}
+	return scanJobDraft(db.QueryRow(`
+		SELECT `+jobDraftColumns+`
+		FROM job_drafts
+		WHERE id = $1 AND import_id = $2 AND user_id = $3 AND status = 'pending'
+	`, id, importID, userID))
+}
+
+// AcceptJobDraft creates the job and links it to the draft in one transaction.
+func (db *PostgresDB) AcceptJobDraft(draftID int, job *models.Job) (*models.Job, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO jobs (user_id, title, company, location, status, notes, url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, job.UserID, job.Title, job.Company, job.Location, job.Status, job.Notes, job.URL,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE job_drafts SET status = 'accepted', job_id = $1
+		WHERE id = $2 AND user_id = $3 AND status = 'pending'
+	`, job.ID, draftID, job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (db *PostgresDB) DismissJobDraft(id int, importID, userID string) error {
+	if !isUUID(importID) {
+		return sql.ErrNoRows
+	}
+	res, err := db.Exec(`
+		UPDATE job_drafts SET status = 'dismissed'
+		WHERE id = $1 AND import_id = $2 AND user_id = $3 AND status = 'pending'
+	`, id, importID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// backgroundJobColumns leaves out input and result, which can run to
+// megabytes; the queries that need them select them after these.
+const backgroundJobColumns = `id, user_id, kind, status, stage, progress, error_message, attempts,
+	max_attempts, run_at, created_at, started_at, finished_at`
+
+func scanBackgroundJob(row interface{ Scan(...interface{}) error }, payload ...interface{}) (*models.BackgroundJob, error) {
+	var job models.BackgroundJob
+	var startedAt, finishedAt sql.NullTime
+	dest := []interface{}{&job.ID, &job.UserID, &job.Kind, &job.Status, &job.Stage, &job.Progress, &job.Error,
+		&job.Attempts, &job.MaxAttempts, &job.RunAt, &job.CreatedAt, &startedAt, &finishedAt}
+	if err := row.Scan(append(dest, payload...)...); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// EnqueueBackgroundJob stores a queued job, runnable right away.
+func (db *PostgresDB) EnqueueBackgroundJob(job *models.BackgroundJob) (*models.BackgroundJob, error) {
+	created, err := scanBackgroundJob(db.QueryRow(`
+		INSERT INTO background_jobs (user_id, kind, input, max_attempts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+backgroundJobColumns,
+		job.UserID, job.Kind, job.Input, job.MaxAttempts))
+	if err != nil {
+		return nil, fmt.Errorf("error enqueueing background job: %w", err)
+	}
+	return created, nil
+}
+
+// GetBackgroundJob returns one of the user's jobs with its (encrypted) result.
+func (db *PostgresDB) GetBackgroundJob(id, userID string) (*models.BackgroundJob, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+	var result []byte
+	job, err := scanBackgroundJob(db.QueryRow(`
+		SELECT `+backgroundJobColumns+`, result
+		FROM background_jobs
+		WHERE id = $1 AND user_id = $2
+	`, id, userID), &result)
+	if err != nil {
+		return nil, err
+	}
+	job.Result = result
+	return job, nil
+}
+
+// GetBackgroundJobsByUserID lists the user's jobs, newest first, without
+// their results.
+func (db *PostgresDB) GetBackgroundJobsByUserID(userID string) ([]models.BackgroundJob, error) {
+	rows, err := db.Query(`
+		SELECT `+backgroundJobColumns+`
+		FROM background_jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.BackgroundJob{}
+	for rows.Next() {
+		job, err := scanBackgroundJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// CancelBackgroundJob stops one of the user's queued or running jobs. A
+// worker running it finds out when its next heartbeat or update no longer
+// matches. It returns sql.ErrNoRows when there is no such unfinished job.
+func (db *PostgresDB) CancelBackgroundJob(id, userID string) (*models.BackgroundJob, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+	return scanBackgroundJob(db.QueryRow(`
+		UPDATE background_jobs
+		SET status = 'cancelled', stage = 'cancelled', error_message = '', finished_at = NOW(),
+		    locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND user_id = $2 AND status IN ('queued', 'running')
+		RETURNING `+backgroundJobColumns,
+		id, userID))
+}
+
+// DeleteBackgroundJob removes one of the user's finished jobs and its result.
+func (db *PostgresDB) DeleteBackgroundJob(id, userID string) error {
+	if !isUUID(id) {
+		return sql.ErrNoRows
+	}
+	res, err := db.Exec(`
+		DELETE FROM background_jobs
+		WHERE id = $1 AND user_id = $2 AND status IN ('completed', 'failed', 'cancelled')
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeBackgroundJobs deletes jobs that finished more than retention ago.
+func (db *PostgresDB) PurgeBackgroundJobs(retention time.Duration) (int64, error) {
+	res, err := db.Exec(`
+		DELETE FROM background_jobs
+		WHERE status IN ('completed', 'failed', 'cancelled')
+		  AND finished_at < NOW() - $1 * INTERVAL '1 millisecond'
+	`, retention.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("error purging background jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ClaimBackgroundJob leases the next runnable job of one of kinds to worker
+// and counts the attempt: a queued job that is due, or a running one whose
+// lease lapsed. It returns nil when there is nothing to do. SKIP LOCKED lets
+// any number of workers, on any number of instances, claim concurrently.
+func (db *PostgresDB) ClaimBackgroundJob(worker string, kinds []string, lease time.Duration) (*models.BackgroundJob, error) {
+	var input []byte
+	job, err := scanBackgroundJob(db.QueryRow(`
+		UPDATE background_jobs
+		SET status = 'running', stage = 'running', progress = 0, attempts = attempts + 1, locked_by = $1,
+		    locked_until = NOW() + $2 * INTERVAL '1 millisecond', started_at = COALESCE(started_at, NOW())
+		WHERE id = (
+			SELECT id FROM background_jobs
+			WHERE kind = ANY($3)
+			  AND ((status = 'queued' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+backgroundJobColumns+`, input`,
+		worker, lease.Milliseconds(), pq.Array(kinds)), &input)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming background job: %w", err)
+	}
+	job.Input = input
+	return job, nil
+}
+
+// ExtendBackgroundJobLease is the worker's heartbeat. It reports false when
+// the worker no longer holds the job, e.g. because its lease lapsed and
+// another worker claimed it.
+func (db *PostgresDB) ExtendBackgroundJobLease(id, worker string, lease time.Duration) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE background_jobs SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker, lease.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UpdateBackgroundJobProgress records the stage a running job has reached
+// and its percentage. It does nothing once the worker has lost the job; the
+// heartbeat is what notices that.
+func (db *PostgresDB) UpdateBackgroundJobProgress(id, worker, stage string, progress int) error {
+	_, err := db.Exec(`
+		UPDATE background_jobs SET stage = $3, progress = $4
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker, stage, progress)
+	if err != nil {
+		return fmt.Errorf("error updating background job progress: %w", err)
+	}
+	return nil
+}
+
+// CompleteBackgroundJob stores a job's result. Like the other updates that
+// end a run, it only applies while worker still holds the lease.
+func (db *PostgresDB) CompleteBackgroundJob(id, worker string, result []byte) error {
+	return db.finishBackgroundJob(`
+		UPDATE background_jobs
+		SET status = 'completed', stage = 'completed', progress = 100, result = $3, error_message = '',
+		    finished_at = NOW(),
+		    locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker, result)
+}
+
+// maxBackgroundJobErrorLength caps a stored job error, in characters. Errors
+// can carry a provider's whole response body or a long list of schema
+// problems.
+const maxBackgroundJobErrorLength = 2000
+
+// RetryBackgroundJob puts a job whose attempt failed back in the queue, due
+// after delay.
+func (db *PostgresDB) RetryBackgroundJob(id, worker, errMsg string, delay time.Duration) error {
+	return db.finishBackgroundJob(`
+		UPDATE background_jobs
+		SET status = 'queued', stage = 'queued', progress = 0, error_message = left($3, $5),
+		    run_at = NOW() + $4 * INTERVAL '1 millisecond',
+		    locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker, errMsg, delay.Milliseconds(), maxBackgroundJobErrorLength)
+}
+
+// FailBackgroundJob marks a job failed for good.
+func (db *PostgresDB) FailBackgroundJob(id, worker, errMsg string) error {
+	return db.finishBackgroundJob(`
+		UPDATE background_jobs
+		SET status = 'failed', stage = 'failed', error_message = left($3, $4), finished_at = NOW(),
+		    locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker, errMsg, maxBackgroundJobErrorLength)
+}
+
+// ReleaseBackgroundJob hands back a job the worker could not finish through
+// no fault of the job, such as a shutdown. The attempt is not counted and the
+// job is runnable again at once.
+func (db *PostgresDB) ReleaseBackgroundJob(id, worker string) error {
+	return db.finishBackgroundJob(`
+		UPDATE background_jobs
+		SET status = 'queued', stage = 'queued', progress = 0, attempts = GREATEST(attempts - 1, 0),
+		    run_at = NOW(),
+		    locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, worker)
+}
+
+// finishBackgroundJob runs one of the updates above and reports
+// sql.ErrNoRows when the worker had lost the job.
+func (db *PostgresDB) finishBackgroundJob(query string, args ...interface{}) error {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating background job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"trackify-jobs/models"
+	"trackify-jobs/render"
 	"trackify-jobs/services"
+	"unicode"
 
 	"github.com/gorilla/mux"
 )
 
+// maxJSONResumeBytes bounds an imported JSON Resume document.
+const maxJSONResumeBytes = 1 << 20
+
 type DocumentHandler struct {
 	DocumentService *services.DocumentService
 }
@@ -25,10 +34,21 @@ func NewDocumentHandler(s *services.DocumentService) *DocumentHandler {
 // Resume Handlers
 //
 
+// CreateResume stores an uploaded resume as a new version. A file identical to
+// one already stored returns that version with duplicate_of set, unless the
+// form sets new_version=true.
+// POST /api/resumes
 func (h *DocumentHandler) CreateResume(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
 
-	err := r.ParseMultipartForm(10 << 20) // 10MB
+	// Leave room for the multipart framing and form fields around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxUploadBytes()+64<<10)
+	err := r.ParseMultipartForm(10 << 20) // 10MB in memory, the rest spills to disk
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
@@ -41,26 +61,67 @@ func (h *DocumentHandler) CreateResume(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	savePath := filepath.Join(h.DocumentService.ResumeFolder, handler.Filename)
-	out, err := os.Create(savePath)
+	resume, err := h.DocumentService.CreateResume(r.Context(), uid, services.ResumeUpload{
+		Filename:   handler.Filename,
+		File:       file,
+		DocumentID: r.FormValue("document_id"),
+		ParentID:   r.FormValue("parent_id"),
+		Label:      r.FormValue("label"),
+		NewVersion: r.FormValue("new_version") == "true",
+	})
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume document or parent version not found", http.StatusNotFound)
+		return
+	}
+	if writeUploadError(w, err) {
+		return
+	}
 	if err != nil {
+		log.Printf("Failed to store resume: %v", err)
 		http.Error(w, "Failed to save file", http.StatusInternalServerError)
 		return
 	}
-	defer out.Close()
 
-	if _, err := io.Copy(out, file); err != nil {
-		http.Error(w, "Failed to write file", http.StatusInternalServerError)
-		return
+	json.NewEncoder(w).Encode(resume)
+}
+
+// writeUploadError maps rejected uploads to a response and reports whether
+// there was one: 413 for a file over the plan's size limit, 415 for a file
+// that is not a PDF, DOCX or text file, 422 for a PDF with active content and
+// 402 when the plan is full.
+func writeUploadError(w http.ResponseWriter, err error) bool {
+	var quota *services.QuotaError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrFileTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, services.ErrUnsupportedFormat):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, services.ErrActiveContent):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.As(err, &quota):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	default:
+		return false
 	}
+	return true
+}
+
+// GetUsage reports stored resumes, bytes and cover letters against the
+// limits of the user's plan.
+// GET /api/usage
+func (h *DocumentHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
 
-	resume, err := h.DocumentService.CreateResume(uid, handler.Filename)
+	usage, err := h.DocumentService.Usage(uid)
 	if err != nil {
-		http.Error(w, "DB error", http.StatusInternalServerError)
+		log.Printf("Failed to load usage: %v", err)
+		http.Error(w, "Failed to load usage", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(resume)
+	writeJSON(w, usage)
 }
 
 func (h *DocumentHandler) GetUserResumes(w http.ResponseWriter, r *http.Request) {
@@ -76,33 +137,599 @@ func (h *DocumentHandler) GetUserResumes(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *DocumentHandler) GetResumeByID(w http.ResponseWriter, r *http.Request) {
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idStr)
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		http.Error(w, "Resume not found", http.StatusNotFound)
 		return
 	}
 
-	resume, err := h.DocumentService.GetResumeByID(id)
+	h.serveResume(w, r, resume)
+}
+
+// GetResumeDownloadURL issues a short-lived link the frontend can use directly
+// in an <a href> or <iframe> without attaching a bearer token.
+// GET /api/resumes/{id}/download-url
+func (h *DocumentHandler) GetResumeDownloadURL(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
 	if err != nil {
 		http.Error(w, "Resume not found", http.StatusNotFound)
 		return
 	}
 
-	filePath := filepath.Join(h.DocumentService.ResumeFolder, resume.Filename)
-	http.ServeFile(w, r, filePath)
+	link, expires, err := h.DocumentService.ResumeDownloadURL(resume)
+	if writeScanError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to sign download URL: %v", err)
+		http.Error(w, "Failed to create download link", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"url": link, "expires_at": expires})
 }
 
-func (h *DocumentHandler) DeleteResume(w http.ResponseWriter, r *http.Request) {
+// DownloadFile serves a file named by a signed download token.
+// GET /api/files/{token}
+func (h *DocumentHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	resume, err := h.DocumentService.ResolveDownloadToken(mux.Vars(r)["token"])
+	if errors.Is(err, services.ErrExpiredToken) {
+		http.Error(w, "Download link has expired", http.StatusGone)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidToken) {
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	h.serveResume(w, r, resume)
+}
+
+func (h *DocumentHandler) serveResume(w http.ResponseWriter, r *http.Request, resume *models.Resume) {
+	file, err := h.DocumentService.OpenResumeForDownload(r.Context(), resume)
+	if writeScanError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open resume %s: %v", resume.PublicID, err)
+		http.Error(w, "Resume file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", resume.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": resume.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if resume.SizeBytes > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(resume.SizeBytes, 10))
+	}
+	io.Copy(w, file)
+}
+
+// writeScanError maps a file held back by malware scanning to a response and
+// reports whether there was one.
+func writeScanError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrScanPending):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrQuarantined):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		return false
+	}
+	return true
+}
+
+// GetResumeText returns the text extracted from the resume on upload.
+// GET /api/resumes/{id}/text
+func (h *DocumentHandler) GetResumeText(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
+	if err != nil {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+
+	text, err := h.DocumentService.ResumeText(r.Context(), resume)
+	if errors.Is(err, services.ErrNoResumeText) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load resume text: %v", err)
+		http.Error(w, "Failed to load resume text", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"id": resume.PublicID, "text": text})
+}
+
+// GetStructuredResume returns the parsed resume with per-field confidence.
+// GET /api/resumes/{id}/structured
+func (h *DocumentHandler) GetStructuredResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
+	if err != nil {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+
+	structured, err := h.DocumentService.StructuredResume(r.Context(), resume)
+	if errors.Is(err, services.ErrNoResumeText) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load structured resume: %v", err)
+		http.Error(w, "Failed to load structured resume", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, structured)
+}
+
+// UpdateStructuredResume replaces the structured resume with the user's edits.
+// PUT /api/resumes/{id}/structured
+func (h *DocumentHandler) UpdateStructuredResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var data models.StructuredResume
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	structured, err := h.DocumentService.UpdateStructuredResume(mux.Vars(r)["id"], uid, &data)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update structured resume: %v", err)
+		http.Error(w, "Failed to update structured resume", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, structured)
+}
+
+// RepairStructuredResume has the LLM fix fields the parser was unsure about.
+// POST /api/resumes/{id}/structured/repair
+func (h *DocumentHandler) RepairStructuredResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	structured, err := h.DocumentService.RepairStructuredResume(r.Context(), mux.Vars(r)["id"], uid)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrUserEdited):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, services.ErrNoResumeText):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("Failed to repair structured resume: %v", err)
+		http.Error(w, "Failed to repair structured resume", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, structured)
+}
+
+// ImportJSONResume creates a resume version from a jsonresume.org document,
+// sent either as the request body or as the "resume" file of a multipart form.
+// POST /api/resumes/import/jsonresume?document_id=&label=
+func (h *DocumentHandler) ImportJSONResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	// Bound the whole request, so a multipart form cannot spill an
+	// arbitrarily large upload to disk either.
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONResumeBytes)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxJSONResumeBytes); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("resume")
+		if err != nil {
+			http.Error(w, "Failed to get file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = io.LimitReader(file, maxJSONResumeBytes)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resume, err := h.DocumentService.ImportJSONResume(r.Context(), uid, data, r.FormValue("document_id"), r.FormValue("label"))
+	var invalid *services.JSONResumeError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err == sql.ErrNoRows:
+		http.Error(w, "Resume document not found", http.StatusNotFound)
+		return
+	case writeUploadError(w, err):
+		return
+	case err != nil:
+		log.Printf("Failed to import JSON Resume: %v", err)
+		http.Error(w, "Failed to import resume", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resume)
+}
+
+// ExportResume returns the structured resume in another format: jsonresume,
+// pdf, docx or latex (a zipped source bundle). Template options are the same
+// as for RenderResume.
+// GET /api/resumes/{id}/export?format=jsonresume
+func (h *DocumentHandler) ExportResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
+	if err != nil {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "jsonresume":
+		doc, err := h.DocumentService.ExportJSONResume(r.Context(), resume)
+		var invalid *services.JSONResumeError
+		switch {
+		case errors.Is(err, services.ErrNoResumeText), errors.As(err, &invalid):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			log.Printf("Failed to export resume: %v", err)
+			http.Error(w, "Failed to export resume", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": exportFilename(resume, ".json"),
+		}))
+		writeJSON(w, doc)
+	default:
+		opts, err := renderOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		export, err := h.DocumentService.ExportResume(r.Context(), resume, format, opts)
+		if writeExportError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", export.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": exportFilename(resume, export.Extension),
+		}))
+		w.Header().Set("Content-Length", strconv.Itoa(len(export.Data)))
+		w.Write(export.Data)
+	}
+}
+
+// SaveResumeExport renders a version as pdf, docx or latex and stores the
+// file as a new version derived from it.
+// POST /api/resumes/{id}/export {"format": "docx", "label": ""}
+func (h *DocumentHandler) SaveResumeExport(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload struct {
+		Format string `json:"format"`
+		Label  string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	opts, err := renderOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resume, err := h.DocumentService.SaveResumeExport(r.Context(), mux.Vars(r)["id"], uid, payload.Format, payload.Label, opts)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if writeUploadError(w, err) {
+		return
+	}
+	if writeExportError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resume)
+}
+
+// writeExportError maps export and render errors to a response and reports
+// whether there was one.
+func writeExportError(w http.ResponseWriter, err error) bool {
+	var unsupported *render.UnsupportedCharError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrUnsupportedExport),
+		errors.Is(err, render.ErrUnknownTemplate), errors.Is(err, render.ErrUnknownFont):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrNoResumeText), errors.As(err, &unsupported):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("Failed to export resume: %v", err)
+		http.Error(w, "Failed to export resume", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// LintResume checks the uploaded file for ATS compatibility problems and
+// returns each finding with a severity, location and suggested fix.
+// POST /api/resumes/{id}/lint
+func (h *DocumentHandler) LintResume(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idStr)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
 	if err != nil {
-		http.Error(w, "Invalid resume ID", http.StatusBadRequest)
+		http.Error(w, "Resume not found", http.StatusNotFound)
 		return
 	}
 
-	err = h.DocumentService.DeleteResumeByID(id, uid)
+	report, err := h.DocumentService.LintResume(r.Context(), resume)
+	if err != nil {
+		log.Printf("Failed to lint resume: %v", err)
+		http.Error(w, "Failed to lint resume", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+// RenderResume returns the structured resume as an ATS-safe PDF. Query
+// parameters override the template: font, font_size and margin (points),
+// page (letter or a4) and fit=false to allow more than one page.
+// GET /api/resumes/{id}/render?template=
+func (h *DocumentHandler) RenderResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	resume, err := h.DocumentService.GetResumeByID(mux.Vars(r)["id"], uid)
+	if err != nil {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+
+	opts, err := renderOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.DocumentService.RenderResume(r.Context(), resume, opts)
+	if writeExportError(w, err) {
+		return
+	}
+
+	writePDF(w, result, exportFilename(resume, ".pdf"))
+}
+
+// RenderStructuredResume renders a structured resume sent in the body, such
+// as the output of the rewrite job, without storing it.
+// POST /api/resumes/render
+func (h *DocumentHandler) RenderStructuredResume(w http.ResponseWriter, r *http.Request) {
+	var data models.StructuredResume
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONResumeBytes)).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := renderOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := render.RenderResume(&data, opts)
+	if writeExportError(w, err) {
+		return
+	}
+
+	writePDF(w, result, "resume.pdf")
+}
+
+// GetResumeTemplates lists the templates RenderResume accepts.
+// GET /api/resume-templates
+func (h *DocumentHandler) GetResumeTemplates(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"templates": render.Templates(),
+		"fonts":     render.FontFamilies(),
+	})
+}
+
+func renderOptions(r *http.Request) (render.Options, error) {
+	q := r.URL.Query()
+	opts := render.Options{
+		Template:   q.Get("template"),
+		Font:       q.Get("font"),
+		FitOnePage: q.Get("fit") != "false",
+	}
+	for name, dst := range map[string]*float64{"font_size": &opts.FontSize, "margin": &opts.Margin} {
+		if v := q.Get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				return opts, errors.New("invalid " + name)
+			}
+			*dst = f
+		}
+	}
+	switch q.Get("page") {
+	case "", "letter":
+		opts.PageSize = render.PageLetter
+	case "a4":
+		opts.PageSize = render.PageA4
+	default:
+		return opts, errors.New("page must be letter or a4")
+	}
+	return opts, nil
+}
+
+func writePDF(w http.ResponseWriter, result *render.Result, filename string) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(result.PDF)))
+	w.Header().Set("X-Render-Pages", strconv.Itoa(result.Pages))
+	w.Header().Set("X-Render-Font-Size", strconv.FormatFloat(result.FontSize, 'f', -1, 64))
+	w.Write(result.PDF)
+}
+
+// exportFilename names an export after the version's original file.
+func exportFilename(resume *models.Resume, ext string) string {
+	base := strings.TrimSuffix(resume.Filename, filepath.Ext(resume.Filename))
+	if base == "" {
+		base = "resume"
+	}
+	return base + ext
+}
+
+// SetResumeLabel names a version, e.g. "Backend v3 tailored for Stripe".
+// PUT /api/resumes/{id}/label
+func (h *DocumentHandler) SetResumeLabel(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload struct {
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.DocumentService.SetResumeLabel(mux.Vars(r)["id"], uid, payload.Label)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to update label", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DiffResume compares a version with ?against={id}, or with its parent when
+// against is omitted.
+// GET /api/resumes/{id}/diff?against={id}
+func (h *DocumentHandler) DiffResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	diff, err := h.DocumentService.DiffResumes(r.Context(), mux.Vars(r)["id"], r.URL.Query().Get("against"), uid)
+	if errors.Is(err, services.ErrNoDiffBase) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to diff resumes: %v", err)
+		http.Error(w, "Failed to diff resumes", http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, diff)
+}
+
+func (h *DocumentHandler) GetResumeDocuments(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	docs, err := h.DocumentService.GetResumeDocuments(uid)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch resume documents", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, docs)
+}
+
+func (h *DocumentHandler) GetResumeDocument(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	doc, err := h.DocumentService.GetResumeDocument(mux.Vars(r)["id"], uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch resume document", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, doc)
+}
+
+// RenameResumeDocument sets the display name shared by all versions.
+// PUT /api/resume-documents/{id}
+func (h *DocumentHandler) RenameResumeDocument(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Name == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.DocumentService.RenameResumeDocument(mux.Vars(r)["id"], uid, payload.Name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to rename resume document", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DocumentHandler) DeleteResume(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	err := h.DocumentService.DeleteResumeByID(r.Context(), mux.Vars(r)["id"], uid)
 	if err != nil {
 		http.Error(w, "Failed to delete resume", http.StatusInternalServerError)
 		return
@@ -115,11 +742,21 @@ func (h *DocumentHandler) DeleteResume(w http.ResponseWriter, r *http.Request) {
 // Cover Letter Handlers
 //
 
+// CreateCoverLetter saves a letter with its title, target job, tone and
+// source ("generated" or "handwritten"). Generated letters carry the
+// prompt_version and model from the generation response.
+// POST /api/cover-letters
 func (h *DocumentHandler) CreateCoverLetter(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
 
 	var payload struct {
-		Content string `json:"content"`
+		Title         string `json:"title"`
+		JobID         *int   `json:"job_id"`
+		Tone          string `json:"tone"`
+		Source        string `json:"source"`
+		PromptVersion string `json:"prompt_version"`
+		Model         string `json:"model"`
+		Content       string `json:"content"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -127,7 +764,22 @@ func (h *DocumentHandler) CreateCoverLetter(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	letter, err := h.DocumentService.CreateCoverLetter(uid, payload.Content)
+	letter, err := h.DocumentService.CreateCoverLetter(uid, models.CoverLetter{
+		Title:         payload.Title,
+		JobID:         payload.JobID,
+		Tone:          payload.Tone,
+		Source:        payload.Source,
+		PromptVersion: payload.PromptVersion,
+		Model:         payload.Model,
+		Content:       payload.Content,
+	})
+	if errors.Is(err, services.ErrInvalidCoverLetter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if writeUploadError(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to save cover letter", http.StatusInternalServerError)
 		return
@@ -136,6 +788,156 @@ func (h *DocumentHandler) CreateCoverLetter(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(letter)
 }
 
+// UpdateCoverLetter changes any of title, job_id (0 unlinks), tone, source,
+// prompt_version, model and content, saving the result as a new version. A
+// handwritten letter keeps no prompt version or model.
+// PATCH /api/cover-letters/{id}
+func (h *DocumentHandler) UpdateCoverLetter(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var patch models.CoverLetterPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	letter, err := h.DocumentService.UpdateCoverLetter(mux.Vars(r)["id"], uid, patch)
+	writeCoverLetter(w, letter, err)
+}
+
+// GetCoverLetterVersions lists a letter's saved versions, newest first.
+// GET /api/cover-letters/{id}/versions
+func (h *DocumentHandler) GetCoverLetterVersions(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	versions, err := h.DocumentService.GetCoverLetterVersions(mux.Vars(r)["id"], uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cover letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load cover letter versions: %v", err)
+		http.Error(w, "Failed to load cover letter versions", http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []models.CoverLetterVersion{}
+	}
+
+	writeJSON(w, versions)
+}
+
+// GetCoverLetterVersion returns one saved version of a letter.
+// GET /api/cover-letters/{id}/versions/{version}
+func (h *DocumentHandler) GetCoverLetterVersion(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	v, err := h.DocumentService.GetCoverLetterVersion(mux.Vars(r)["id"], uid, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cover letter version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load cover letter version: %v", err)
+		http.Error(w, "Failed to load cover letter version", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, v)
+}
+
+// RestoreCoverLetterVersion makes an old version current by saving a copy of
+// it as the newest version.
+// POST /api/cover-letters/{id}/versions/{version}/restore
+func (h *DocumentHandler) RestoreCoverLetterVersion(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	letter, err := h.DocumentService.RestoreCoverLetterVersion(mux.Vars(r)["id"], uid, version)
+	writeCoverLetter(w, letter, err)
+}
+
+// ExportCoverLetter renders a letter as pdf or docx under a letterhead built
+// from a resume's contact details (resume_id, default the newest), with the
+// linked job's company as the recipient. recipient adds a name above it;
+// template options are the same as for RenderResume.
+// GET /api/cover-letters/{id}/export?format=pdf
+func (h *DocumentHandler) ExportCoverLetter(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	opts, err := renderOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = services.ExportPDF
+	}
+
+	export, letter, err := h.DocumentService.ExportCoverLetter(r.Context(), mux.Vars(r)["id"], uid, services.CoverLetterExportOptions{
+		Format:    format,
+		ResumeID:  q.Get("resume_id"),
+		Recipient: q.Get("recipient"),
+		Render:    opts,
+	})
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cover letter or resume not found", http.StatusNotFound)
+		return
+	}
+	if writeExportError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": coverLetterFilename(letter, export.Extension),
+	}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Data)))
+	w.Write(export.Data)
+}
+
+// coverLetterFilename names an export after the letter's title.
+func coverLetterFilename(letter *models.CoverLetter, ext string) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(letter.Title))
+	if base == "" {
+		base = "cover-letter"
+	}
+	return base + ext
+}
+
+// writeCoverLetter writes an edited letter or the error that prevented the edit.
+func writeCoverLetter(w http.ResponseWriter, letter *models.CoverLetter, err error) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Cover letter not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidCoverLetter):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		log.Printf("Failed to update cover letter: %v", err)
+		http.Error(w, "Failed to update cover letter", http.StatusInternalServerError)
+	default:
+		writeJSON(w, letter)
+	}
+}
+
 func (h *DocumentHandler) GetUserCoverLetters(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
 
@@ -150,14 +952,8 @@ func (h *DocumentHandler) GetUserCoverLetters(w http.ResponseWriter, r *http.Req
 
 func (h *DocumentHandler) GetCoverLetterByID(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
-	}
 
-	letter, err := h.DocumentService.GetUserCoverLetterByID(id, uid)
+	letter, err := h.DocumentService.GetUserCoverLetterByID(mux.Vars(r)["id"], uid)
 	if err != nil {
 		http.Error(w, "Cover letter not found", http.StatusNotFound)
 		return
@@ -168,18 +964,195 @@ func (h *DocumentHandler) GetCoverLetterByID(w http.ResponseWriter, r *http.Requ
 
 func (h *DocumentHandler) DeleteCoverLetter(w http.ResponseWriter, r *http.Request) {
 	uid := r.Context().Value("uid").(string)
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idStr)
+
+	err := h.DocumentService.DeleteCoverLetterByID(mux.Vars(r)["id"], uid)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		http.Error(w, "Failed to delete cover letter", http.StatusInternalServerError)
 		return
 	}
 
-	err = h.DocumentService.DeleteCoverLetterByID(id, uid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//
+// Cover Letter Template Handlers
+//
+
+type coverLetterTemplatePayload struct {
+	Name     string            `json:"name"`
+	Body     string            `json:"body"`
+	Tone     string            `json:"tone"`
+	Defaults map[string]string `json:"defaults"`
+}
+
+func (p coverLetterTemplatePayload) template() models.CoverLetterTemplate {
+	return models.CoverLetterTemplate{Name: p.Name, Body: p.Body, Tone: p.Tone, Defaults: p.Defaults}
+}
+
+// CreateCoverLetterTemplate saves a reusable letter. Body may use
+// {{company}}, {{role}}, {{location}}, {{job_url}} and {{date}}, filled from
+// the job, plus {{hiring_manager}} and any custom fields, filled from the
+// request or the template's defaults.
+// POST /api/cover-letter-templates
+func (h *DocumentHandler) CreateCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload coverLetterTemplatePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.DocumentService.CreateCoverLetterTemplate(uid, payload.template())
+	writeCoverLetterTemplate(w, t, err)
+}
+
+// GetCoverLetterTemplates lists the user's templates, most recently edited first.
+// GET /api/cover-letter-templates
+func (h *DocumentHandler) GetCoverLetterTemplates(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	templates, err := h.DocumentService.GetCoverLetterTemplates(uid)
 	if err != nil {
-		http.Error(w, "Failed to delete cover letter", http.StatusInternalServerError)
+		log.Printf("Failed to load cover letter templates: %v", err)
+		http.Error(w, "Failed to load cover letter templates", http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []models.CoverLetterTemplate{}
+	}
+
+	writeJSON(w, templates)
+}
+
+// GetCoverLetterTemplate returns one template with its placeholder names.
+// GET /api/cover-letter-templates/{id}
+func (h *DocumentHandler) GetCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	t, err := h.DocumentService.GetCoverLetterTemplate(mux.Vars(r)["id"], uid)
+	writeCoverLetterTemplate(w, t, err)
+}
+
+// UpdateCoverLetterTemplate replaces a template's name, body, tone and defaults.
+// PUT /api/cover-letter-templates/{id}
+func (h *DocumentHandler) UpdateCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload coverLetterTemplatePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.DocumentService.UpdateCoverLetterTemplate(mux.Vars(r)["id"], uid, payload.template())
+	writeCoverLetterTemplate(w, t, err)
+}
+
+// DeleteCoverLetterTemplate removes a template. Letters created from it are kept.
+// DELETE /api/cover-letter-templates/{id}
+func (h *DocumentHandler) DeleteCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	if err := h.DocumentService.DeleteCoverLetterTemplate(mux.Vars(r)["id"], uid); err != nil {
+		http.Error(w, "Failed to delete cover letter template", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+type coverLetterTemplateFill struct {
+	JobID  int               `json:"job_id"`
+	Values map[string]string `json:"values"`
+	Title  string            `json:"title"`
+}
+
+// PreviewCoverLetterTemplate fills in a template for a job without saving
+// it. Unresolved placeholders stay in the content and are listed in missing.
+// POST /api/cover-letter-templates/{id}/preview
+func (h *DocumentHandler) PreviewCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload coverLetterTemplateFill
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	preview, _, _, err := h.DocumentService.PreviewCoverLetterTemplate(mux.Vars(r)["id"], uid, payload.JobID, payload.Values)
+	if writeTemplateError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to preview cover letter template: %v", err)
+		http.Error(w, "Failed to preview cover letter template", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, preview)
+}
+
+// RenderCoverLetterTemplate fills in a template for a job and saves the
+// result as a new cover letter linked to it. Every placeholder must resolve.
+// POST /api/cover-letter-templates/{id}/render
+func (h *DocumentHandler) RenderCoverLetterTemplate(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+
+	var payload coverLetterTemplateFill
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	letter, err := h.DocumentService.CreateCoverLetterFromTemplate(mux.Vars(r)["id"], uid, payload.JobID, payload.Values, payload.Title)
+	if writeTemplateError(w, err) || writeUploadError(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCoverLetter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create cover letter from template: %v", err)
+		http.Error(w, "Failed to save cover letter", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, letter)
+}
+
+// writeTemplateError maps template errors to responses. It reports whether
+// it wrote one; other errors are left to the caller.
+func writeTemplateError(w http.ResponseWriter, err error) bool {
+	var unresolved *services.UnresolvedPlaceholdersError
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Cover letter template not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidTemplate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &unresolved):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   unresolved.Error(),
+			"missing": unresolved.Missing,
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// writeCoverLetterTemplate writes a saved template or the error that prevented the save.
+func writeCoverLetterTemplate(w http.ResponseWriter, t *models.CoverLetterTemplate, err error) {
+	if writeTemplateError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save cover letter template: %v", err)
+		http.Error(w, "Failed to save cover letter template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, t)
+}
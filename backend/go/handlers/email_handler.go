@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"trackify-jobs/services"
+)
+
+type EmailHandler struct {
+	EmailService *services.EmailService
+}
+
+func NewEmailHandler(s *services.EmailService) *EmailHandler {
+	return &EmailHandler{EmailService: s}
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>You have been unsubscribed. You can turn emails back on from your account settings.</p>
+{{else}}<form method="POST" action="?token={{.Token}}">
+<p>Stop receiving these emails?</p>
+<button type="submit">Unsubscribe</button>
+</form>{{end}}
+</body></html>`))
+
+// UnsubscribePage renders a confirmation form. Mail scanners prefetch links, so a
+// GET never changes state; the form and RFC 8058 one-click clients both POST.
+func (h *EmailHandler) UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]interface{}{"Token": token, "Done": false})
+}
+
+// Unsubscribe handles one-click (List-Unsubscribe=One-Click) and form submissions.
+// POST /api/email/unsubscribe?token=...
+func (h *EmailHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.EmailService.UnsubscribeWithToken(token); err != nil {
+		if err == services.ErrInvalidToken {
+			http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Unsubscribe failed: %v", err)
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	// One-click clients only look at the status code; browsers get a page.
+	if r.FormValue("List-Unsubscribe") == "One-Click" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]interface{}{"Done": true})
+}
+
+func (h *EmailHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := h.EmailService.GetPreferences(uid)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch email preferences", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, prefs)
+}
+
+// UpdatePreferences accepts a {"category": enabled} object.
+func (h *EmailHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var updates map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.EmailService.UpdatePreferences(uid, updates); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.GetPreferences(w, r)
+}
+
+// resendWebhookEvent is the subset of Resend's webhook payload we act on.
+type resendWebhookEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		To     []string `json:"to"`
+		Bounce *struct {
+			Type string `json:"type"` // "Permanent" or "Transient"
+		} `json:"bounce"`
+	} `json:"data"`
+}
+
+// ProviderWebhook receives delivery events from Resend and feeds hard bounces and
+// spam complaints into the suppression list.
+func (h *EmailHandler) ProviderWebhook(w http.ResponseWriter, r *http.Request) {
+	const maxBodyBytes = int64(65536)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+
+	secret := os.Getenv("RESEND_WEBHOOK_SECRET")
+	if secret == "" {
+		http.Error(w, "Missing webhook secret", http.StatusInternalServerError)
+		return
+	}
+
+	if err := verifySvixSignature(secret, r.Header, payload); err != nil {
+		log.Printf("Rejected email webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusBadRequest)
+		return
+	}
+
+	var event resendWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		http.Error(w, "Invalid event payload", http.StatusBadRequest)
+		return
+	}
+
+	var reason string
+	switch event.Type {
+	case "email.bounced":
+		if event.Data.Bounce != nil && !strings.EqualFold(event.Data.Bounce.Type, "Permanent") {
+			break // transient bounces may succeed on a later send
+		}
+		reason = "bounce"
+	case "email.complained":
+		reason = "complaint"
+	default:
+		log.Printf("Unhandled email event type: %s", event.Type)
+	}
+
+	if reason != "" {
+		for _, to := range event.Data.To {
+			if err := h.EmailService.SuppressAddress(to, reason); err != nil {
+				log.Printf("Failed to suppress %s: %v", to, err)
+				http.Error(w, "Failed to record event", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifySvixSignature checks the Svix headers Resend signs webhooks with:
+// base64(HMAC-SHA256(secret, id + "." + timestamp + "." + body)).
+func verifySvixSignature(secret string, header http.Header, payload []byte) error {
+	id := header.Get("svix-id")
+	timestamp := header.Get("svix-timestamp")
+	signatures := header.Get("svix-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > 5*time.Minute || d < -5*time.Minute {
+		return fmt.Errorf("timestamp outside tolerance")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return fmt.Errorf("invalid webhook secret")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(payload)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// The header holds space-separated "v1,<sig>" entries during key rotation.
+	for _, sig := range strings.Fields(signatures) {
+		if version, value, ok := strings.Cut(sig, ","); ok && version == "v1" {
+			if hmac.Equal([]byte(value), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no matching signature")
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"trackify-jobs/services"
+
+	"github.com/gorilla/mux"
+)
+
+type InboundEmailHandler struct {
+	InboundEmailService *services.InboundEmailService
+}
+
+func NewInboundEmailHandler(s *services.InboundEmailService) *InboundEmailHandler {
+	return &InboundEmailHandler{InboundEmailService: s}
+}
+
+// ReceiveEmail accepts forwarded mail from the inbound provider, either as raw
+// RFC 822 (message/rfc822 or text/plain) or as webhook JSON. The provider must
+// send the shared secret in the X-Inbound-Secret header or the "secret" query parameter.
+// POST /api/inbound/email
+func (h *InboundEmailHandler) ReceiveEmail(w http.ResponseWriter, r *http.Request) {
+	secret := os.Getenv("INBOUND_EMAIL_SECRET")
+	if secret == "" {
+		http.Error(w, "Missing inbound secret", http.StatusInternalServerError)
+		return
+	}
+	provided := r.Header.Get("X-Inbound-Secret")
+	if provided == "" {
+		provided = r.URL.Query().Get("secret")
+	}
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var email *services.ParsedEmail
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		email, err = services.ParseInboundWebhook(payload)
+	} else {
+		email, err = services.ParseRawEmail(bytes.NewReader(payload))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.InboundEmailService.Process(r.Context(), email)
+	if errors.Is(err, services.ErrUnknownRecipient) {
+		// Accept so the provider does not retry mail for addresses we never issued.
+		log.Printf("Dropping inbound email for unknown recipient %v", email.To)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to process inbound email: %v", err)
+		http.Error(w, "Failed to process email", http.StatusInternalServerError)
+		return
+	}
+
+	if msg == nil {
+		w.WriteHeader(http.StatusOK) // duplicate delivery
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
+
+// GetForwardingAddress returns the caller's personal forwarding address.
+func (h *InboundEmailHandler) GetForwardingAddress(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	address, err := h.InboundEmailService.GetForwardingAddress(uid)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to get forwarding address", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"address": address})
+}
+
+// GetProposals lists status proposals; ?status=pending|accepted|rejected filters them.
+func (h *InboundEmailHandler) GetProposals(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	proposals, err := h.InboundEmailService.GetProposals(uid, r.URL.Query().Get("status"))
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch proposals", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, proposals)
+}
+
+func (h *InboundEmailHandler) AcceptProposal(w http.ResponseWriter, r *http.Request) {
+	h.resolveProposal(w, r, true)
+}
+
+func (h *InboundEmailHandler) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	h.resolveProposal(w, r, false)
+}
+
+func (h *InboundEmailHandler) resolveProposal(w http.ResponseWriter, r *http.Request, accept bool) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid proposal id", http.StatusBadRequest)
+		return
+	}
+
+	proposal, err := h.InboundEmailService.ResolveProposal(id, uid, accept)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Proposal not found or already resolved", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to resolve proposal", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, proposal)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	"trackify-jobs/models"
+	"trackify-jobs/services"
+
+	"github.com/gorilla/websocket"
+)
+
+// jobEventsKeepalive is how often a quiet job stream is pinged.
+const jobEventsKeepalive = 20 * time.Second
+
+// wsWriteTimeout bounds each WebSocket write, so that a client that stopped
+// reading does not hold the stream open.
+const wsWriteTimeout = 10 * time.Second
+
+var jobEventsUpgrader = websocket.Upgrader{
+	Subprotocols: []string{"bearer"},
+	// Any origin may connect. Requests are authorized by token, never by
+	// cookie, so a page on another site gains nothing it could not already
+	// do with fetch.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func jobEvent(job *models.BackgroundJob) models.JobEvent {
+	return models.JobEvent{
+		JobID:    job.ID,
+		Status:   job.Status,
+		Stage:    job.Stage,
+		Progress: job.Progress,
+		Error:    job.Error,
+	}
+}
+
+// followJob sends a job's progress until the job finishes, the client goes
+// away or the server shuts down:
+//
+//	progress {"job_id", "status", "stage", "progress", "error"}
+//	         on every change, starting with the job's current state
+//	result   {...}   once the job has completed: what QueryJob returns
+//
+// The stream ends after the job's last progress message, and its result.
+func (h *LLMHandler) followJob(ctx context.Context, job *models.BackgroundJob, sub *services.JobSubscription,
+	send func(event string, data interface{}) error, ping func() error) {
+	last := jobEvent(job)
+	if send("progress", last) != nil {
+		return
+	}
+	keepalive := time.NewTicker(jobEventsKeepalive)
+	defer keepalive.Stop()
+
+	for !last.Finished() {
+		var ev models.JobEvent
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			if ping() != nil {
+				return
+			}
+			continue
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			ev = e
+		case _, ok := <-sub.Resync:
+			if !ok {
+				return
+			}
+			fresh, err := h.Jobs.Get(job.ID, job.UserID)
+			if err != nil {
+				log.Printf("Failed to reload job %s: %v", job.ID, err)
+				return
+			}
+			job, ev = fresh, jobEvent(fresh)
+		}
+		if ev == last {
+			continue
+		}
+		last = ev
+		if send("progress", ev) != nil {
+			return
+		}
+	}
+
+	if last.Status != models.JobStatusCompleted {
+		return
+	}
+	if job.Status != models.JobStatusCompleted {
+		// Only the event said so; the result is in the database.
+		fresh, err := h.Jobs.Get(job.ID, job.UserID)
+		if err != nil {
+			log.Printf("Failed to load result of job %s: %v", job.ID, err)
+			return
+		}
+		job = fresh
+	}
+	send("result", newJobStatus(job))
+}
+
+// followJobWebSocket runs followJob over a WebSocket. Each message is a JSON
+// object {"event": "progress" | "result", "data": {...}}. The server closes
+// the connection when the stream ends; the client's messages are ignored.
+func (h *LLMHandler) followJobWebSocket(w http.ResponseWriter, r *http.Request, job *models.BackgroundJob, sub *services.JobSubscription) {
+	conn, err := jobEventsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		log.Printf("WebSocket upgrade for job %s failed: %v", job.ID, err)
+		return
+	}
+	defer conn.Close()
+
+	// A hijacked connection outlives its request's context, so watch the
+	// connection itself: reading is also what processes the client's pongs
+	// and close frame.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event string, data interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(map[string]interface{}{"event": event, "data": data})
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+	}
+	h.followJob(ctx, job, sub, send, ping)
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(wsWriteTimeout))
+}
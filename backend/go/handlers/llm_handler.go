@@ -2,304 +2,299 @@
 package handlers
 
 import (
-	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
-	"time"
-	"trackify-jobs/database"
+	"trackify-jobs/models"
 	"trackify-jobs/services"
 
-	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
+// LLM requests name one of the caller's stored resumes; its text is the one
+// extracted on upload, never text supplied by the client.
 type ResumeRewriteRequest struct {
-	ResumeText     string `json:"resume_text"`
+	ResumeID       string `json:"resume_id"`
 	JobDescription string `json:"job_description"`
 }
 
 type CoverLetterRequest struct {
-	ResumeText     string `json:"resume_text"`
+	ResumeID       string `json:"resume_id"`
 	JobDescription string `json:"job_description"`
 }
 
 type RecommendationsRequest struct {
-	ResumeText     string `json:"resume_text"`
+	ResumeID       string `json:"resume_id"`
 	JobDescription string `json:"job_description"`
 }
 
+// Generation in each response records the prompt version and model that
+// produced it; clients send it back when they save the output.
 type ResumeRewriteResponse struct {
-	Rewrites json.RawMessage `json:"rewrites"` // JSON object or array
+	Rewrites   json.RawMessage   `json:"rewrites"` // JSON object or array
+	Generation models.Generation `json:"generation"`
 }
 
 type CoverLetterResponse struct {
-	CoverLetter json.RawMessage `json:"cover_letter"` // JSON object
+	CoverLetter *models.GeneratedCoverLetter `json:"cover_letter"`
+	Generation  models.Generation            `json:"generation"`
 }
 
 type RecommendationsResponse struct {
-	Recommendations json.RawMessage `json:"recommendations"` // JSON object
+	Recommendations *models.Suggestions `json:"recommendations"`
+	Generation      models.Generation   `json:"generation"`
 }
 
 type LLMHandler struct {
-	LLMService *services.LLMService
-	NLPService *services.NLPService
-	DB         *database.PostgresDB
+	LLMService      *services.LLMService
+	DocumentService *services.DocumentService
+	Jobs            *services.JobQueue
+	Events          *services.JobEvents
 }
 
-func NewLLMHandler(s *services.LLMService, n *services.NLPService, d *database.PostgresDB) *LLMHandler {
-	return &LLMHandler{LLMService: s, NLPService: n, DB: d}
+func NewLLMHandler(s *services.LLMService, docs *services.DocumentService, jobs *services.JobQueue, events *services.JobEvents) *LLMHandler {
+	return &LLMHandler{LLMService: s, DocumentService: docs, Jobs: jobs, Events: events}
 }
 
-func (h *LLMHandler) ResumeRewriteHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// loadResume fetches the caller's resume and its extracted text. On failure it
+// writes the error response and returns ok=false.
+func (h *LLMHandler) loadResume(w http.ResponseWriter, r *http.Request, resumeID string) (*models.Resume, string, bool) {
+	uid, _ := r.Context().Value("uid").(string)
+	if resumeID == "" {
+		http.Error(w, "Missing resume_id", http.StatusBadRequest)
+		return nil, "", false
 	}
 
-	err := r.ParseMultipartForm(10 << 20)
+	resume, err := h.DocumentService.GetResumeByID(resumeID, uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return nil, "", false
+	}
 	if err != nil {
-		log.Printf("Failed to parse multipart form: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to parse multipart form: %v", err), http.StatusBadRequest)
-		return
+		log.Printf("Failed to load resume %s: %v", resumeID, err)
+		http.Error(w, "Failed to load resume", http.StatusInternalServerError)
+		return nil, "", false
 	}
 
-	file, header, err := r.FormFile("resume_file")
+	text, err := h.DocumentService.ResumeText(r.Context(), resume)
+	if errors.Is(err, services.ErrNoResumeText) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return nil, "", false
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Missing resume_file: %v", err), http.StatusBadRequest)
-		return
+		log.Printf("Failed to load text for resume %s: %v", resumeID, err)
+		http.Error(w, "Failed to load resume", http.StatusInternalServerError)
+		return nil, "", false
 	}
-	defer file.Close()
+	return resume, text, true
+}
 
-	resumeText := r.FormValue("resume_text")
-	jobDescription := r.FormValue("job_description")
-	if resumeText == "" || jobDescription == "" {
-		http.Error(w, "Missing resume_text or job_description", http.StatusBadRequest)
+// ResumeRewriteHandler accepts resume_id and job_description as JSON or form
+// values and queues a rewrite job. The resume file and text are stored with
+// the job before the 202 goes out, so the job runs even across a restart.
+// POST /api/llm/rewrite
+func (h *LLMHandler) ResumeRewriteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	jobID := uuid.NewString()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
-		"job_id": jobID,
-		"status": "processing",
-	})
-
-	go func() {
-		log.Println("Starting")
-
-		analysis, err := h.NLPService.Analyze(file, header, jobDescription)
-		if err != nil {
-			log.Printf("NLP analysis failed for job %s: %v", jobID, err)
-			h.saveJobError(jobID, err)
+	var req ResumeRewriteRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := parseJSON(r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		var parsedAnalysis map[string]interface{}
-		if err := json.Unmarshal([]byte(analysis), &parsedAnalysis); err != nil {
-			log.Printf("Failed to parse analysis JSON for job %s: %v", jobID, err)
-			h.saveJobError(jobID, err)
+	} else {
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
 			return
 		}
-
-		var lowSimilaritySkills []string
-		if matched, ok := parsedAnalysis["matched_skills"].([]interface{}); ok {
-			for _, item := range matched {
-				if skillMap, ok := item.(map[string]interface{}); ok {
-					if sim, okSim := skillMap["similarity"].(float64); okSim {
-						if skill, okSkill := skillMap["job_skill"].(string); okSkill && sim < 75 {
-							lowSimilaritySkills = append(lowSimilaritySkills, skill)
-						}
-					}
-				}
-			}
-		}
-
-		rewrites, err := h.LLMService.RewriteResume(resumeText, jobDescription, lowSimilaritySkills)
-		if err != nil {
-			log.Printf("Resume rewrite failed for job %s: %v", jobID, err)
-			h.saveJobError(jobID, err)
-			return
-		}
-
-		// ✅ Save results to DB so client can fetch later
-		err = h.saveJobResult(jobID, rewrites)
-		if err != nil {
-			log.Printf("Failed to save results for job %s: %v", jobID, err)
-		}
-
-		log.Println("Finished")
-	}()
-}
-
-func encryptAESGCM(key, plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+		req.ResumeID = r.FormValue("resume_id")
+		req.JobDescription = r.FormValue("job_description")
 	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
+	if req.JobDescription == "" {
+		http.Error(w, "Missing job_description", http.StatusBadRequest)
+		return
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
+	resume, resumeText, ok := h.loadResume(w, r, req.ResumeID)
+	if !ok {
+		return
 	}
-
-	// ciphertext = nonce || gcm(nonce, plaintext)
-	ct := gcm.Seal(nonce, nonce, plaintext, nil)
-	return ct, nil
-}
-
-func decryptAESGCM(key, ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	resumeFile, err := h.DocumentService.ReadResume(r.Context(), resume)
 	if err != nil {
-		return nil, err
+		log.Printf("Failed to read resume %s: %v", resume.PublicID, err)
+		http.Error(w, "Failed to load resume", http.StatusInternalServerError)
+		return
 	}
-	gcm, err := cipher.NewGCM(block)
+	uid := r.Context().Value("uid").(string)
+
+	job, err := h.Jobs.Enqueue(uid, models.JobKindResumeRewrite, services.ResumeRewriteInput{
+		ResumeID:       resume.PublicID,
+		Filename:       resume.Filename,
+		File:           resumeFile,
+		ResumeText:     resumeText,
+		JobDescription: req.JobDescription,
+	})
 	if err != nil {
-		return nil, err
-	}
-
-	ns := gcm.NonceSize()
-	if len(ciphertext) < ns {
-		return nil, errors.New("ciphertext too short")
+		log.Printf("Failed to queue rewrite for resume %s: %v", resume.PublicID, err)
+		http.Error(w, "Failed to start rewrite", http.StatusInternalServerError)
+		return
 	}
 
-	nonce, data := ciphertext[:ns], ciphertext[ns:]
-	pt, err := gcm.Open(nil, nonce, data, nil)
-	if err != nil {
-		return nil, err
-	}
-	return pt, nil
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
 }
 
-// QueryJob returns the status of a rewrite job and, if completed, the rewritten resume JSON.
-// GET /llm/rewrite/status?job_id=UUID
+// QueryJob returns the status of one of the caller's rewrite jobs and, if
+// completed, the rewritten resume JSON.
+// GET /api/llm/query-job?job_id=UUID
 func (h *LLMHandler) QueryJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	uid := r.Context().Value("uid").(string)
 	jobID := r.URL.Query().Get("job_id")
 	if jobID == "" {
 		http.Error(w, "Missing job_id", http.StatusBadRequest)
 		return
 	}
 
-	// Short DB timeout so this endpoint stays snappy
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
-	defer cancel()
-
-	// If you store plaintext JSONB in result_json and optionally ciphertext in result_json_enc
-	// Select both. Prefer decrypting enc if present. Fall back to plain JSON.
-	var (
-		status  string
-		encJSON []byte // from BYTEA
-		errMsg  sql.NullString
-	)
-
-	err := h.DB.QueryRowContext(ctx, `
-		SELECT status,
-		       COALESCE(result_json_enc, ''::bytea)  AS result_json_enc,
-		       error_message
-		FROM resume_jobs
-		WHERE job_id = $1
-	`, jobID).Scan(&status, &encJSON, &errMsg)
+	job, err := h.Jobs.Get(jobID, uid)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, "Database error"+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to load job %s: %v", jobID, err)
+		http.Error(w, "Failed to load job", http.StatusInternalServerError)
 		return
 	}
 
-	// Decrypt if encrypted payload exists
-	var payload []byte
-	if len(encJSON) > 0 {
-		key := []byte(os.Getenv("RESUME_JSON_KEY")) // 32 bytes for AES-256
-		pt, decErr := decryptAESGCM(key, encJSON)
-		if decErr != nil {
-			http.Error(w, "Failed to decrypt result", http.StatusInternalServerError)
-			return
-		}
-		payload = pt
-	}
+	writeJSON(w, newJobStatus(job))
+}
 
-	// For non completed states do not return rewrites
-	type resp struct {
-		Status   string          `json:"status"`
-		Rewrites json.RawMessage `json:"rewrites"`        // null if not ready
-		Error    *string         `json:"error,omitempty"` // present if failed
-	}
+// jobStatus is a rewrite job as QueryJob reports it. For non completed
+// states it carries no rewrites.
+type jobStatus struct {
+	Status     string             `json:"status"`
+	Stage      string             `json:"stage"`
+	Progress   int                `json:"progress"`
+	Rewrites   json.RawMessage    `json:"rewrites"`             // null if not ready
+	Generation *models.Generation `json:"generation,omitempty"` // prompt and model behind the rewrite
+	Error      *string            `json:"error,omitempty"`      // present if failed
+}
 
-	out := resp{Status: status, Rewrites: nil}
-	if errMsg.Valid && errMsg.String != "" {
-		out.Error = &errMsg.String
+func newJobStatus(job *models.BackgroundJob) jobStatus {
+	out := jobStatus{Status: job.Status, Stage: job.Stage, Progress: job.Progress, Rewrites: nil}
+	if job.Error != "" {
+		out.Error = &job.Error
 	}
 
-	if status == "completed" && len(payload) > 0 && json.Valid(payload) {
-		out.Rewrites = json.RawMessage(payload)
+	if job.Status == models.JobStatusCompleted && json.Valid(job.Result) {
+		out.Rewrites = json.RawMessage(job.Result)
+		var stored struct {
+			Generation *models.Generation `json:"generation"`
+		}
+		if json.Unmarshal(job.Result, &stored) == nil {
+			out.Generation = stored.Generation
+		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	// You can return 200 for all statuses. If you prefer semantics, you can do 202 when processing.
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(out)
+	return out
 }
 
-// saveJobResult stores the successful rewrite + analysis in your DB or cache.
-func (h *LLMHandler) saveJobResult(jobID string, rewrites interface{}) error {
-	data, err := json.Marshal(struct {
-		Rewrites interface{} `json:"rewrites"`
-	}{
-		Rewrites: rewrites,
-	})
+// ListJobs returns the caller's background jobs, newest first, with their
+// status and timings but not their results.
+// GET /api/llm/jobs
+func (h *LLMHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+	jobs, err := h.Jobs.List(uid)
 	if err != nil {
-		return err
+		log.Printf("Failed to list jobs for %s: %v", uid, err)
+		http.Error(w, "Failed to list jobs", http.StatusInternalServerError)
+		return
 	}
+	writeJSON(w, jobs)
+}
 
-	key := []byte(os.Getenv("RESUME_JSON_KEY")) // 32 bytes from a secret manager
-	if len(key) != 32 {
-		return fmt.Errorf("bad key length")
+// CancelJob cancels one of the caller's queued or running jobs and returns
+// it. A job that has already finished is deleted instead, result and all,
+// with no content in reply.
+// DELETE /api/llm/jobs/{id}
+func (h *LLMHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+	jobID := mux.Vars(r)["id"]
+
+	job, err := h.Jobs.Cancel(jobID, uid)
+	if errors.Is(err, services.ErrJobNotCancellable) {
+		err = h.Jobs.Delete(jobID, uid)
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
 	}
-	ciphertext, err := encryptAESGCM(key, data)
 	if err != nil {
-		return err
+		log.Printf("Failed to cancel job %s: %v", jobID, err)
+		http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+		return
 	}
-
-	_, err = h.DB.Exec(`
-  INSERT INTO resume_jobs (job_id, status, result_json_enc)
-  VALUES ($1, $2, $3)
-  ON CONFLICT (job_id) DO UPDATE
-  SET status = EXCLUDED.status,
-      result_json_enc = EXCLUDED.result_json_enc
-`, jobID, "completed", ciphertext)
-
-	return err
+	writeJSON(w, job)
 }
 
-// saveJobError stores an error message for a job in your DB or cache.
-func (h *LLMHandler) saveJobError(jobID string, err error) {
-	log.Println("Save job failed.")
-	// Example using a database (pseudo-code)
-	h.DB.Exec(`INSERT INTO resume_jobs (job_id, status, error_message) VALUES ($1, $2, $3) 
-	         ON CONFLICT(job_id) DO UPDATE SET status = $4, error_message = $5`,
-		jobID, "failed", err.Error(), "failed", err.Error())
+// JobEvents pushes one of the caller's jobs as it moves through its stages,
+// instead of the client polling QueryJob. It serves Server-Sent Events, or a
+// WebSocket when the request is an upgrade; both carry the same messages
+// (see followJob). A browser, which cannot set headers on a WebSocket, may
+// send its token as the second of the subprotocols "bearer" and the token.
+// GET /api/llm/jobs/{id}/events
+func (h *LLMHandler) JobEvents(w http.ResponseWriter, r *http.Request) {
+	uid := r.Context().Value("uid").(string)
+	jobID := mux.Vars(r)["id"]
+
+	// Subscribe before loading the job, so that no change between the two
+	// goes unseen.
+	sub := h.Events.Subscribe(jobID)
+	defer sub.Close()
+
+	job, err := h.Jobs.Get(jobID, uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load job %s: %v", jobID, err)
+		http.Error(w, "Failed to load job", http.StatusInternalServerError)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.followJobWebSocket(w, r, job, sub)
+		return
+	}
+	es := newEventStream(w)
+	h.followJob(r.Context(), job, sub, es.send, es.ping)
 }
 
+// CoverLetterHandler drafts a cover letter. With Accept: text/event-stream
+// the reply streams as it is written (see streamGeneration).
+// POST /api/llm/cover-letter
 func (h *LLMHandler) CoverLetterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -312,16 +307,36 @@ func (h *LLMHandler) CoverLetterHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	letter, err := h.LLMService.GenerateCoverLetter(req.ResumeText, req.JobDescription)
+	_, resumeText, ok := h.loadResume(w, r, req.ResumeID)
+	if !ok {
+		return
+	}
+	uid := r.Context().Value("uid").(string)
+
+	if wantsEventStream(r) {
+		streamGeneration(w, func(stream *services.Stream) (interface{}, error) {
+			letter, gen, err := h.LLMService.GenerateCoverLetter(r.Context(), uid, resumeText, req.JobDescription, stream)
+			if err != nil {
+				return nil, err
+			}
+			return CoverLetterResponse{CoverLetter: letter, Generation: gen}, nil
+		})
+		return
+	}
+
+	letter, gen, err := h.LLMService.GenerateCoverLetter(r.Context(), uid, resumeText, req.JobDescription, nil)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Cover letter generation failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Cover letter generation failed: %v", err), generationErrorStatus(err))
 		return
 	}
 
-	resp := CoverLetterResponse{CoverLetter: letter}
+	resp := CoverLetterResponse{CoverLetter: letter, Generation: gen}
 	writeJSON(w, resp)
 }
 
+// RecommendationsHandler compares a resume with a job description. With
+// Accept: text/event-stream the reply streams as it is written.
+// POST /api/llm/recommendations
 func (h *LLMHandler) RecommendationsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -334,23 +349,42 @@ func (h *LLMHandler) RecommendationsHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	recommendations, err := h.LLMService.GetSuggestions(req.ResumeText, req.JobDescription)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Recommendation generation failed: %v", err), http.StatusInternalServerError)
+	_, resumeText, ok := h.loadResume(w, r, req.ResumeID)
+	if !ok {
+		return
+	}
+	uid := r.Context().Value("uid").(string)
+
+	if wantsEventStream(r) {
+		streamGeneration(w, func(stream *services.Stream) (interface{}, error) {
+			recommendations, gen, err := h.LLMService.GetSuggestions(r.Context(), uid, resumeText, req.JobDescription, stream)
+			if err != nil {
+				return nil, err
+			}
+			return RecommendationsResponse{Recommendations: recommendations, Generation: gen}, nil
+		})
 		return
 	}
 
-	var tmp json.RawMessage
-	if err := json.Unmarshal([]byte(recommendations), &tmp); err != nil {
-		fmt.Printf("Invalid recommendations JSON: %v", err)
-		http.Error(w, "Internal error: invalid AI output", http.StatusInternalServerError)
+	recommendations, gen, err := h.LLMService.GetSuggestions(r.Context(), uid, resumeText, req.JobDescription, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Recommendation generation failed: %v", err), generationErrorStatus(err))
 		return
 	}
 
-	resp := RecommendationsResponse{Recommendations: tmp}
+	resp := RecommendationsResponse{Recommendations: recommendations, Generation: gen}
 	writeJSON(w, resp)
 }
 
+// generationErrorStatus reports a model that would not produce valid output
+// as a bad gateway; anything else is our own failure.
+func generationErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidLLMOutput) {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
 // Utility methods
 func parseJSON(r *http.Request, v interface{}) error {
 	body, err := io.ReadAll(r.Body)
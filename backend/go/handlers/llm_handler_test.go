@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"trackify-jobs/database"
+	"trackify-jobs/llm"
+	"trackify-jobs/prompts"
+	"trackify-jobs/services"
+)
+
+const (
+	testUserID   = "user-1"
+	testResumeID = "5b0c3a52-6f8e-4c1e-9d2a-7f4e8b1c2d3e"
+	testResume   = "Jane Doe\nBackend Engineer at Acme\nGo, Postgres, AWS"
+)
+
+var (
+	validCoverLetter   = `{"cover_letter": "Dear Hiring Manager,\n\nI build Go services.\n\nSincerely,\nJane Doe"}`
+	validSuggestions   = `{"missing_skills": ["Kubernetes"], "keyword_gaps": ["on-call"], "experience_alignment": ["Go APIs"], "general_advice": ["Lead with results."]}`
+	invalidCoverLetter = `{"letter": "Dear Hiring Manager"}`
+	invalidSuggestions = `{"missing_skills": "Kubernetes"}`
+)
+
+// llmHandlerTests runs each case against both generation endpoints.
+var llmHandlerTests = []struct {
+	name    string
+	feature string
+	valid   string
+	invalid string
+	handler func(h *LLMHandler) http.HandlerFunc
+	check   func(t *testing.T, body []byte)
+}{
+	{
+		name:    "cover letter",
+		feature: services.FeatureCoverLetter,
+		valid:   validCoverLetter,
+		invalid: invalidCoverLetter,
+		handler: func(h *LLMHandler) http.HandlerFunc { return h.CoverLetterHandler },
+		check: func(t *testing.T, body []byte) {
+			var resp CoverLetterResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.CoverLetter == nil || !strings.HasPrefix(resp.CoverLetter.CoverLetter, "Dear Hiring Manager") {
+				t.Errorf("cover letter = %+v", resp.CoverLetter)
+			}
+			if resp.Generation.Feature != services.FeatureCoverLetter || resp.Generation.PromptVersion == "" {
+				t.Errorf("generation = %+v", resp.Generation)
+			}
+		},
+	},
+	{
+		name:    "recommendations",
+		feature: services.FeatureSuggestions,
+		valid:   validSuggestions,
+		invalid: invalidSuggestions,
+		handler: func(h *LLMHandler) http.HandlerFunc { return h.RecommendationsHandler },
+		check: func(t *testing.T, body []byte) {
+			var resp RecommendationsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Recommendations == nil || len(resp.Recommendations.MissingSkills) != 1 || resp.Recommendations.MissingSkills[0] != "Kubernetes" {
+				t.Errorf("recommendations = %+v", resp.Recommendations)
+			}
+			if resp.Generation.Feature != services.FeatureSuggestions || resp.Generation.PromptVersion == "" {
+				t.Errorf("generation = %+v", resp.Generation)
+			}
+		},
+	},
+}
+
+func TestGenerationHandlerJSON(t *testing.T) {
+	for _, tt := range llmHandlerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &llm.Fake{Fixtures: map[string]string{tt.feature: tt.valid}}
+			rec := serveGeneration(t, tt.handler(newTestLLMHandler(t, fake)), "")
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			tt.check(t, rec.Body.Bytes())
+
+			calls := fake.Calls()
+			if len(calls) != 1 {
+				t.Fatalf("got %d LLM calls, want 1", len(calls))
+			}
+			if !calls[0].JSON || !strings.Contains(calls[0].Messages[len(calls[0].Messages)-1].Content, "Backend Engineer at Acme") {
+				t.Errorf("request does not carry the stored resume text: %+v", calls[0])
+			}
+		})
+	}
+}
+
+func TestGenerationHandlerEventStream(t *testing.T) {
+	for _, tt := range llmHandlerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &llm.Fake{Fixtures: map[string]string{tt.feature: tt.valid}}
+			rec := serveGeneration(t, tt.handler(newTestLLMHandler(t, fake)), "text/event-stream")
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			events := readEvents(t, rec.Body)
+			var streamed strings.Builder
+			for _, ev := range events[:len(events)-1] {
+				if ev.name != "delta" {
+					t.Fatalf("unexpected %q event before the result", ev.name)
+				}
+				var delta struct{ Text string }
+				if err := json.Unmarshal([]byte(ev.data), &delta); err != nil {
+					t.Fatalf("decoding delta: %v", err)
+				}
+				streamed.WriteString(delta.Text)
+			}
+			if len(events) < 3 {
+				t.Errorf("got %d events, want the reply in several deltas", len(events))
+			}
+			if streamed.String() != tt.valid {
+				t.Errorf("deltas joined = %q, want the raw reply %q", streamed.String(), tt.valid)
+			}
+
+			last := events[len(events)-1]
+			if last.name != "result" {
+				t.Fatalf("last event = %q %s, want result", last.name, last.data)
+			}
+			tt.check(t, []byte(last.data))
+		})
+	}
+}
+
+func TestGenerationHandlerRepair(t *testing.T) {
+	for _, tt := range llmHandlerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtures := map[string]string{tt.feature: tt.valid}
+			firstRequest := firstLLMRequest(t, tt.handler, fixtures)
+			// The first conversation gets a reply that fails the schema; the
+			// repair request carries more messages, so it falls back to the
+			// valid fixture.
+			fixtures[tt.feature+"-"+llm.RequestKey(firstRequest)] = tt.invalid
+
+			t.Run("json", func(t *testing.T) {
+				fake := &llm.Fake{Fixtures: fixtures}
+				rec := serveGeneration(t, tt.handler(newTestLLMHandler(t, fake)), "")
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+				}
+				tt.check(t, rec.Body.Bytes())
+				checkRepairCalls(t, fake.Calls(), tt.invalid)
+			})
+
+			t.Run("event stream", func(t *testing.T) {
+				fake := &llm.Fake{Fixtures: fixtures}
+				rec := serveGeneration(t, tt.handler(newTestLLMHandler(t, fake)), "text/event-stream")
+
+				events := readEvents(t, rec.Body)
+				retries := 0
+				for _, ev := range events {
+					if ev.name == "retry" {
+						retries++
+						var retry struct{ Problems []string }
+						if err := json.Unmarshal([]byte(ev.data), &retry); err != nil || len(retry.Problems) == 0 {
+							t.Errorf("retry event %s has no problems (%v)", ev.data, err)
+						}
+					}
+				}
+				if retries != 1 {
+					t.Errorf("got %d retry events, want 1", retries)
+				}
+				last := events[len(events)-1]
+				if last.name != "result" {
+					t.Fatalf("last event = %q %s, want result", last.name, last.data)
+				}
+				tt.check(t, []byte(last.data))
+				checkRepairCalls(t, fake.Calls(), tt.invalid)
+			})
+		})
+	}
+}
+
+// firstLLMRequest returns the request the handler sends first, so a fixture
+// can be keyed to that exact conversation.
+func firstLLMRequest(t *testing.T, handler func(h *LLMHandler) http.HandlerFunc, fixtures map[string]string) llm.Request {
+	t.Helper()
+	fake := &llm.Fake{Fixtures: fixtures}
+	serveGeneration(t, handler(newTestLLMHandler(t, fake)), "")
+	calls := fake.Calls()
+	if len(calls) == 0 {
+		t.Fatal("handler made no LLM call")
+	}
+	return calls[0]
+}
+
+// checkRepairCalls verifies a rejected reply was sent back with the problems
+// found in it.
+func checkRepairCalls(t *testing.T, calls []llm.Request, invalid string) {
+	t.Helper()
+	if len(calls) != 2 {
+		t.Fatalf("got %d LLM calls, want 2", len(calls))
+	}
+	repair := calls[1].Messages
+	if len(repair) != len(calls[0].Messages)+2 {
+		t.Fatalf("repair request has %d messages, want %d", len(repair), len(calls[0].Messages)+2)
+	}
+	if m := repair[len(repair)-2]; m.Role != llm.RoleAssistant || m.Content != invalid {
+		t.Errorf("repair request does not replay the rejected reply: %+v", m)
+	}
+	if m := repair[len(repair)-1]; m.Role != llm.RoleUser || !strings.Contains(m.Content, "does not match the required JSON format") {
+		t.Errorf("repair request does not ask for a correction: %+v", m)
+	}
+}
+
+func serveGeneration(t *testing.T, handler http.HandlerFunc, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := fmt.Sprintf(`{"resume_id": %q, "job_description": "Backend Engineer, Go and Kubernetes"}`, testResumeID)
+	req := httptest.NewRequest(http.MethodPost, "/api/llm", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "uid", testUserID))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+type sseEvent struct {
+	name, data string
+}
+
+func readEvents(t *testing.T, r io.Reader) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var ev sseEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && ev.name != "":
+			events = append(events, ev)
+			ev = sseEvent{}
+		}
+	}
+	if len(events) == 0 {
+		t.Fatal("no events in the stream")
+	}
+	return events
+}
+
+func newTestLLMHandler(t *testing.T, provider llm.Provider) *LLMHandler {
+	t.Helper()
+	set, err := prompts.Load(os.DirFS("../prompts"))
+	if err != nil {
+		t.Fatalf("loading prompts: %v", err)
+	}
+	db := &database.PostgresDB{DB: sql.OpenDB(resumeConnector{})}
+	t.Cleanup(func() { db.Close() })
+	return NewLLMHandler(services.NewLLMService(provider, set), services.NewDocumentService(db, nil, nil, nil), nil, nil)
+}
+
+// resumeConnector is a database with a single resume, testResumeID, whose
+// text has already been extracted. It answers only the two queries the
+// generation handlers make.
+type resumeConnector struct{}
+
+func (c resumeConnector) Connect(context.Context) (driver.Conn, error) { return resumeConn{}, nil }
+func (c resumeConnector) Driver() driver.Driver                        { return c }
+func (c resumeConnector) Open(string) (driver.Conn, error)             { return resumeConn{}, nil }
+
+type resumeConn struct{}
+
+func (resumeConn) Prepare(query string) (driver.Stmt, error) { return resumeStmt(query), nil }
+func (resumeConn) Close() error                              { return nil }
+func (resumeConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("transactions not supported") }
+
+type resumeStmt string
+
+func (s resumeStmt) Close() error  { return nil }
+func (s resumeStmt) NumInput() int { return -1 }
+
+func (s resumeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("unexpected statement: %s", s)
+}
+
+func (s resumeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(string(s), "FROM resumes r"):
+		if args[0] != testResumeID || args[1] != testUserID {
+			return &resumeRows{}, nil
+		}
+		return &resumeRows{values: [][]driver.Value{{
+			int64(1), testResumeID, testUserID, int64(1), "0e6f3c1a-2b4d-4e8f-9a7b-1c2d3e4f5a6b", int64(1),
+			nil, nil, "", "resume.pdf", "resumes/1.pdf", "application/pdf", int64(2048), nil,
+			"clean", "2026-01-05T10:00:00Z", "",
+		}}}, nil
+	case strings.Contains(string(s), "SELECT extracted_text, extracted_at"):
+		return &resumeRows{values: [][]driver.Value{{testResume, time.Date(2026, 1, 5, 10, 0, 1, 0, time.UTC)}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", s)
+}
+
+type resumeRows struct {
+	values [][]driver.Value
+}
+
+func (r *resumeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *resumeRows) Close() error { return nil }
+
+func (r *resumeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
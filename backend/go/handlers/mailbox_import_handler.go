@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"trackify-jobs/models"
+	"trackify-jobs/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxMailboxUploadBytes bounds a single mailbox export upload.
+const maxMailboxUploadBytes = 200 << 20
+
+type MailboxImportHandler struct {
+	ImportService *services.MailboxImportService
+}
+
+func NewMailboxImportHandler(s *services.MailboxImportService) *MailboxImportHandler {
+	return &MailboxImportHandler{ImportService: s}
+}
+
+// StartImport accepts an mbox file, a zip of .eml files or a single .eml in the
+// "mailbox" form field and returns 202 with the import to poll.
+// POST /api/import/mailbox
+func (h *MailboxImportHandler) StartImport(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Stream the part to disk instead of buffering a large export in memory.
+	r.Body = http.MaxBytesReader(w, r.Body, maxMailboxUploadBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart form", http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Mailbox is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Missing mailbox file", http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "mailbox" {
+			part.Close()
+			continue
+		}
+
+		imp, err := h.ImportService.StartImport(uid, part.FileName(), part)
+		part.Close()
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Mailbox is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to start mailbox import: %v", err)
+			http.Error(w, "Failed to store mailbox", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(imp)
+		return
+	}
+}
+
+func (h *MailboxImportHandler) GetUserImports(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	imports, err := h.ImportService.GetUserImports(uid)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch imports", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, imports)
+}
+
+// GetImport returns progress and, once scanning is done, the review list of drafts.
+func (h *MailboxImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	imp, err := h.ImportService.GetImport(mux.Vars(r)["id"], uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Import not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to fetch import", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, imp)
+}
+
+// AcceptDraft creates a job from a draft. The optional JSON body may override
+// title, company, location, status, notes or url.
+func (h *MailboxImportHandler) AcceptDraft(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := strconv.Atoi(mux.Vars(r)["draftId"])
+	if err != nil {
+		http.Error(w, "invalid draft id", http.StatusBadRequest)
+		return
+	}
+
+	var overrides models.Job
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := h.ImportService.AcceptDraft(draftID, mux.Vars(r)["id"], uid, overrides)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Draft not found or already reviewed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "could not create job", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *MailboxImportHandler) DismissDraft(w http.ResponseWriter, r *http.Request) {
+	uid, ok := r.Context().Value("uid").(string)
+	if !ok || uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := strconv.Atoi(mux.Vars(r)["draftId"])
+	if err != nil {
+		http.Error(w, "invalid draft id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.ImportService.DismissDraft(draftID, mux.Vars(r)["id"], uid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Draft not found or already reviewed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "Failed to dismiss draft", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
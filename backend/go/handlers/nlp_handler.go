@@ -30,6 +30,7 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing file", http.StatusBadRequest)
 		return
 	}
+	defer file.Close()
 
 	jobDesc := r.FormValue("job_description")
 	if jobDesc == "" {
@@ -37,7 +38,7 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := nlpService.Analyze(file, header, jobDesc)
+	result, err := nlpService.Analyze(r.Context(), file, header.Filename, jobDesc)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("NLP service error: %v", err), http.StatusInternalServerError)
 		return
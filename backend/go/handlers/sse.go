@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"trackify-jobs/services"
+)
+
+// wantsEventStream reports whether the client asked for Server-Sent Events.
+func wantsEventStream(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// eventStream writes Server-Sent Events, each with a JSON payload.
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newEventStream starts an event stream response. It lifts the server's
+// read and write timeouts for this response, since a stream lasts as long as
+// the model or job takes (the read timeout would otherwise end it too, by
+// cancelling the request's context); it ends when the handler returns or the
+// client goes away.
+func newEventStream(w http.ResponseWriter) *eventStream {
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift read deadline for event stream: %v", err)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift write deadline for event stream: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w, rc: rc}
+}
+
+func (s *eventStream) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// ping sends a comment line, which clients ignore, to keep proxies from
+// closing a stream that is quiet for a while.
+func (s *eventStream) ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// streamGeneration runs generate with its output sent to the client as
+// events:
+//
+//	delta   {"text": "..."}       the next piece of the model's raw JSON reply
+//	retry   {"problems": [...]}   the reply failed validation; discard the
+//	                              deltas so far, a corrected reply follows
+//	result  {...}                 the validated response, exactly as the
+//	                              non-streaming endpoint returns it
+//	error   {"error": "...", "status": 502}
+//
+// Closing the connection cancels the request context, which the generation
+// runs under, so the upstream call stops with it.
+func streamGeneration(w http.ResponseWriter, generate func(stream *services.Stream) (interface{}, error)) {
+	es := newEventStream(w)
+	result, err := generate(&services.Stream{
+		Delta: func(text string) error {
+			return es.send("delta", map[string]string{"text": text})
+		},
+		Retry: func(problems []string) error {
+			return es.send("retry", map[string][]string{"problems": problems})
+		},
+	})
+	if err != nil {
+		log.Printf("Streamed generation failed: %v", err)
+		es.send("error", map[string]interface{}{"error": err.Error(), "status": generationErrorStatus(err)})
+		return
+	}
+	es.send("result", result)
+}
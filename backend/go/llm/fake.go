@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrNoFixture = errors.New("no fixture for request")
+
+// Fake replays canned replies so the LLM features can run end to end with
+// no network. A reply is looked up by the request's feature, most specific
+// first: "<feature>-<key>" for this exact conversation (key is RequestKey),
+// then "<feature>" for any. Fixtures is checked before Dir, where each
+// fixture is a .json or .txt file holding the reply verbatim.
+type Fake struct {
+	Dir      string
+	Fixtures map[string]string
+
+	mu    sync.Mutex
+	calls []Request
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Complete(ctx context.Context, req Request) (*Response, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, req)
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	names := []string{req.Feature + "-" + RequestKey(req), req.Feature}
+	for _, name := range names {
+		reply, ok, err := f.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		model := req.Model
+		if model == "" {
+			model = "fake"
+		}
+		prompt := 0
+		for _, m := range req.Messages {
+			prompt += estimateTokens(m.Content)
+		}
+		completion := estimateTokens(reply)
+		return &Response{
+			Content: reply,
+			Model:   model,
+			Usage:   Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: add a fixture named %q or %q", ErrNoFixture, names[0], names[1])
+}
+
+// Stream replays the fixture a few words at a time, the way a model streams.
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	resp, err := f.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range splitChunks(resp.Content, 3) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// splitChunks cuts s into pieces of n words, keeping all whitespace so the
+// pieces join back into s.
+func splitChunks(s string, n int) []string {
+	var chunks []string
+	words, start := 0, 0
+	for i := 1; i < len(s); i++ {
+		if s[i-1] == ' ' && s[i] != ' ' {
+			if words++; words == n {
+				chunks = append(chunks, s[start:i])
+				words, start = 0, i
+			}
+		}
+	}
+	if start < len(s) {
+		chunks = append(chunks, s[start:])
+	}
+	return chunks
+}
+
+func (f *Fake) lookup(name string) (string, bool, error) {
+	if reply, ok := f.Fixtures[name]; ok {
+		return reply, true, nil
+	}
+	if f.Dir == "" {
+		return "", false, nil
+	}
+	for _, ext := range []string{".json", ".txt"} {
+		data, err := os.ReadFile(filepath.Join(f.Dir, name+ext))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(data), true, nil
+	}
+	return "", false, nil
+}
+
+// Calls returns the requests the fake has answered or refused, in order.
+func (f *Fake) Calls() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.calls...)
+}
+
+// RequestKey identifies a conversation independently of the model asked
+// for, so a fixture keeps matching when model routing changes.
+func RequestKey(req Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "json=%t\n", req.JSON)
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s %d\n%s\n", m.Role, len(m.Content), m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// estimateTokens approximates a tokenizer at four characters per token.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
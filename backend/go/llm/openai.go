@@ -0,0 +1,163 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultModel answers requests that do not name a model.
+const DefaultModel = openai.GPT4Dot1Mini
+
+// OpenAI talks to the OpenAI API, or to any server implementing its chat
+// completions endpoint when built with a base URL.
+type OpenAI struct {
+	client *openai.Client
+	name   string
+	Model  string // when set, used for every request
+}
+
+// NewOpenAI returns a provider for the OpenAI API when baseURL is empty and
+// for an OpenAI-compatible server otherwise. Local servers usually take any
+// API key. A non-empty model pins every request to it.
+func NewOpenAI(apiKey, baseURL, model string) *OpenAI {
+	cfg := openai.DefaultConfig(apiKey)
+	name := "openai"
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+		name = "local"
+	}
+	return &OpenAI{client: openai.NewClientWithConfig(cfg), name: name, Model: model}
+}
+
+func (p *OpenAI) Name() string { return p.name }
+
+func (p *OpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	creq := p.request(req)
+	resp, err := p.client.CreateChatCompletion(ctx, creq)
+	if err != nil {
+		return nil, fmt.Errorf("%s call failed: %w", p.name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	model := creq.Model
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	return &Response{
+		Content: resp.Choices[0].Message.Content,
+		Model:   model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func (p *OpenAI) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Response, error) {
+	creq := p.request(req)
+	creq.Stream = true
+	if p.name == "openai" {
+		// Usage arrives in a final, choice-less chunk. Not every compatible
+		// server knows the option, and some reject unknown fields.
+		creq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	stream, err := p.client.CreateChatCompletionStream(ctx, creq)
+	if err != nil {
+		return nil, fmt.Errorf("%s call failed: %w", p.name, err)
+	}
+	defer stream.Close()
+
+	out := &Response{Model: creq.Model}
+	var content strings.Builder
+	choices := false
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s stream failed: %w", p.name, err)
+		}
+		if chunk.Model != "" {
+			out.Model = chunk.Model
+		}
+		if u := chunk.Usage; u != nil {
+			out.Usage = Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choices = true
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			content.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !choices {
+		return nil, ErrEmptyResponse
+	}
+	out.Content = content.String()
+	return out, nil
+}
+
+// request translates req for the API, applying the pinned model.
+func (p *OpenAI) request(req Request) openai.ChatCompletionRequest {
+	model := req.Model
+	if p.Model != "" {
+		model = p.Model
+	}
+	if model == "" {
+		model = DefaultModel
+	}
+
+	creq := openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: make([]openai.ChatCompletionMessage, len(req.Messages)),
+	}
+	if t := req.Temperature; t != nil {
+		// go-openai omits a zero temperature and the API then uses its
+		// default of 1, so an explicit 0 goes out as the smallest float32.
+		creq.Temperature = *t
+		if creq.Temperature == 0 {
+			creq.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	for i, m := range req.Messages {
+		creq.Messages[i] = openai.ChatCompletionMessage{Role: m.Role, Content: m.Content}
+	}
+	if req.MaxTokens > 0 {
+		// OpenAI wants max_completion_tokens, which also counts reasoning
+		// tokens; compatible servers mostly still only read max_tokens.
+		if p.name == "openai" {
+			creq.MaxCompletionTokens = req.MaxTokens
+		} else {
+			creq.MaxTokens = req.MaxTokens
+		}
+	}
+	switch {
+	case req.Schema != nil && p.name == "openai":
+		// Compatible servers differ in which schema features they accept,
+		// so only OpenAI gets the schema; the others get plain JSON mode.
+		creq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   req.Schema.Name,
+				Schema: &req.Schema.Definition,
+				Strict: req.Schema.Strict,
+			},
+		}
+	case req.JSON || req.Schema != nil:
+		creq.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return creq
+}
@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Schema is the shape a JSON reply must have. Providers with structured
+// outputs hand it to the model; the caller checks the reply with Validate
+// either way, since not every backend enforces it.
+type Schema struct {
+	Name       string
+	Definition jsonschema.Definition
+	// Strict asks the provider to guarantee the shape. OpenAI only allows it
+	// when every object requires all its properties and forbids others, so
+	// map-like objects (additionalProperties with a schema) cannot be strict.
+	Strict bool
+}
+
+// SchemaError lists every way a reply departs from its schema, one problem
+// per entry, each starting with the JSON path it concerns.
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "reply does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate parses content and checks it against the schema. It returns a
+// *SchemaError listing all problems, not just the first, so that a model
+// asked to fix its reply can fix everything at once.
+func (s *Schema) Validate(content string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return &SchemaError{Problems: []string{"reply is not valid JSON: " + err.Error()}}
+	}
+	var problems []string
+	validate(&s.Definition, v, "$", &problems)
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+func validate(d *jsonschema.Definition, v interface{}, path string, problems *[]string) {
+	if v == nil && d.Nullable {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+" "+fmt.Sprintf(format, args...))
+	}
+
+	switch d.Type {
+	case jsonschema.Object:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("must be an object, got %s", jsonType(v))
+			return
+		}
+		for _, key := range d.Required {
+			if _, ok := obj[key]; !ok {
+				*problems = append(*problems, path+"."+key+" is required")
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := d.Properties[key]; ok {
+				validate(&prop, obj[key], path+"."+key, problems)
+				continue
+			}
+			switch extra := d.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					*problems = append(*problems, path+"."+key+" is not allowed")
+				}
+			case jsonschema.Definition:
+				validate(&extra, obj[key], path+"."+key, problems)
+			}
+		}
+	case jsonschema.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			fail("must be an array, got %s", jsonType(v))
+			return
+		}
+		if d.Items != nil {
+			for i, item := range arr {
+				validate(d.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case jsonschema.String:
+		s, ok := v.(string)
+		if !ok {
+			fail("must be a string, got %s", jsonType(v))
+			return
+		}
+		if len(d.Enum) > 0 && !contains(d.Enum, s) {
+			fail("must be one of %s, got %q", strings.Join(d.Enum, ", "), s)
+		}
+	case jsonschema.Number:
+		if _, ok := v.(float64); !ok {
+			fail("must be a number, got %s", jsonType(v))
+		}
+	case jsonschema.Integer:
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			fail("must be an integer, got %s", jsonType(v))
+		}
+	case jsonschema.Boolean:
+		if _, ok := v.(bool); !ok {
+			fail("must be a boolean, got %s", jsonType(v))
+		}
+	case jsonschema.Null:
+		if v != nil {
+			fail("must be null, got %s", jsonType(v))
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		if n == float64(int64(n)) {
+			return "an integer"
+		}
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
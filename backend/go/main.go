@@ -5,13 +5,20 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"trackify-jobs/config"
 	"trackify-jobs/database"
 	"trackify-jobs/handlers"
+	"trackify-jobs/llm"
 	"trackify-jobs/middleware"
+	"trackify-jobs/models"
+	"trackify-jobs/prompts"
+	"trackify-jobs/scanner"
 	"trackify-jobs/services"
+	"trackify-jobs/storage"
 
 	firebase "firebase.google.com/go/v4"
 	"github.com/gorilla/mux"
@@ -66,15 +73,65 @@ func main() {
 	middleware.InitFirebaseAuth(authClient)
 
 	// Start services
-	emailService := services.NewEmailService("")
+	emailService := services.NewEmailService(os.Getenv("RESEND_API_KEY"), db)
 	defer emailService.Stop()
+	emailHandler := handlers.NewEmailHandler(emailService)
 
-	llmService := services.NewLLMService()
+	llmProvider, err := llm.NewProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize LLM provider: %v", err)
+	}
+	llmPrompts, err := prompts.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load LLM prompts: %v", err)
+	}
+	llmService := services.NewLLMService(llmProvider, llmPrompts)
+
+	blobStore, err := storage.NewBlobStoreFromEnv(cfg.MainFolder)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob storage: %v", err)
+	}
 
-	documentService := services.NewDocumentService(db, cfg.MainFolder)
+	fileScanner, err := scanner.NewScannerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize file scanner: %v", err)
+	}
+
+	if key := os.Getenv("UNIDOC_LICENSE_API_KEY"); key != "" {
+		if err := services.SetPDFLicenseKey(key); err != nil {
+			log.Fatalf("Failed to set PDF license key: %v", err)
+		}
+	} else {
+		log.Println("UNIDOC_LICENSE_API_KEY is not set; text extraction from PDF resumes will fail")
+	}
+
+	documentService := services.NewDocumentService(db, blobStore, fileScanner, llmService)
 	documentHandler := handlers.NewDocumentHandler(documentService)
 	nlpService := services.NewNLPService(20)
-	suggestionsHandler := handlers.NewLLMHandler(llmService, nlpService, db)
+
+	jobQueue, err := services.NewJobQueue(db, []byte(os.Getenv("RESUME_JSON_KEY")), services.JobQueueOptions{
+		Workers:   cfg.JobWorkers,
+		Retention: cfg.JobRetention,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize job queue: %v", err)
+	}
+	jobQueue.Register(models.JobKindResumeRewrite, services.ResumeRewriteJob(nlpService, llmService))
+	jobQueue.Start()
+	jobEvents, err := services.NewJobEvents(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to listen for job events: %v", err)
+	}
+	suggestionsHandler := handlers.NewLLMHandler(llmService, documentService, jobQueue, jobEvents)
+
+	inboundEmailService := services.NewInboundEmailService(db, llmService)
+	inboundEmailHandler := handlers.NewInboundEmailHandler(inboundEmailService)
+
+	mailboxImportService := services.NewMailboxImportService(db, cfg.MainFolder)
+	if err := mailboxImportService.ResumeImports(); err != nil {
+		log.Printf("Failed to resume mailbox imports: %v", err)
+	}
+	mailboxImportHandler := handlers.NewMailboxImportHandler(mailboxImportService)
 
 	stripeService := services.NewStripeService(db)
 	stripeHandler := handlers.NewStripeHandler(authClient, stripeService, db, firebaseApp)
@@ -94,14 +151,36 @@ func main() {
 	public.HandleFunc("/logout", handlers.Logout).Methods("POST")
 	public.HandleFunc("/init-user", stripeHandler.CreateNewUserHandler).Methods("POST")
 	public.HandleFunc("/stripe/webhook", webhookHandler.Handle)
+	public.HandleFunc("/email/unsubscribe", emailHandler.UnsubscribePage).Methods("GET")
+	public.HandleFunc("/email/unsubscribe", emailHandler.Unsubscribe).Methods("POST")
+	public.HandleFunc("/email/webhook", emailHandler.ProviderWebhook).Methods("POST")
+	public.HandleFunc("/inbound/email", inboundEmailHandler.ReceiveEmail).Methods("POST")
+	public.HandleFunc("/files/{token}", documentHandler.DownloadFile).Methods("GET")
 
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.FirebaseMiddleware)
 
 	// Resume management
+	protected.HandleFunc("/usage", documentHandler.GetUsage).Methods("GET")
 	protected.HandleFunc("/resumes", documentHandler.CreateResume).Methods("POST")
 	protected.HandleFunc("/resumes", documentHandler.GetUserResumes).Methods("GET")
+	protected.HandleFunc("/resumes/import/jsonresume", documentHandler.ImportJSONResume).Methods("POST")
+	protected.HandleFunc("/resumes/render", documentHandler.RenderStructuredResume).Methods("POST")
 	protected.HandleFunc("/resumes/{id}", documentHandler.GetResumeByID).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/download-url", documentHandler.GetResumeDownloadURL).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/text", documentHandler.GetResumeText).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/structured", documentHandler.GetStructuredResume).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/structured", documentHandler.UpdateStructuredResume).Methods("PUT")
+	protected.HandleFunc("/resumes/{id}/label", documentHandler.SetResumeLabel).Methods("PUT")
+	protected.HandleFunc("/resumes/{id}/diff", documentHandler.DiffResume).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/export", documentHandler.ExportResume).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/export", documentHandler.SaveResumeExport).Methods("POST")
+	protected.HandleFunc("/resumes/{id}/render", documentHandler.RenderResume).Methods("GET")
+	protected.HandleFunc("/resumes/{id}/lint", documentHandler.LintResume).Methods("POST")
+	protected.HandleFunc("/resume-templates", documentHandler.GetResumeTemplates).Methods("GET")
+	protected.HandleFunc("/resume-documents", documentHandler.GetResumeDocuments).Methods("GET")
+	protected.HandleFunc("/resume-documents/{id}", documentHandler.GetResumeDocument).Methods("GET")
+	protected.HandleFunc("/resume-documents/{id}", documentHandler.RenameResumeDocument).Methods("PUT")
 	protected.HandleFunc("/resumes/{id}", documentHandler.DeleteResume).Methods("DELETE")
 
 	// NLP usage
@@ -112,6 +191,23 @@ func main() {
 	protected.HandleFunc("/stripe/create-checkout-session", stripeHandler.CreateCheckoutSession).Methods("POST")
 	protected.HandleFunc("/stripe/create-customer-portal-session", stripeHandler.CreateCustomerPortalSession).Methods("POST")
 
+	// Email notification preferences
+	protected.HandleFunc("/email/preferences", emailHandler.GetPreferences).Methods("GET")
+	protected.HandleFunc("/email/preferences", emailHandler.UpdatePreferences).Methods("PUT")
+
+	// Inbound recruiter email
+	protected.HandleFunc("/inbound/address", inboundEmailHandler.GetForwardingAddress).Methods("GET")
+	protected.HandleFunc("/job-status-proposals", inboundEmailHandler.GetProposals).Methods("GET")
+	protected.HandleFunc("/job-status-proposals/{id}/accept", inboundEmailHandler.AcceptProposal).Methods("POST")
+	protected.HandleFunc("/job-status-proposals/{id}/reject", inboundEmailHandler.RejectProposal).Methods("POST")
+
+	// Mailbox import
+	protected.HandleFunc("/import/mailbox", mailboxImportHandler.StartImport).Methods("POST")
+	protected.HandleFunc("/import/mailbox", mailboxImportHandler.GetUserImports).Methods("GET")
+	protected.HandleFunc("/import/mailbox/{id}", mailboxImportHandler.GetImport).Methods("GET")
+	protected.HandleFunc("/import/mailbox/{id}/drafts/{draftId}/accept", mailboxImportHandler.AcceptDraft).Methods("POST")
+	protected.HandleFunc("/import/mailbox/{id}/drafts/{draftId}/dismiss", mailboxImportHandler.DismissDraft).Methods("POST")
+
 	// Feedback + account
 	protected.HandleFunc("/feedback", NotImplemented).Methods("POST")
 	protected.HandleFunc("/delete-account", NotImplemented).Methods("POST")
@@ -126,7 +222,19 @@ func main() {
 	protected.HandleFunc("/cover-letters", documentHandler.CreateCoverLetter).Methods("POST")
 	protected.HandleFunc("/cover-letters", documentHandler.GetUserCoverLetters).Methods("GET")
 	protected.HandleFunc("/cover-letters/{id}", documentHandler.GetCoverLetterByID).Methods("GET")
+	protected.HandleFunc("/cover-letters/{id}", documentHandler.UpdateCoverLetter).Methods("PATCH")
 	protected.HandleFunc("/cover-letters/{id}", documentHandler.DeleteCoverLetter).Methods("DELETE")
+	protected.HandleFunc("/cover-letters/{id}/export", documentHandler.ExportCoverLetter).Methods("GET")
+	protected.HandleFunc("/cover-letters/{id}/versions", documentHandler.GetCoverLetterVersions).Methods("GET")
+	protected.HandleFunc("/cover-letters/{id}/versions/{version}", documentHandler.GetCoverLetterVersion).Methods("GET")
+	protected.HandleFunc("/cover-letters/{id}/versions/{version}/restore", documentHandler.RestoreCoverLetterVersion).Methods("POST")
+	protected.HandleFunc("/cover-letter-templates", documentHandler.CreateCoverLetterTemplate).Methods("POST")
+	protected.HandleFunc("/cover-letter-templates", documentHandler.GetCoverLetterTemplates).Methods("GET")
+	protected.HandleFunc("/cover-letter-templates/{id}", documentHandler.GetCoverLetterTemplate).Methods("GET")
+	protected.HandleFunc("/cover-letter-templates/{id}", documentHandler.UpdateCoverLetterTemplate).Methods("PUT")
+	protected.HandleFunc("/cover-letter-templates/{id}", documentHandler.DeleteCoverLetterTemplate).Methods("DELETE")
+	protected.HandleFunc("/cover-letter-templates/{id}/preview", documentHandler.PreviewCoverLetterTemplate).Methods("POST")
+	protected.HandleFunc("/cover-letter-templates/{id}/render", documentHandler.RenderCoverLetterTemplate).Methods("POST")
 
 	// Pro-only LLM routes
 	subMiddleware := &middleware.Handler{DB: db}
@@ -134,10 +242,14 @@ func main() {
 	pro.Use(middleware.FirebaseMiddleware)
 	pro.Use(subMiddleware.RequireProSubscription)
 
+	pro.HandleFunc("/resumes/{id}/structured/repair", documentHandler.RepairStructuredResume).Methods("POST") // LLM call
 	pro.HandleFunc("/llm/recommendations", suggestionsHandler.RecommendationsHandler).Methods("POST")
 	pro.HandleFunc("/llm/rewrite", suggestionsHandler.ResumeRewriteHandler).Methods("POST")
 	pro.HandleFunc("/llm/cover-letter", suggestionsHandler.CoverLetterHandler).Methods("POST")
 	pro.HandleFunc("/llm/query-job", suggestionsHandler.QueryJob).Methods("GET")
+	pro.HandleFunc("/llm/jobs", suggestionsHandler.ListJobs).Methods("GET")
+	pro.HandleFunc("/llm/jobs/{id}", suggestionsHandler.CancelJob).Methods("DELETE")
+	pro.HandleFunc("/llm/jobs/{id}/events", suggestionsHandler.JobEvents).Methods("GET")
 
 	// Apply CORS middleware
 	http.Handle("/", middleware.CORSMiddleware(router))
@@ -155,10 +267,32 @@ func main() {
 		WriteTimeout: 120 * time.Second, // max time to write response
 		IdleTimeout:  60 * time.Second,
 	}
+	// End job event streams as soon as shutdown starts; Shutdown would
+	// otherwise wait on them until its deadline.
+	srv.RegisterOnShutdown(jobEvents.Close)
 
 	// Start server
-	log.Printf("Server listening on port %s", cfg.Port)
-	log.Fatal(srv.ListenAndServe())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Printf("Server listening on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+
+	// Stop taking requests, then let running background jobs finish; any
+	// still running at the deadline go back to the queue for another instance.
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	if err := jobQueue.Stop(shutdownCtx); err != nil {
+		log.Printf("Job queue shutdown: %v", err)
+	}
 }
 
 func NotImplemented(w http.ResponseWriter, r *http.Request) {
@@ -23,14 +23,12 @@ func GetFirebaseAuth() *auth.Client {
 // FirebaseMiddleware verifies Firebase ID tokens from Authorization header
 func FirebaseMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		idToken := bearerToken(r)
+		if idToken == "" {
 			http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
 			return
 		}
 
-		idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
 		token, err := FirebaseAuth.VerifyIDToken(r.Context(), idToken)
 		if err != nil {
 			http.Error(w, "Unauthorized: Invalid Firebase token", http.StatusUnauthorized)
@@ -44,3 +42,25 @@ func FirebaseMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// bearerToken returns the ID token from the Authorization header. Browsers
+// cannot set headers on a WebSocket handshake, so one may instead offer the
+// subprotocols "bearer" and the token, in that order.
+func bearerToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	if len(protocols) == 2 && protocols[0] == "bearer" {
+		return protocols[1]
+	}
+	return ""
+}
@@ -13,9 +13,11 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		// It is recommended to specify specific origins instead of '*' for security reasons.
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		// Set allowed HTTP methods for CORS requests.
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		// Set allowed headers for CORS requests.
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		// Let the browser read response headers that describe a download.
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Render-Pages, X-Render-Font-Size")
 
 		// If the incoming request is an OPTIONS request (preflight request), respond with a 200 OK status.
 		// This is necessary for handling certain types of CORS requests.
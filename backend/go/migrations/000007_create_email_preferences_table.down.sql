@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_email_suppressions_user_id;
+DROP TABLE IF EXISTS email_suppressions;
+DROP TABLE IF EXISTS email_preferences;
@@ -0,0 +1,19 @@
+CREATE TABLE email_preferences (
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, category)
+);
+
+CREATE TABLE email_suppressions (
+    id SERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    category TEXT NOT NULL DEFAULT 'all',
+    reason TEXT NOT NULL, -- unsubscribe, bounce, complaint
+    user_id TEXT REFERENCES user_stripe(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (email, category)
+);
+
+CREATE INDEX idx_email_suppressions_user_id ON email_suppressions(user_id);
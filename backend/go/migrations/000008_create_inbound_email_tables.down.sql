@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_job_status_proposals_user_status;
+DROP TABLE IF EXISTS job_status_proposals;
+DROP INDEX IF EXISTS idx_inbound_messages_user_from;
+DROP TABLE IF EXISTS inbound_messages;
+DROP TABLE IF EXISTS inbound_addresses;
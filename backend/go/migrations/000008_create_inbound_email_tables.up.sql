@@ -0,0 +1,38 @@
+CREATE TABLE inbound_addresses (
+    user_id TEXT PRIMARY KEY REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE inbound_messages (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    message_id TEXT NOT NULL,
+    from_address TEXT NOT NULL,
+    from_name TEXT,
+    subject TEXT,
+    body TEXT,
+    classification TEXT NOT NULL,
+    confidence REAL NOT NULL DEFAULT 0,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, message_id)
+);
+
+CREATE INDEX idx_inbound_messages_user_from ON inbound_messages(user_id, from_address);
+
+CREATE TABLE job_status_proposals (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    job_id INT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    inbound_message_id INT REFERENCES inbound_messages(id) ON DELETE SET NULL,
+    current_status TEXT,
+    proposed_status TEXT NOT NULL,
+    classification TEXT NOT NULL,
+    confidence REAL NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, rejected
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX idx_job_status_proposals_user_status ON job_status_proposals(user_id, status);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_job_drafts_import_id;
+DROP TABLE IF EXISTS job_drafts;
+DROP INDEX IF EXISTS idx_mailbox_imports_user_id;
+DROP TABLE IF EXISTS mailbox_imports;
@@ -0,0 +1,34 @@
+CREATE TABLE mailbox_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued', -- queued, processing, completed, failed
+    total_messages INT NOT NULL DEFAULT 0,
+    processed_messages INT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_mailbox_imports_user_id ON mailbox_imports(user_id);
+
+CREATE TABLE job_drafts (
+    id SERIAL PRIMARY KEY,
+    import_id UUID NOT NULL REFERENCES mailbox_imports(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    company TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    job_status TEXT NOT NULL DEFAULT 'applied',
+    applied_at TIMESTAMP,
+    thread_id TEXT NOT NULL,
+    thread_url TEXT,
+    subject TEXT,
+    from_address TEXT,
+    message_count INT NOT NULL DEFAULT 1,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, dismissed
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (import_id, thread_id)
+);
+
+CREATE INDEX idx_job_drafts_import_id ON job_drafts(import_id);
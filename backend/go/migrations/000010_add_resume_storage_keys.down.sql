@@ -0,0 +1,3 @@
+ALTER TABLE resumes DROP COLUMN IF EXISTS size_bytes;
+ALTER TABLE resumes DROP COLUMN IF EXISTS content_type;
+ALTER TABLE resumes DROP COLUMN IF EXISTS storage_key;
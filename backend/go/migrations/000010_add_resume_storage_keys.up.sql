@@ -0,0 +1,10 @@
+-- Files are now stored under generated keys; filename is only the original
+-- name the user uploaded. Existing rows keep their old path as the key; it is
+-- not unique because the old scheme let users overwrite each other's files.
+ALTER TABLE resumes ADD COLUMN storage_key TEXT;
+ALTER TABLE resumes ADD COLUMN content_type TEXT NOT NULL DEFAULT 'application/octet-stream';
+ALTER TABLE resumes ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0;
+
+UPDATE resumes SET storage_key = filename WHERE storage_key IS NULL;
+
+ALTER TABLE resumes ALTER COLUMN storage_key SET NOT NULL;
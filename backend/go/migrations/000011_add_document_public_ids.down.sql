@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cover_letters_public_id;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS public_id;
+DROP INDEX IF EXISTS idx_resumes_public_id;
+ALTER TABLE resumes DROP COLUMN IF EXISTS public_id;
@@ -0,0 +1,7 @@
+-- Documents are addressed by random public IDs in the API; the serial id stays
+-- internal for foreign keys.
+ALTER TABLE resumes ADD COLUMN public_id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX idx_resumes_public_id ON resumes(public_id);
+
+ALTER TABLE cover_letters ADD COLUMN public_id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX idx_cover_letters_public_id ON cover_letters(public_id);
@@ -0,0 +1,7 @@
+ALTER TABLE resumes DROP CONSTRAINT IF EXISTS resumes_document_version_key;
+ALTER TABLE resumes DROP COLUMN IF EXISTS label;
+ALTER TABLE resumes DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE resumes DROP COLUMN IF EXISTS version;
+ALTER TABLE resumes DROP COLUMN IF EXISTS document_id;
+DROP INDEX IF EXISTS idx_resume_documents_user_id;
+DROP TABLE IF EXISTS resume_documents;
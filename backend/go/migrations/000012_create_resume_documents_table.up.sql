@@ -0,0 +1,31 @@
+-- A resume document groups the numbered versions of one resume. parent_id
+-- records which version a tailored variant was derived from and may point
+-- into another document.
+CREATE TABLE resume_documents (
+    id SERIAL PRIMARY KEY,
+    public_id UUID NOT NULL DEFAULT gen_random_uuid() UNIQUE,
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    seed_resume_id INT -- only used by the backfill below
+);
+
+CREATE INDEX idx_resume_documents_user_id ON resume_documents(user_id);
+
+ALTER TABLE resumes ADD COLUMN document_id INT REFERENCES resume_documents(id) ON DELETE CASCADE;
+ALTER TABLE resumes ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE resumes ADD COLUMN parent_id INT REFERENCES resumes(id) ON DELETE SET NULL;
+ALTER TABLE resumes ADD COLUMN label TEXT NOT NULL DEFAULT '';
+
+-- Every existing upload becomes version 1 of its own document.
+INSERT INTO resume_documents (user_id, name, created_at, updated_at, seed_resume_id)
+SELECT user_id, filename, uploaded_at, uploaded_at, id FROM resumes;
+
+UPDATE resumes r SET document_id = d.id
+FROM resume_documents d
+WHERE d.seed_resume_id = r.id;
+
+ALTER TABLE resume_documents DROP COLUMN seed_resume_id;
+ALTER TABLE resumes ALTER COLUMN document_id SET NOT NULL;
+ALTER TABLE resumes ADD CONSTRAINT resumes_document_version_key UNIQUE (document_id, version);
@@ -0,0 +1,3 @@
+ALTER TABLE resumes DROP COLUMN IF EXISTS extracted_at;
+ALTER TABLE resumes DROP COLUMN IF EXISTS extraction_error;
+ALTER TABLE resumes DROP COLUMN IF EXISTS extracted_text;
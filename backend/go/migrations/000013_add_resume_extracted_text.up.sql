@@ -0,0 +1,6 @@
+-- Text is extracted once on upload so LLM endpoints never take client text.
+-- extracted_at is NULL for rows uploaded before extraction existed; those are
+-- extracted on first use.
+ALTER TABLE resumes ADD COLUMN extracted_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE resumes ADD COLUMN extraction_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE resumes ADD COLUMN extracted_at TIMESTAMP;
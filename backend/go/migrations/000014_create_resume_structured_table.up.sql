@@ -0,0 +1,10 @@
+-- Structured form of one resume version. data follows
+-- models.StructuredResume; confidence maps each top-level field to [0, 1].
+-- source records whether the parser, an LLM repair or the user wrote it last.
+CREATE TABLE resume_structured (
+    resume_id INT PRIMARY KEY REFERENCES resumes(id) ON DELETE CASCADE,
+    data JSONB NOT NULL,
+    confidence JSONB NOT NULL DEFAULT '{}',
+    source TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS resumes_user_sha256_idx;
+ALTER TABLE resumes DROP COLUMN IF EXISTS content_sha256;
+DROP TABLE IF EXISTS resume_blobs;
@@ -0,0 +1,23 @@
+-- Identical uploads share one stored file. Each row counts the resume versions
+-- pointing at a storage key; the file is deleted when the count reaches zero.
+-- Deduplication is per user so one user's upload never reveals another's.
+CREATE TABLE resume_blobs (
+    storage_key TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    content_sha256 TEXT,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    ref_count INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX resume_blobs_user_sha256_idx ON resume_blobs (user_id, content_sha256);
+
+ALTER TABLE resumes ADD COLUMN content_sha256 TEXT;
+CREATE INDEX resumes_user_sha256_idx ON resumes (user_id, content_sha256);
+
+-- Files stored before hashing have no hash; count their references so deletes
+-- work the same way. Keys from the old scheme may be shared between users.
+INSERT INTO resume_blobs (storage_key, user_id, size_bytes, ref_count)
+SELECT storage_key, MIN(user_id), MAX(size_bytes), COUNT(*)
+FROM resumes
+GROUP BY storage_key;
@@ -0,0 +1,3 @@
+ALTER TABLE resume_blobs DROP COLUMN IF EXISTS scanned_at;
+ALTER TABLE resume_blobs DROP COLUMN IF EXISTS scan_detail;
+ALTER TABLE resume_blobs DROP COLUMN IF EXISTS scan_status;
@@ -0,0 +1,6 @@
+-- Files are scanned after upload and served only once clean. Files stored
+-- before scanning existed start as pending and are scanned on first download.
+ALTER TABLE resume_blobs ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (scan_status IN ('pending', 'clean', 'quarantined', 'failed'));
+ALTER TABLE resume_blobs ADD COLUMN scan_detail TEXT NOT NULL DEFAULT '';
+ALTER TABLE resume_blobs ADD COLUMN scanned_at TIMESTAMP;
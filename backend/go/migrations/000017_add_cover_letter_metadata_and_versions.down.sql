@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS cover_letter_versions;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS version;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS source;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS tone;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS job_id;
+ALTER TABLE cover_letters DROP COLUMN IF EXISTS title;
@@ -0,0 +1,26 @@
+-- Cover letters get a title, an optional target job, a tone and where the text
+-- came from. Letters saved before sources were tracked count as handwritten.
+ALTER TABLE cover_letters ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE cover_letters ADD COLUMN job_id INT REFERENCES jobs(id) ON DELETE SET NULL;
+ALTER TABLE cover_letters ADD COLUMN tone TEXT NOT NULL DEFAULT '';
+ALTER TABLE cover_letters ADD COLUMN source TEXT NOT NULL DEFAULT 'handwritten'
+    CHECK (source IN ('generated', 'handwritten'));
+ALTER TABLE cover_letters ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE cover_letters ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+UPDATE cover_letters SET updated_at = created_at;
+
+-- Every edit is kept as a numbered version; cover_letters holds the latest.
+CREATE TABLE cover_letter_versions (
+    id SERIAL PRIMARY KEY,
+    cover_letter_id INT NOT NULL REFERENCES cover_letters(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    tone TEXT NOT NULL DEFAULT '',
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (cover_letter_id, version)
+);
+
+INSERT INTO cover_letter_versions (cover_letter_id, version, content, created_at)
+SELECT id, 1, content, created_at FROM cover_letters;
@@ -0,0 +1,16 @@
+-- Reusable letters with {{placeholders}} filled in from a tracked job.
+-- defaults holds fallback values for placeholders, custom ones included.
+CREATE TABLE cover_letter_templates (
+    id SERIAL PRIMARY KEY,
+    public_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    tone TEXT NOT NULL DEFAULT '',
+    defaults JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_cover_letter_templates_public_id ON cover_letter_templates(public_id);
+CREATE INDEX idx_cover_letter_templates_user_id ON cover_letter_templates(user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE resume_structured DROP COLUMN model;
+ALTER TABLE resume_structured DROP COLUMN prompt_version;
+ALTER TABLE cover_letters DROP COLUMN model;
+ALTER TABLE cover_letters DROP COLUMN prompt_version;
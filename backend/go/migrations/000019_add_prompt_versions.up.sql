@@ -0,0 +1,7 @@
+-- Generated output records the prompt version and model that produced it, so
+-- prompt experiments can be compared. Empty for anything written before, or
+-- not written by a model.
+ALTER TABLE cover_letters ADD COLUMN prompt_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE cover_letters ADD COLUMN model TEXT NOT NULL DEFAULT '';
+ALTER TABLE resume_structured ADD COLUMN prompt_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE resume_structured ADD COLUMN model TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+-- Durable queue for background work such as resume rewrites. A worker claims
+-- a job by taking a lease (locked_by, locked_until) and keeps extending it
+-- while the job runs; a running job whose lease has lapsed belonged to a
+-- worker that died, and is claimed again. input and result are AES-GCM
+-- encrypted JSON.
+CREATE TABLE background_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL REFERENCES user_stripe(user_id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    input BYTEA NOT NULL,
+    result BYTEA,
+    error_message TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 3,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by TEXT,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_background_jobs_runnable ON background_jobs(run_at) WHERE status IN ('queued', 'running');
+CREATE INDEX idx_background_jobs_user_id ON background_jobs(user_id);
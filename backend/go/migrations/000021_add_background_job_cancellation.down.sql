@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_background_jobs_finished_at;
+UPDATE background_jobs SET status = 'failed' WHERE status = 'cancelled';
+ALTER TABLE background_jobs DROP CONSTRAINT background_jobs_status_check;
+ALTER TABLE background_jobs ADD CONSTRAINT background_jobs_status_check
+    CHECK (status IN ('queued', 'running', 'completed', 'failed'));
@@ -0,0 +1,8 @@
+-- Users can cancel queued and running jobs. Finished jobs, cancelled ones
+-- included, are purged after a retention window.
+ALTER TABLE background_jobs DROP CONSTRAINT background_jobs_status_check;
+ALTER TABLE background_jobs ADD CONSTRAINT background_jobs_status_check
+    CHECK (status IN ('queued', 'running', 'completed', 'failed', 'cancelled'));
+
+CREATE INDEX idx_background_jobs_finished_at ON background_jobs(finished_at)
+    WHERE status IN ('completed', 'failed', 'cancelled');
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS background_job_events ON background_jobs;
+DROP FUNCTION IF EXISTS notify_background_job_event();
+ALTER TABLE background_jobs DROP COLUMN IF EXISTS progress;
+ALTER TABLE background_jobs DROP COLUMN IF EXISTS stage;
@@ -0,0 +1,33 @@
+-- Jobs report how far along they are: stage names the step (queued,
+-- analyzing, rewriting, saving, completed, ...) and progress is a percentage.
+ALTER TABLE background_jobs
+    ADD COLUMN stage TEXT NOT NULL DEFAULT 'queued',
+    ADD COLUMN progress SMALLINT NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100);
+
+UPDATE background_jobs SET stage = status, progress = CASE WHEN status = 'completed' THEN 100 ELSE 0 END;
+
+-- Every change of status, stage or progress is announced on the
+-- background_job_events channel, so any backend instance can push it to
+-- clients whichever instance made it. Heartbeats change none of these and
+-- stay quiet. NOTIFY caps a payload at 8000 bytes and raises past it, which
+-- would roll back the update that fired it, so the error is cut short here.
+CREATE FUNCTION notify_background_job_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND OLD.status = NEW.status AND OLD.stage = NEW.stage AND OLD.progress = NEW.progress THEN
+        RETURN NEW;
+    END IF;
+    PERFORM pg_notify('background_job_events', json_build_object(
+        'job_id', NEW.id,
+        'status', NEW.status,
+        'stage', NEW.stage,
+        'progress', NEW.progress,
+        'error', left(NEW.error_message, 500)
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER background_job_events
+    AFTER INSERT OR UPDATE ON background_jobs
+    FOR EACH ROW EXECUTE FUNCTION notify_background_job_event();
@@ -0,0 +1,3 @@
+ALTER TABLE cover_letter_versions DROP COLUMN model;
+ALTER TABLE cover_letter_versions DROP COLUMN prompt_version;
+ALTER TABLE cover_letter_versions DROP COLUMN source;
@@ -0,0 +1,12 @@
+-- Versions record where their text came from, so restoring one brings back
+-- its source and, for generated text, the prompt version and model. Earlier
+-- versions were not tracked and take the letter's current values.
+ALTER TABLE cover_letter_versions
+    ADD COLUMN source TEXT NOT NULL DEFAULT 'handwritten' CHECK (source IN ('generated', 'handwritten')),
+    ADD COLUMN prompt_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN model TEXT NOT NULL DEFAULT '';
+
+UPDATE cover_letter_versions v
+SET source = c.source, prompt_version = c.prompt_version, model = c.model
+FROM cover_letters c
+WHERE c.id = v.cover_letter_id;
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// JobKind names a kind of background job. Each kind has one handler.
+type JobKind string
+
+const (
+	JobKindResumeRewrite JobKind = "resume_rewrite"
+)
+
+// Background job statuses. A failed attempt that will be retried goes back
+// to queued; failed is final. Cancelled jobs were stopped by their owner.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job stages. The queue sets queued, running, saving and the final stages,
+// which match the final statuses; handlers report the steps in between.
+const (
+	JobStageQueued    = "queued"
+	JobStageRunning   = "running"
+	JobStageAnalyzing = "analyzing"
+	JobStageRewriting = "rewriting"
+	JobStageSaving    = "saving"
+)
+
+// JobEvent is a change in a job's status, stage or progress, as pushed to
+// the job's owner.
+type JobEvent struct {
+	JobID    string `json:"job_id"`
+	Status   string `json:"status"`
+	Stage    string `json:"stage"`
+	Progress int    `json:"progress"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Finished reports whether the event is the job's last.
+func (e JobEvent) Finished() bool {
+	switch e.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// BackgroundJob is one unit of queued work. Input and Result are JSON,
+// encrypted at rest: the database layer stores and returns them as they are
+// given, and the job queue encrypts and decrypts them. A finished job is
+// deleted, result and all, at ExpiresAt.
+type BackgroundJob struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Kind        JobKind    `json:"kind"`
+	Status      string     `json:"status"`
+	Stage       string     `json:"stage"`
+	Progress    int        `json:"progress"` // percent
+	Input       []byte     `json:"-"`
+	Result      []byte     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
@@ -2,9 +2,78 @@ package models
 
 import "time"
 
+// Where a cover letter's text came from.
+const (
+	CoverLetterGenerated   = "generated"
+	CoverLetterHandwritten = "handwritten"
+)
+
+// CoverLetter is the latest version of a letter. JobTitle and Company are
+// read from the linked job. PromptVersion and Model record what wrote a
+// generated letter.
 type CoverLetter struct {
-	ID        int       `json:"id"`
-	UserID    string    `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            int       `json:"-"`
+	PublicID      string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	Title         string    `json:"title"`
+	JobID         *int      `json:"job_id"`
+	JobTitle      string    `json:"job_title,omitempty"`
+	Company       string    `json:"company,omitempty"`
+	Tone          string    `json:"tone"`
+	Source        string    `json:"source"`
+	PromptVersion string    `json:"prompt_version,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Content       string    `json:"content"`
+	Version       int       `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CoverLetterVersion is one saved state of a letter.
+type CoverLetterVersion struct {
+	Version       int       `json:"version"`
+	Title         string    `json:"title"`
+	JobID         *int      `json:"job_id"`
+	Tone          string    `json:"tone"`
+	Source        string    `json:"source"`
+	PromptVersion string    `json:"prompt_version,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Content       string    `json:"content"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CoverLetterPatch lists the fields to change; nil fields are left alone. A
+// JobID of 0 unlinks the job.
+type CoverLetterPatch struct {
+	Title         *string `json:"title"`
+	JobID         *int    `json:"job_id"`
+	Tone          *string `json:"tone"`
+	Source        *string `json:"source"`
+	PromptVersion *string `json:"prompt_version"`
+	Model         *string `json:"model"`
+	Content       *string `json:"content"`
+}
+
+// CoverLetterTemplate is a reusable letter whose Body holds {{placeholders}}.
+// Defaults supplies values for placeholders the job and request leave empty.
+// Placeholders is derived from Body.
+type CoverLetterTemplate struct {
+	ID           int               `json:"-"`
+	PublicID     string            `json:"id"`
+	UserID       string            `json:"user_id"`
+	Name         string            `json:"name"`
+	Body         string            `json:"body"`
+	Tone         string            `json:"tone"`
+	Defaults     map[string]string `json:"defaults"`
+	Placeholders []string          `json:"placeholders"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// CoverLetterPreview is a template filled in for one job. Missing lists the
+// placeholders nothing resolved; they are left in Content as written.
+type CoverLetterPreview struct {
+	Content string            `json:"content"`
+	Values  map[string]string `json:"values"`
+	Missing []string          `json:"missing"`
 }
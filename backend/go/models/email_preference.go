@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Notification categories a user can opt out of individually.
+const (
+	EmailCategoryReminders      = "reminders"
+	EmailCategoryJobUpdates     = "job_updates"
+	EmailCategoryProductUpdates = "product_updates"
+
+	// EmailCategoryAll suppresses every category for an address.
+	EmailCategoryAll = "all"
+)
+
+// EmailCategories lists the categories exposed in the preferences API.
+var EmailCategories = []string{
+	EmailCategoryReminders,
+	EmailCategoryJobUpdates,
+	EmailCategoryProductUpdates,
+}
+
+type EmailPreference struct {
+	UserID    string    `json:"user_id"`
+	Category  string    `json:"category"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type EmailSuppression struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Category  string    `json:"category"`
+	Reason    string    `json:"reason"` // "unsubscribe", "bounce" or "complaint"
+	UserID    string    `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// Generation records what produced an LLM output: the feature, the prompt
+// version that was served (experiments can serve more than one) and the
+// model that answered.
+type Generation struct {
+	Feature       string `json:"feature"`
+	PromptVersion string `json:"prompt_version"`
+	Model         string `json:"model"`
+}
+
+// Suggestions is the suggestions feature's comparison of a resume with a
+// job description.
+type Suggestions struct {
+	MissingSkills       []string `json:"missing_skills"`
+	KeywordGaps         []string `json:"keyword_gaps"`
+	ExperienceAlignment []string `json:"experience_alignment"`
+	GeneralAdvice       []string `json:"general_advice"`
+}
+
+// GeneratedCoverLetter is a drafted letter before the user saves it.
+type GeneratedCoverLetter struct {
+	CoverLetter string `json:"cover_letter"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Classifications assigned to inbound recruiter email.
+const (
+	EmailClassRejection        = "rejection"
+	EmailClassInterviewRequest = "interview_request"
+	EmailClassOffer            = "offer"
+	EmailClassAutoAck          = "auto_ack"
+	EmailClassUnknown          = "unknown"
+)
+
+type InboundMessage struct {
+	ID             int       `json:"id"`
+	UserID         string    `json:"user_id"`
+	JobID          *int      `json:"job_id"`
+	MessageID      string    `json:"message_id"`
+	FromAddress    string    `json:"from_address"`
+	FromName       string    `json:"from_name"`
+	Subject        string    `json:"subject"`
+	Body           string    `json:"-"`
+	Classification string    `json:"classification"`
+	Confidence     float64   `json:"confidence"`
+	ReceivedAt     time.Time `json:"received_at"`
+}
+
+// JobStatusProposal is a status change suggested by an inbound message that
+// waits for the user to accept or reject it.
+type JobStatusProposal struct {
+	ID               int        `json:"id"`
+	UserID           string     `json:"user_id"`
+	JobID            int        `json:"job_id"`
+	InboundMessageID *int       `json:"inbound_message_id"`
+	CurrentStatus    string     `json:"current_status"`
+	ProposedStatus   string     `json:"proposed_status"`
+	Classification   string     `json:"classification"`
+	Confidence       float64    `json:"confidence"`
+	Status           string     `json:"status"` // "pending", "accepted" or "rejected"
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+
+	// Populated on reads for display.
+	Subject string `json:"subject,omitempty"`
+	Company string `json:"company,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
@@ -2,13 +2,22 @@ package models
 
 import "time"
 
+// Job statuses, as the jobs board names them.
+const (
+	JobStatusNeedToApply = "Need to Apply"
+	JobStatusApplied     = "Applied"
+	JobStatusInterview   = "Interview"
+	JobStatusOffer       = "Offer"
+	JobStatusRejected    = "Rejected"
+)
+
 type Job struct {
 	ID        int       `json:"id"`
 	UserID    string    `json:"user_id"`
 	Title     string    `json:"title"`
 	Company   string    `json:"company"`
 	Location  string    `json:"location"`
-	Status    string    `json:"status"` // e.g. "applied", "interviewing", etc.
+	Status    string    `json:"status"` // one of the JobStatus values, or a user-defined status
 	Notes     string    `json:"notes"`
 	URL       string    `json:"url"` // link to the job posting
 	CreatedAt time.Time `json:"created_at"`
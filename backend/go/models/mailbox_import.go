@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type MailboxImport struct {
+	ID                string     `json:"id"`
+	UserID            string     `json:"user_id"`
+	Filename          string     `json:"filename"`
+	Status            string     `json:"status"` // "queued", "processing", "completed" or "failed"
+	TotalMessages     int        `json:"total_messages"`
+	ProcessedMessages int        `json:"processed_messages"`
+	Error             string     `json:"error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	FinishedAt        *time.Time `json:"finished_at"`
+
+	Drafts []JobDraft `json:"drafts,omitempty"`
+}
+
+// JobDraft is a job reconstructed from an imported email thread. It only becomes
+// a tracked Job once the user accepts it.
+type JobDraft struct {
+	ID           int        `json:"id"`
+	ImportID     string     `json:"import_id"`
+	UserID       string     `json:"user_id"`
+	Company      string     `json:"company"`
+	Title        string     `json:"title"`
+	JobStatus    string     `json:"job_status"` // status the job would be created with
+	AppliedAt    *time.Time `json:"applied_at"`
+	ThreadID     string     `json:"thread_id"`
+	ThreadURL    string     `json:"thread_url,omitempty"`
+	Subject      string     `json:"subject"`
+	FromAddress  string     `json:"from_address"`
+	MessageCount int        `json:"message_count"`
+	Status       string     `json:"status"` // "pending", "accepted" or "dismissed"
+	JobID        *int       `json:"job_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
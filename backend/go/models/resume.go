@@ -1,8 +1,66 @@
 package models
 
+import "time"
+
 type Resume struct {
-	ID         int    `json:"id"`
-	UserID     string `json:"user_id"`
-	Filename   string `json:"filename"`
-	UploadedAt string `json:"uploaded_at"`
+	ID          int     `json:"-"`
+	PublicID    string  `json:"id"`
+	UserID      string  `json:"user_id"`
+	DocumentID  int     `json:"-"`
+	DocumentRef string  `json:"document_id"` // public ID of the document
+	Version     int     `json:"version"`
+	ParentID    *int    `json:"-"`
+	ParentRef   *string `json:"parent_id"` // public ID of the version this was derived from
+	Label       string  `json:"label"`
+	Filename    string  `json:"filename"` // original upload name, display only
+	StorageKey  string  `json:"-"`
+	ContentType string  `json:"content_type"`
+	SizeBytes   int64   `json:"size_bytes"`
+	SHA256      string  `json:"sha256,omitempty"` // content hash; empty for files uploaded before hashing
+	ScanStatus  string  `json:"scan_status"`      // one of the Scan* constants
+	UploadedAt  string  `json:"uploaded_at"`
+
+	// DuplicateOf is set on an upload response when the file was identical
+	// to this existing version and no new version was created.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+
+	ExtractedText   string `json:"-"` // loaded only where needed, see GetResumeText
+	ExtractionError string `json:"extraction_error,omitempty"`
+}
+
+// Malware scan states of a stored file. Only clean files are served.
+const (
+	ScanPending     = "pending"
+	ScanClean       = "clean"
+	ScanQuarantined = "quarantined"
+	ScanFailed      = "failed"
+)
+
+// ResumeDocument groups the numbered versions of one resume.
+type ResumeDocument struct {
+	ID        int       `json:"-"`
+	PublicID  string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Versions  []Resume  `json:"versions,omitempty"`
+}
+
+// ResumeDiff compares the extracted text of two versions section by section.
+type ResumeDiff struct {
+	From     string              `json:"from"` // public ID of the older side
+	To       string              `json:"to"`
+	Sections []ResumeSectionDiff `json:"sections"`
+}
+
+type ResumeSectionDiff struct {
+	Section string     `json:"section"`
+	Status  string     `json:"status"` // added, removed, changed, unchanged
+	Lines   []DiffLine `json:"lines,omitempty"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"` // "=", "+", "-"
+	Text string `json:"text"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Lint finding severities, most severe first.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintInfo    = "info"
+)
+
+// LintFinding is one ATS compatibility problem. Location points at where it
+// was found, e.g. "page 2", "section: Experience" or "file".
+type LintFinding struct {
+	Check      string `json:"check"`
+	Severity   string `json:"severity"`
+	Location   string `json:"location"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// LintReport is the result of linting one resume version. Score starts at 100
+// and loses points per finding by severity.
+type LintReport struct {
+	ResumeID  string        `json:"resume_id"`
+	Score     int           `json:"score"`
+	Errors    int           `json:"errors"`
+	Warnings  int           `json:"warnings"`
+	Findings  []LintFinding `json:"findings"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// StructuredResume is the canonical resume schema. The parser produces it from
+// extracted text and the LLM rewrite returns it, so both share one shape.
+type StructuredResume struct {
+	Name       string              `json:"name"`
+	Email      string              `json:"email"`
+	Phone      string              `json:"phone"`
+	LinkedIn   string              `json:"linkedin"`
+	GitHub     string              `json:"github"`
+	Summary    string              `json:"summary"`
+	Education  []EducationEntry    `json:"education"`
+	Experience []ExperienceEntry   `json:"experience"`
+	Projects   []ProjectEntry      `json:"projects"`
+	Skills     map[string][]string `json:"skills"`
+}
+
+type EducationEntry struct {
+	Degree string `json:"degree"`
+	School string `json:"school"`
+	Date   string `json:"date"`
+}
+
+type ExperienceEntry struct {
+	Title   string   `json:"title"`
+	Company string   `json:"company"`
+	Date    string   `json:"date"`
+	Bullets []string `json:"bullets"`
+}
+
+type ProjectEntry struct {
+	Name    string   `json:"name"`
+	Date    string   `json:"date"`
+	Bullets []string `json:"bullets"`
+}
+
+// Structured resume sources.
+const (
+	StructuredSourceParser    = "parser"
+	StructuredSourceLLMRepair = "llm_repair"
+	StructuredSourceUser      = "user"
+	StructuredSourceImport    = "import"
+)
+
+// ResumeStructured is the stored structured form of one resume version.
+// Confidence maps each top-level field of Data to a score in [0, 1].
+// PromptVersion and Model are set when an LLM repair wrote it last.
+type ResumeStructured struct {
+	ResumeID      string             `json:"resume_id"` // public ID
+	Data          StructuredResume   `json:"data"`
+	Confidence    map[string]float64 `json:"confidence"`
+	Source        string             `json:"source"`
+	PromptVersion string             `json:"prompt_version,omitempty"`
+	Model         string             `json:"model,omitempty"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// PlanLimits caps what one user may store. Resumes counts every stored
+// version, since each one is a file.
+type PlanLimits struct {
+	Resumes      int   `json:"resumes"`
+	StorageBytes int64 `json:"storage_bytes"`
+	CoverLetters int   `json:"cover_letters"`
+	MaxFileBytes int64 `json:"max_file_bytes"`
+}
+
+// Usage is what a user has stored against the limits of their plan.
+type Usage struct {
+	Plan         string     `json:"plan"`
+	Resumes      int        `json:"resumes"`
+	StorageBytes int64      `json:"storage_bytes"`
+	CoverLetters int        `json:"cover_letters"`
+	Limits       PlanLimits `json:"limits"`
+}
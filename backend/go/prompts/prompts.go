@@ -0,0 +1,173 @@
+// Package prompts holds the LLM prompts as versioned templates and the
+// per-feature settings used to run them.
+//
+// Each feature has a directory of versions, e.g. cover_letter/v1.tmpl, and
+// every version defines a "system" and a "user" template. config.json picks
+// the model, temperature, token limit and prompt version per feature, and
+// can send a share of users to a second version:
+//
+//	"cover_letter": {
+//	  "model": "gpt-4.1-nano", "temperature": 0.4, "max_tokens": 1500,
+//	  "prompt": "v1",
+//	  "experiment": {"prompt": "v2", "percent": 20}
+//	}
+//
+// The files are compiled into the binary; PROMPTS_DIR points at a directory
+// with the same layout to use instead. Either way they are loaded once, at
+// startup, and a missing or broken template stops the server from starting.
+package prompts
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+//go:embed config.json */*.tmpl
+var embedded embed.FS
+
+var ErrUnknownFeature = errors.New("no prompt configured for feature")
+
+// Feature is one feature's entry in config.json.
+type Feature struct {
+	Model       string      `json:"model"`
+	Temperature *float32    `json:"temperature"` // nil leaves it to the provider
+	MaxTokens   int         `json:"max_tokens"`  // 0 leaves it to the provider
+	Prompt      string      `json:"prompt"`
+	Experiment  *Experiment `json:"experiment,omitempty"`
+}
+
+// Experiment serves a second prompt version to Percent of users.
+type Experiment struct {
+	Prompt  string `json:"prompt"`
+	Percent int    `json:"percent"`
+}
+
+// Prompt is a rendered prompt with the settings to run it. Version is
+// recorded with whatever the model produces.
+type Prompt struct {
+	Feature     string
+	Version     string
+	System      string
+	User        string
+	Model       string
+	Temperature *float32
+	MaxTokens   int
+}
+
+// Set is a loaded configuration and its templates.
+type Set struct {
+	features  map[string]Feature
+	templates map[string]map[string]*template.Template // feature -> version -> template
+}
+
+// LoadFromEnv loads the prompts from PROMPTS_DIR, or the compiled-in copy
+// when it is unset.
+func LoadFromEnv() (*Set, error) {
+	if dir := os.Getenv("PROMPTS_DIR"); dir != "" {
+		return Load(os.DirFS(dir))
+	}
+	return Load(embedded)
+}
+
+// Load reads config.json and every version of every configured feature.
+func Load(fsys fs.FS) (*Set, error) {
+	data, err := fs.ReadFile(fsys, "config.json")
+	if err != nil {
+		return nil, fmt.Errorf("error reading prompt config: %w", err)
+	}
+	s := &Set{features: map[string]Feature{}, templates: map[string]map[string]*template.Template{}}
+	if err := json.Unmarshal(data, &s.features); err != nil {
+		return nil, fmt.Errorf("error parsing prompt config: %w", err)
+	}
+
+	for name, f := range s.features {
+		files, err := fs.Glob(fsys, name+"/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		s.templates[name] = map[string]*template.Template{}
+		for _, file := range files {
+			version := strings.TrimSuffix(path.Base(file), ".tmpl")
+			src, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, fmt.Errorf("error reading prompt %s: %w", file, err)
+			}
+			t, err := template.New(file).Option("missingkey=error").Parse(string(src))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing prompt %s: %w", file, err)
+			}
+			for _, part := range []string{"system", "user"} {
+				if t.Lookup(part) == nil {
+					return nil, fmt.Errorf("prompt %s does not define %q", file, part)
+				}
+			}
+			s.templates[name][version] = t
+		}
+
+		versions := []string{f.Prompt}
+		if e := f.Experiment; e != nil {
+			if e.Percent < 0 || e.Percent > 100 {
+				return nil, fmt.Errorf("prompt experiment for %s: percent must be between 0 and 100", name)
+			}
+			versions = append(versions, e.Prompt)
+		}
+		for _, v := range versions {
+			if s.templates[name][v] == nil {
+				return nil, fmt.Errorf("prompt config for %s names version %q, but %s/%s.tmpl does not exist", name, v, name, v)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Render picks the prompt version for subject, usually a user ID, and fills
+// it in with data. A subject always lands on the same side of an experiment;
+// an empty subject is assigned at random.
+func (s *Set) Render(feature, subject string, data interface{}) (*Prompt, error) {
+	f, ok := s.features[feature]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFeature, feature)
+	}
+	version := f.Prompt
+	if e := f.Experiment; e != nil && bucket(feature+"/"+e.Prompt, subject) < e.Percent {
+		version = e.Prompt
+	}
+
+	t := s.templates[feature][version]
+	var system, user strings.Builder
+	if err := t.ExecuteTemplate(&system, "system", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s/%s: %w", feature, version, err)
+	}
+	if err := t.ExecuteTemplate(&user, "user", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s/%s: %w", feature, version, err)
+	}
+	return &Prompt{
+		Feature:     feature,
+		Version:     version,
+		System:      system.String(),
+		User:        user.String(),
+		Model:       f.Model,
+		Temperature: f.Temperature,
+		MaxTokens:   f.MaxTokens,
+	}, nil
+}
+
+// bucket maps a subject to 0-99, stably per experiment so that different
+// experiments split users independently.
+func bucket(experiment, subject string) int {
+	if subject == "" {
+		return rand.IntN(100)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(experiment + "\x00" + subject))
+	return int(h.Sum32() % 100)
+}
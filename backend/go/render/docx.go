@@ -0,0 +1,295 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"trackify-jobs/models"
+)
+
+// DOCXContentType is the media type of the generated Word files.
+const DOCXContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// wordFonts maps font families to the fonts Word and LibreOffice ship.
+var wordFonts = map[string]string{
+	"helvetica": "Arial",
+	"times":     "Times New Roman",
+	"courier":   "Courier New",
+}
+
+// RenderResumeDOCX writes the structured resume as a Word document using the
+// same template settings as the PDF. Structure is carried by real paragraph
+// styles (Title, Heading 1, List Bullet) so Word's navigation and ATS parsers
+// both see the sections.
+func RenderResumeDOCX(r *models.StructuredResume, opts Options) ([]byte, error) {
+	st, err := resolveStyle(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize.Width == 0 {
+		opts.PageSize = PageLetter
+	}
+
+	d := &docxBody{style: st}
+	d.writeResume(r, opts.PageSize)
+	return d.pack(opts.PageSize, strings.TrimSpace(r.Name+" Resume"))
+}
+
+// pack zips the body with the shared styles, numbering and properties.
+func (d *docxBody) pack(size PageSize, title string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRootRels},
+		{"word/_rels/document.xml.rels", docxDocumentRels},
+		{"word/document.xml", d.document(size)},
+		{"word/styles.xml", docxStyles(d.style)},
+		{"word/numbering.xml", docxNumbering},
+		{"docProps/core.xml", docxCoreProps(title)},
+		{"docProps/app.xml", docxAppProps},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// docxRun is a piece of text and its character formatting.
+type docxRun struct {
+	text         string
+	bold, italic bool
+	tab          bool // a tab character instead of text
+	br           bool // a line break instead of text
+}
+
+type docxBody struct {
+	style style
+	buf   strings.Builder
+}
+
+// para writes one paragraph. rightTab puts a right-aligned tab stop at the
+// text edge, which is how dates are set flush right.
+func (d *docxBody) para(style string, center bool, rightTab float64, runs ...docxRun) {
+	d.buf.WriteString("<w:p><w:pPr>")
+	if style != "" {
+		fmt.Fprintf(&d.buf, `<w:pStyle w:val="%s"/>`, style)
+	}
+	if style == "ListBullet" {
+		// Repeat the style's numbering on the paragraph, as Word does; parsers
+		// that do not resolve styles still see a list item.
+		d.buf.WriteString(`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`)
+	}
+	if rightTab > 0 {
+		fmt.Fprintf(&d.buf, `<w:tabs><w:tab w:val="right" w:pos="%d"/></w:tabs>`, twips(rightTab))
+	}
+	if center {
+		d.buf.WriteString(`<w:jc w:val="center"/>`)
+	}
+	d.buf.WriteString("</w:pPr>")
+	for _, r := range runs {
+		d.buf.WriteString("<w:r>")
+		if r.bold || r.italic {
+			d.buf.WriteString("<w:rPr>")
+			if r.bold {
+				d.buf.WriteString("<w:b/>")
+			}
+			if r.italic {
+				d.buf.WriteString("<w:i/>")
+			}
+			d.buf.WriteString("</w:rPr>")
+		}
+		switch {
+		case r.tab:
+			d.buf.WriteString("<w:tab/>")
+		case r.br:
+			d.buf.WriteString("<w:br/>")
+		default:
+			d.buf.WriteString(`<w:t xml:space="preserve">`)
+			xml.EscapeText(&d.buf, []byte(r.text))
+			d.buf.WriteString("</w:t>")
+		}
+		d.buf.WriteString("</w:r>")
+	}
+	d.buf.WriteString("</w:p>")
+}
+
+func (d *docxBody) writeResume(r *models.StructuredResume, size PageSize) {
+	st := d.style
+	textWidth := size.Width - 2*st.Margin
+
+	d.para("Title", st.centerHeader, 0, docxRun{text: r.Name})
+	if contact := contactLine(r.Email, r.Phone, r.LinkedIn, r.GitHub); contact != "" {
+		d.para("Contact", st.centerHeader, 0, docxRun{text: contact})
+	}
+
+	entry := func(date string, runs ...docxRun) {
+		if date == "" {
+			d.para("EntryHeading", false, 0, runs...)
+			return
+		}
+		if st.datesRight {
+			d.para("EntryHeading", false, textWidth, append(runs, docxRun{tab: true}, docxRun{text: date})...)
+			return
+		}
+		d.para("EntryHeading", false, 0, append(runs, docxRun{text: "  |  " + date})...)
+	}
+	bullets := func(items []string) {
+		for _, b := range items {
+			d.para("ListBullet", false, 0, docxRun{text: b})
+		}
+	}
+
+	if s := strings.TrimSpace(r.Summary); s != "" {
+		d.para("Heading1", false, 0, docxRun{text: "Summary"})
+		d.para("", false, 0, docxRun{text: s})
+	}
+	if len(r.Experience) > 0 {
+		d.para("Heading1", false, 0, docxRun{text: "Experience"})
+		for _, e := range r.Experience {
+			runs := []docxRun{{text: e.Title, bold: true}}
+			if e.Company != "" {
+				if e.Title != "" {
+					runs = append(runs, docxRun{text: ", "})
+				}
+				runs = append(runs, docxRun{text: e.Company, italic: true})
+			}
+			entry(e.Date, runs...)
+			bullets(e.Bullets)
+		}
+	}
+	if len(r.Projects) > 0 {
+		d.para("Heading1", false, 0, docxRun{text: "Projects"})
+		for _, p := range r.Projects {
+			entry(p.Date, docxRun{text: p.Name, bold: true})
+			bullets(p.Bullets)
+		}
+	}
+	if len(r.Education) > 0 {
+		d.para("Heading1", false, 0, docxRun{text: "Education"})
+		for _, e := range r.Education {
+			entry(e.Date, docxRun{text: e.School, bold: true})
+			if e.Degree != "" {
+				d.para("", false, 0, docxRun{text: e.Degree})
+			}
+		}
+	}
+	if len(r.Skills) > 0 {
+		d.para("Heading1", false, 0, docxRun{text: "Skills"})
+		categories := make([]string, 0, len(r.Skills))
+		for c := range r.Skills {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		for _, c := range categories {
+			if len(r.Skills[c]) > 0 {
+				d.para("", false, 0, docxRun{text: c + ":", bold: true}, docxRun{text: " " + strings.Join(r.Skills[c], ", ")})
+			}
+		}
+	}
+}
+
+func (d *docxBody) document(size PageSize) string {
+	m := twips(d.style.Margin)
+	return xml.Header + `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		d.buf.String() +
+		fmt.Sprintf(`<w:sectPr><w:pgSz w:w="%d" w:h="%d"/>`, twips(size.Width), twips(size.Height)) +
+		fmt.Sprintf(`<w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="720" w:footer="720" w:gutter="0"/>`, m, m, m, m) +
+		`</w:sectPr></w:body></w:document>`
+}
+
+// twips converts points to twentieths of a point.
+func twips(points float64) int {
+	return int(math.Round(points * 20))
+}
+
+func docxStyles(st style) string {
+	font := wordFonts[st.Font]
+	size := int(math.Round(st.FontSize * 2)) // half-points
+	line := int(math.Round(st.lineHeight * 240))
+	gap := twips(st.FontSize * st.sectionGap)
+	entryGap := twips(st.FontSize * st.entryGap)
+	border := ""
+	if st.headingRule {
+		border = `<w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="000000"/></w:pBdr>`
+	}
+	return xml.Header + `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		fmt.Sprintf(`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="%[1]s" w:hAnsi="%[1]s" w:cs="%[1]s" w:eastAsia="%[1]s"/>`+
+			`<w:sz w:val="%[2]d"/><w:szCs w:val="%[2]d"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>`+
+			`<w:pPrDefault><w:pPr><w:spacing w:before="0" w:after="0" w:line="%[3]d" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>`,
+			font, size, line) +
+		`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+		fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:rPr><w:b/><w:sz w:val="%[1]d"/><w:szCs w:val="%[1]d"/></w:rPr></w:style>`, int(math.Round(st.FontSize*st.nameScale*2))) +
+		`<w:style w:type="paragraph" w:styleId="Contact"><w:name w:val="Contact"/><w:basedOn w:val="Normal"/></w:style>` +
+		fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:keepNext/>%[1]s<w:spacing w:before="%[2]d" w:after="60"/><w:outlineLvl w:val="0"/></w:pPr>`+
+			`<w:rPr><w:b/><w:caps/><w:sz w:val="%[3]d"/><w:szCs w:val="%[3]d"/></w:rPr></w:style>`, border, gap, int(math.Round(st.FontSize*st.headingScale*2))) +
+		fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="EntryHeading"><w:name w:val="Entry Heading"/><w:basedOn w:val="Normal"/><w:next w:val="ListBullet"/>`+
+			`<w:pPr><w:keepNext/><w:spacing w:before="%d"/></w:pPr></w:style>`, entryGap) +
+		fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Letterhead"><w:name w:val="Letterhead"/><w:basedOn w:val="Contact"/>`+
+			`<w:pPr>%s<w:spacing w:after="%d"/></w:pPr></w:style>`, border, gap) +
+		fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="LetterBody"><w:name w:val="Letter Body"/><w:basedOn w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:spacing w:after="%d"/></w:pPr></w:style>`, twips(st.FontSize*st.lineHeight*letterParagraphGap)) +
+		`<w:style w:type="paragraph" w:styleId="ListBullet"><w:name w:val="List Bullet"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr><w:ind w:left="360" w:hanging="240"/></w:pPr></w:style>` +
+		`</w:styles>`
+}
+
+func docxCoreProps(title string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(title))
+	now := time.Now().UTC().Format(time.RFC3339)
+	return xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + escaped.String() + `</dc:title><dc:creator>Trackify</dc:creator>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + now + `</dcterms:created>` +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + now + `</dcterms:modified>` +
+		`</cp:coreProperties>`
+}
+
+const docxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>` +
+	`</Types>`
+
+const docxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>` +
+	`</Relationships>`
+
+const docxDocumentRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>` +
+	`</Relationships>`
+
+const docxNumbering = xml.Header + `<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:abstractNum w:abstractNumId="0"><w:multiLevelType w:val="singleLevel"/>` +
+	`<w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="•"/><w:lvlJc w:val="left"/>` +
+	`<w:pPr><w:ind w:left="360" w:hanging="240"/></w:pPr></w:lvl></w:abstractNum>` +
+	`<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num></w:numbering>`
+
+const docxAppProps = xml.Header + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">` +
+	`<Application>Trackify</Application></Properties>`
@@ -0,0 +1,184 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Font is one of the PDF standard 14 fonts. Viewers ship these, so nothing is
+// embedded and the text stays selectable and parseable by ATS software.
+type Font struct {
+	Name   string // PostScript base font name
+	widths [256]int
+}
+
+// FontFamily groups the faces a template uses.
+type FontFamily struct {
+	Regular, Bold, Italic *Font
+}
+
+// Width returns the advance width of s at size, in points.
+func (f *Font) Width(s string, size float64) float64 {
+	total := 0
+	encoded, _ := encodeWinAnsi(s)
+	for _, b := range encoded {
+		total += f.widths[b]
+	}
+	return float64(total) * size / 1000
+}
+
+// Font families available to templates.
+var fontFamilies = map[string]FontFamily{}
+
+// FontFamilies lists the family names templates and requests may use.
+func FontFamilies() []string {
+	return []string{"helvetica", "times", "courier"}
+}
+
+func init() {
+	helvetica := newFont("Helvetica", helveticaASCII, fontSpecials{quote: 222, dquote: 333, endash: 556, emdash: 1000, euro: 556, trademark: 1000, copyright: 737})
+	helveticaBold := newFont("Helvetica-Bold", helveticaBoldASCII, fontSpecials{quote: 278, dquote: 500, endash: 556, emdash: 1000, euro: 556, trademark: 1000, copyright: 737})
+	helveticaOblique := newFont("Helvetica-Oblique", helveticaASCII, fontSpecials{quote: 222, dquote: 333, endash: 556, emdash: 1000, euro: 556, trademark: 1000, copyright: 737})
+	times := newFont("Times-Roman", timesASCII, fontSpecials{quote: 333, dquote: 444, endash: 500, emdash: 1000, euro: 500, trademark: 980, copyright: 760})
+	timesBold := newFont("Times-Bold", timesBoldASCII, fontSpecials{quote: 333, dquote: 500, endash: 500, emdash: 1000, euro: 500, trademark: 1000, copyright: 747})
+	timesItalic := newFont("Times-Italic", timesItalicASCII, fontSpecials{quote: 333, dquote: 556, endash: 500, emdash: 889, euro: 500, trademark: 980, copyright: 760})
+
+	courier := func(name string) *Font {
+		f := &Font{Name: name}
+		for i := range f.widths {
+			f.widths[i] = 600
+		}
+		return f
+	}
+
+	fontFamilies["helvetica"] = FontFamily{Regular: helvetica, Bold: helveticaBold, Italic: helveticaOblique}
+	fontFamilies["times"] = FontFamily{Regular: times, Bold: timesBold, Italic: timesItalic}
+	fontFamilies["courier"] = FontFamily{Regular: courier("Courier"), Bold: courier("Courier-Bold"), Italic: courier("Courier-Oblique")}
+}
+
+// fontSpecials are the widths of the WinAnsi punctuation resumes use most.
+type fontSpecials struct {
+	quote, dquote, endash, emdash, euro, trademark, copyright int
+}
+
+// newFont builds a width table from the AFM widths of printable ASCII. The
+// accented Latin-1 letters take the width of their base letter, which is
+// exact for the standard fonts in all but a handful of cases.
+func newFont(name, ascii string, sp fontSpecials) *Font {
+	f := &Font{Name: name}
+	for i, w := range strings.Fields(ascii) {
+		f.widths[32+i], _ = strconv.Atoi(w)
+	}
+	for b := 128; b < 256; b++ {
+		f.widths[b] = f.widths['o']
+	}
+	f.widths[128] = sp.euro
+	f.widths[133] = 1000
+	f.widths[145], f.widths[146] = sp.quote, sp.quote
+	f.widths[147], f.widths[148] = sp.dquote, sp.dquote
+	f.widths[149] = 350
+	f.widths[150], f.widths[151] = sp.endash, sp.emdash
+	f.widths[153] = sp.trademark
+	f.widths[160] = f.widths[' ']
+	f.widths[169], f.widths[174] = sp.copyright, sp.copyright
+	f.widths[173] = f.widths['-']
+	for i, base := range latin1Base {
+		if base != ' ' {
+			f.widths[192+i] = f.widths[base]
+		}
+	}
+	f.widths[198], f.widths[230] = f.widths['W'], f.widths['m'] // Æ, æ
+	f.widths[223] = f.widths['b']                               // ß
+	return f
+}
+
+// latin1Base is the unaccented letter for each of U+00C0..U+00FF.
+const latin1Base = "AAAAAAACEEEEIIIIDNOOOOO+OUUUUYPbaaaaaaaceeeeiiiionooooo+ouuuuypy"
+
+// AFM widths of ASCII 32..126.
+const (
+	helveticaASCII = `278 278 355 556 556 889 667 191 333 333 389 584 278 333 278 278
+		556 556 556 556 556 556 556 556 556 556 278 278 584 584 584 556
+		1015 667 667 722 722 667 611 778 722 278 500 667 556 833 722 778
+		667 778 722 667 611 722 667 944 667 667 611 278 278 278 469 556
+		333 556 556 500 556 556 278 556 556 222 222 500 222 833 556 556
+		556 556 333 500 278 556 500 722 500 500 500 334 260 334 584`
+	helveticaBoldASCII = `278 333 474 556 556 889 722 238 333 333 389 584 278 333 278 278
+		556 556 556 556 556 556 556 556 556 556 333 333 584 584 584 611
+		975 722 722 722 722 667 611 778 722 278 556 722 611 833 722 778
+		667 778 722 667 611 722 667 944 667 667 611 333 278 333 584 556
+		333 556 611 556 611 556 333 611 611 278 278 556 278 889 611 611
+		611 611 389 556 333 611 556 778 556 556 500 389 280 389 584`
+	timesASCII = `250 333 408 500 500 833 778 180 333 333 500 564 250 333 250 278
+		500 500 500 500 500 500 500 500 500 500 278 278 564 564 564 444
+		921 722 667 667 722 611 556 722 722 333 389 722 611 889 722 722
+		556 722 667 556 611 722 722 944 722 722 611 333 278 333 469 500
+		333 444 500 444 500 444 333 500 500 278 278 500 278 778 500 500
+		500 500 333 389 278 500 500 722 500 500 444 480 200 480 541`
+	timesBoldASCII = `250 333 555 500 500 1000 833 278 333 333 500 570 250 333 250 278
+		500 500 500 500 500 500 500 500 500 500 333 333 570 570 570 500
+		930 722 667 722 722 667 611 778 778 389 500 778 667 944 722 778
+		611 778 722 556 667 722 722 1000 722 722 667 333 278 333 581 500
+		333 500 556 444 556 444 333 500 556 278 333 556 278 833 556 500
+		556 556 444 389 333 556 500 722 500 500 444 394 220 394 520`
+	timesItalicASCII = `250 333 420 500 500 833 778 214 333 333 500 675 250 333 250 278
+		500 500 500 500 500 500 500 500 500 500 333 333 675 675 675 500
+		920 611 611 667 722 611 611 722 722 333 444 667 556 833 667 722
+		611 722 611 500 556 722 611 833 611 556 556 389 278 389 422 500
+		333 500 500 444 500 444 278 500 500 278 278 444 278 722 500 500
+		500 500 389 389 278 500 444 667 444 444 389 400 275 400 541`
+)
+
+// winAnsiSpecials maps the non-Latin-1 characters WinAnsiEncoding has.
+var winAnsiSpecials = map[rune]byte{
+	'€': 128, '‚': 130, '„': 132, '…': 133, '‘': 145, '’': 146, '“': 147, '”': 148,
+	'•': 149, '–': 150, '—': 151, '™': 153,
+}
+
+// UnsupportedCharError reports text the standard fonts have no glyph for.
+// They only cover WinAnsiEncoding, which is Latin-1 plus some punctuation.
+type UnsupportedCharError struct {
+	Char rune
+	Text string // the text the character appeared in
+}
+
+func (e *UnsupportedCharError) Error() string {
+	text := []rune(e.Text)
+	if len(text) > 60 {
+		text = append(text[:57], '.', '.', '.')
+	}
+	return fmt.Sprintf("cannot render %q (U+%04X) in %q: PDF export supports Latin-1 text and common punctuation only", e.Char, e.Char, string(text))
+}
+
+// encodeWinAnsi converts s to WinAnsiEncoding, the encoding the standard fonts
+// are used with. Common bullet and dash look-alikes are mapped to their
+// WinAnsi equivalents. Any other character outside the encoding is returned
+// as an *UnsupportedCharError; the bytes then hold '?' in its place, which is
+// good enough for measuring.
+func encodeWinAnsi(s string) ([]byte, error) {
+	var err error
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7F, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case winAnsiSpecials[r] != 0:
+			out = append(out, winAnsiSpecials[r])
+		case strings.ContainsRune("●▪◦‣∙■□➢►✓", r):
+			out = append(out, 149)
+		case r == '‐' || r == '‑' || r == '−':
+			out = append(out, '-')
+		case r < 0x20:
+			// drop control characters
+		default:
+			if err == nil {
+				err = &UnsupportedCharError{Char: r, Text: s}
+			}
+			out = append(out, '?')
+		}
+	}
+	return out, err
+}
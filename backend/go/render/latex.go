@@ -0,0 +1,206 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"trackify-jobs/models"
+)
+
+// LaTeXBundleContentType is the media type of the zipped LaTeX source.
+const LaTeXBundleContentType = "application/zip"
+
+// latexFonts loads the font packages closest to each family. All ship with
+// TeX Live and MiKTeX.
+var latexFonts = map[string]string{
+	"helvetica": "\\usepackage[scaled]{helvet}\n\\renewcommand{\\familydefault}{\\sfdefault}",
+	"times":     "\\usepackage{mathptmx}",
+	"courier":   "\\usepackage{courier}\n\\renewcommand{\\familydefault}{\\ttdefault}",
+}
+
+// RenderResumeLaTeX returns a zip with resume.tex and a README. The source
+// uses the standard article class and common packages, in the single-column
+// style most LaTeX resume templates share, so it compiles with pdflatex as-is
+// and is easy to edit by hand.
+func RenderResumeLaTeX(r *models.StructuredResume, opts Options) ([]byte, error) {
+	st, err := resolveStyle(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize.Width == 0 {
+		opts.PageSize = PageLetter
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, body string }{
+		{"resume.tex", latexSource(r, st, opts.PageSize)},
+		{"README.md", latexReadme},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func latexSource(r *models.StructuredResume, st style, size PageSize) string {
+	var b strings.Builder
+	w := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+
+	// article only has 10, 11 and 12pt; pick the nearest.
+	pt := min(12, max(10, int(math.Round(st.FontSize))))
+	paper := "letterpaper"
+	if size == PageA4 {
+		paper = "a4paper"
+	}
+
+	w("%% Generated by Trackify from a structured resume.\n")
+	w("\\documentclass[%s,%dpt]{article}\n\n", paper, pt)
+	w("\\usepackage[T1]{fontenc}\n\\usepackage[utf8]{inputenc}\n%s\n", latexFonts[st.Font])
+	w("\\usepackage[margin=%.2fin]{geometry}\n", st.Margin/72)
+	w("\\usepackage{titlesec}\n\\usepackage{enumitem}\n\\usepackage[hidelinks]{hyperref}\n\\usepackage{tabularx}\n\n")
+	w("\\pagestyle{empty}\n\\setlength{\\parindent}{0pt}\n\\linespread{%.2f}\n\n", st.lineHeight/1.2)
+
+	rule := ""
+	if st.headingRule {
+		rule = "\\titlerule"
+	}
+	w("%% Section headings: bold capitals with an optional rule.\n")
+	w("\\titleformat{\\section}{\\large\\bfseries}{}{0pt}{\\MakeUppercase}[%s]\n", rule)
+	w("\\titlespacing*{\\section}{0pt}{%.1fex}{0.6ex}\n\n", 2.2*st.sectionGap)
+
+	w("%% \\entry{left}{right}: an entry line with the date set flush right.\n")
+	if st.datesRight {
+		w("\\newcommand{\\entry}[2]{\\par\\vspace{%.1fex}\\begin{tabularx}{\\textwidth}{@{}X@{\\hspace{1em}}r@{}}#1 & #2\\end{tabularx}\\par}\n", 2*st.entryGap)
+	} else {
+		w("\\newcommand{\\entry}[2]{\\par\\vspace{%.1fex}#1\\ifx&#2&\\else\\ \\textbar\\ #2\\fi\\par}\n", 2*st.entryGap)
+	}
+	w("\\newenvironment{highlights}{\\begin{itemize}[leftmargin=1.5em,itemsep=0pt,topsep=0.3ex,parsep=0pt]}{\\end{itemize}}\n\n")
+
+	w("\\begin{document}\n\n")
+
+	align, endAlign := "", ""
+	if st.centerHeader {
+		align, endAlign = "\\begin{center}\n", "\\end{center}\n"
+	}
+	w("%s{\\LARGE\\bfseries %s}\\\\[0.6ex]\n", align, latexEscape(r.Name))
+	var contact []string
+	if r.Email != "" {
+		contact = append(contact, fmt.Sprintf("\\href{mailto:%s}{%s}", latexURL(r.Email), latexEscape(r.Email)))
+	}
+	if r.Phone != "" {
+		contact = append(contact, latexEscape(r.Phone))
+	}
+	for _, link := range []string{r.LinkedIn, r.GitHub} {
+		if link != "" {
+			contact = append(contact, fmt.Sprintf("\\href{%s}{%s}", latexURL(absoluteURL(link)), latexEscape(displayURL(link))))
+		}
+	}
+	w("%s\n%s\n", strings.Join(contact, " \\quad\\textbar\\quad "), endAlign)
+
+	highlights := func(items []string) {
+		if len(items) == 0 {
+			return
+		}
+		w("\\begin{highlights}\n")
+		for _, item := range items {
+			w("  \\item %s\n", latexEscape(item))
+		}
+		w("\\end{highlights}\n")
+	}
+
+	if s := strings.TrimSpace(r.Summary); s != "" {
+		w("\n\\section{Summary}\n%s\n", latexEscape(s))
+	}
+	if len(r.Experience) > 0 {
+		w("\n\\section{Experience}\n")
+		for _, e := range r.Experience {
+			left := "\\textbf{" + latexEscape(e.Title) + "}"
+			if e.Company != "" {
+				left += ", \\textit{" + latexEscape(e.Company) + "}"
+			}
+			w("\\entry{%s}{%s}\n", left, latexEscape(e.Date))
+			highlights(e.Bullets)
+		}
+	}
+	if len(r.Projects) > 0 {
+		w("\n\\section{Projects}\n")
+		for _, p := range r.Projects {
+			w("\\entry{\\textbf{%s}}{%s}\n", latexEscape(p.Name), latexEscape(p.Date))
+			highlights(p.Bullets)
+		}
+	}
+	if len(r.Education) > 0 {
+		w("\n\\section{Education}\n")
+		for _, e := range r.Education {
+			w("\\entry{\\textbf{%s}}{%s}\n", latexEscape(e.School), latexEscape(e.Date))
+			if e.Degree != "" {
+				w("%s\\par\n", latexEscape(e.Degree))
+			}
+		}
+	}
+	if len(r.Skills) > 0 {
+		w("\n\\section{Skills}\n")
+		categories := make([]string, 0, len(r.Skills))
+		for c := range r.Skills {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		for _, c := range categories {
+			if len(r.Skills[c]) > 0 {
+				w("\\textbf{%s:} %s\\par\n", latexEscape(c), latexEscape(strings.Join(r.Skills[c], ", ")))
+			}
+		}
+	}
+
+	w("\n\\end{document}\n")
+	return b.String()
+}
+
+var latexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`, `_`, `\_`,
+	`{`, `\{`, `}`, `\}`,
+	`~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+	`<`, `\textless{}`, `>`, `\textgreater{}`, `|`, `\textbar{}`,
+	"\u2022", `\textbullet{}`,
+)
+
+// latexEscape makes user text safe to place in LaTeX source.
+func latexEscape(s string) string {
+	return latexReplacer.Replace(s)
+}
+
+// latexURL escapes the characters hyperref cannot take literally in \href.
+func latexURL(s string) string {
+	return strings.NewReplacer(`\`, ``, `%`, `\%`, `#`, `\#`, `{`, ``, `}`, ``).Replace(s)
+}
+
+func absoluteURL(u string) string {
+	if strings.Contains(u, "://") {
+		return u
+	}
+	return "https://" + u
+}
+
+const latexReadme = `# Resume LaTeX source
+
+Build with any TeX distribution (TeX Live, MiKTeX, Overleaf):
+
+    pdflatex resume.tex
+
+resume.tex uses only the article class and standard packages: geometry,
+titlesec, enumitem, hyperref and tabularx. Edit it freely; the \entry command
+sets an entry line with its date on the right.
+`
@@ -0,0 +1,177 @@
+package render
+
+import (
+	"strings"
+)
+
+// run is text set in one font.
+type run struct {
+	font *Font
+	text string
+}
+
+func runsWidth(runs []run, size float64) float64 {
+	w := 0.0
+	for _, r := range runs {
+		w += r.font.Width(r.text, size)
+	}
+	return w
+}
+
+// wrapRuns breaks runs into lines no wider than width, at spaces. A word that
+// does not fit on a line of its own, such as a long URL, is broken between
+// characters. Text that follows a run without a space, like a comma, stays
+// attached to it.
+func wrapRuns(runs []run, size, width float64) [][]run {
+	type word struct {
+		font        *Font
+		text        string
+		spaceBefore bool
+	}
+	var words []word
+	prevSpace := false
+	for _, r := range runs {
+		startsWithSpace := strings.TrimLeft(r.text, " \t\n") != r.text
+		for i, w := range strings.Fields(r.text) {
+			words = append(words, word{r.font, w, i > 0 || startsWithSpace || prevSpace})
+		}
+		prevSpace = strings.TrimRight(r.text, " \t\n") != r.text
+	}
+
+	var lines [][]run
+	var cur []run
+	curWidth := 0.0
+	flush := func() {
+		if len(cur) > 0 {
+			lines = append(lines, cur)
+		}
+		cur, curWidth = nil, 0
+	}
+	add := func(w word) {
+		sep := ""
+		if len(cur) > 0 && w.spaceBefore {
+			sep = " "
+		}
+		ww := w.font.Width(sep+w.text, size)
+		if sep != "" && curWidth+ww > width {
+			flush()
+			sep, ww = "", w.font.Width(w.text, size)
+		}
+		if n := len(cur); n > 0 && cur[n-1].font == w.font {
+			cur[n-1].text += sep + w.text
+		} else {
+			cur = append(cur, run{w.font, sep + w.text})
+		}
+		curWidth += ww
+	}
+
+	for _, w := range words {
+		if w.font.Width(w.text, size) <= width {
+			add(w)
+			continue
+		}
+		flush()
+		chunk := ""
+		for _, c := range w.text {
+			if chunk != "" && w.font.Width(chunk+string(c), size) > width {
+				add(word{w.font, chunk, true})
+				flush()
+				chunk = ""
+			}
+			chunk += string(c)
+		}
+		add(word{w.font, chunk, true})
+	}
+	flush()
+	return lines
+}
+
+// pageLayout places lines top to bottom and starts a new page when the next
+// line would cross the bottom margin.
+type pageLayout struct {
+	doc    *pdfDocument
+	margin float64
+	y      float64 // top of the next line
+}
+
+func newPageLayout(size PageSize, margin float64) *pageLayout {
+	l := &pageLayout{doc: newPDFDocument(size), margin: margin}
+	l.newPage()
+	return l
+}
+
+func (l *pageLayout) newPage() {
+	l.doc.addPage()
+	l.y = l.doc.size.Height - l.margin
+}
+
+func (l *pageLayout) left() float64  { return l.margin }
+func (l *pageLayout) right() float64 { return l.doc.size.Width - l.margin }
+func (l *pageLayout) width() float64 { return l.right() - l.left() }
+
+// ensure starts a new page unless height points fit above the bottom margin.
+func (l *pageLayout) ensure(height float64) {
+	if l.y-height < l.margin && l.y < l.doc.size.Height-l.margin {
+		l.newPage()
+	}
+}
+
+// space moves down by h points; at the top of a page it does nothing.
+func (l *pageLayout) space(h float64) {
+	if l.y < l.doc.size.Height-l.margin {
+		l.y -= h
+	}
+}
+
+// drawLine sets one line of runs starting at x and returns its baseline.
+func (l *pageLayout) drawLine(runs []run, size, lineHeight, x float64) float64 {
+	l.ensure(size * lineHeight)
+	baseline := l.y - size*0.8 - (lineHeight-1)*size/2
+	for _, r := range runs {
+		l.doc.text(r.font, size, x, baseline, r.text)
+		x += r.font.Width(r.text, size)
+	}
+	l.y -= size * lineHeight
+	return baseline
+}
+
+// paragraph wraps runs between x and the right margin.
+func (l *pageLayout) paragraph(runs []run, size, lineHeight, x float64) {
+	for _, line := range wrapRuns(runs, size, l.right()-x) {
+		l.drawLine(line, size, lineHeight, x)
+	}
+}
+
+// centered wraps runs and centres each line between the margins.
+func (l *pageLayout) centered(runs []run, size, lineHeight float64) {
+	for _, line := range wrapRuns(runs, size, l.width()) {
+		l.drawLine(line, size, lineHeight, l.left()+(l.width()-runsWidth(line, size))/2)
+	}
+}
+
+// withRight sets runs on the left and right on the same first line, flush
+// with the right margin, wrapping the left side in the space that remains.
+func (l *pageLayout) withRight(runs []run, right run, size, lineHeight float64) {
+	rightWidth := 0.0
+	if right.text != "" {
+		rightWidth = right.font.Width(right.text, size) + size
+	}
+	lines := wrapRuns(runs, size, l.width()-rightWidth)
+	if len(lines) == 0 {
+		lines = [][]run{nil}
+	}
+	// Do not leave the first line of an entry alone at the bottom of a page.
+	l.ensure(size * lineHeight * float64(min(len(lines), 2)))
+	for i, line := range lines {
+		baseline := l.drawLine(line, size, lineHeight, l.left())
+		if i == 0 && right.text != "" {
+			l.doc.text(right.font, size, l.right()-right.font.Width(right.text, size), baseline, right.text)
+		}
+	}
+}
+
+// rule draws a horizontal line across the text width.
+func (l *pageLayout) rule(width, gap float64) {
+	l.doc.line(l.left(), l.y-gap/2, l.right(), l.y-gap/2, width)
+	l.y -= gap
+}
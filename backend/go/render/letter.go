@@ -0,0 +1,192 @@
+package render
+
+import (
+	"strings"
+)
+
+// letterParagraphGap is the space after a body paragraph, in body lines.
+const letterParagraphGap = 0.75
+
+// Letter is a cover letter set under the same letterhead as the resume
+// templates. Body paragraphs are separated by blank lines; single line
+// breaks are kept, so a sign-off like "Sincerely,\nJane" stays on two lines.
+type Letter struct {
+	Name     string
+	Email    string
+	Phone    string
+	LinkedIn string
+	GitHub   string
+
+	Date      string
+	Recipient []string // one line each, e.g. hiring manager, company, location
+	Subject   string   // e.g. "Re: Backend Engineer"
+	Body      string
+}
+
+func (lt *Letter) contact() string {
+	return contactLine(lt.Email, lt.Phone, lt.LinkedIn, lt.GitHub)
+}
+
+func (lt *Letter) hasLetterhead() bool {
+	return strings.TrimSpace(lt.Name) != "" || lt.contact() != ""
+}
+
+// paragraphs splits the body at blank lines into paragraphs of lines.
+func (lt *Letter) paragraphs() [][]string {
+	body := strings.ReplaceAll(strings.ReplaceAll(lt.Body, "\r\n", "\n"), "\r", "\n")
+	var paras [][]string
+	var cur []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if cur != nil {
+				paras = append(paras, cur)
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if cur != nil {
+		paras = append(paras, cur)
+	}
+	return paras
+}
+
+func (lt *Letter) recipient() []string {
+	var lines []string
+	for _, line := range lt.Recipient {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// RenderLetter lays out a cover letter as a PDF. FitOnePage tightens the
+// template the same way it does for resumes.
+func RenderLetter(lt *Letter, opts Options) (*Result, error) {
+	st, err := resolveStyle(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize.Width == 0 {
+		opts.PageSize = PageLetter
+	}
+
+	l := layoutLetter(lt, st, opts.PageSize)
+	fitted := false
+	for opts.FitOnePage && len(l.doc.pages) > 1 {
+		next, ok := st.tighten()
+		if !ok {
+			break
+		}
+		st = next
+		l = layoutLetter(lt, st, opts.PageSize)
+		fitted = len(l.doc.pages) == 1
+	}
+
+	meta := Metadata{Title: "Cover Letter", Author: lt.Name, Subject: "Cover Letter"}
+	if lt.Name != "" {
+		meta.Title = lt.Name + " - Cover Letter"
+	}
+	pdf, err := l.doc.bytes(meta)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		PDF:      pdf,
+		Pages:    len(l.doc.pages),
+		FontSize: st.FontSize,
+		Margin:   st.Margin,
+		Fitted:   fitted,
+	}, nil
+}
+
+func layoutLetter(lt *Letter, st style, size PageSize) *pageLayout {
+	l := newPageLayout(size, st.Margin)
+	fs, lh := st.FontSize, st.lineHeight
+	reg, bold := st.family.Regular, st.family.Bold
+	gap := fs * lh * letterParagraphGap * st.gapScale
+
+	if lt.hasLetterhead() {
+		layoutHeader(l, st, lt.Name, lt.contact())
+		if st.headingRule {
+			l.rule(0.6, 4)
+		}
+		l.space(fs * st.sectionGap * 2 * st.gapScale)
+	}
+
+	if lt.Date != "" {
+		l.paragraph([]run{{reg, lt.Date}}, fs, lh, l.left())
+		l.space(gap)
+	}
+	if lines := lt.recipient(); len(lines) > 0 {
+		for _, line := range lines {
+			l.paragraph([]run{{reg, line}}, fs, lh, l.left())
+		}
+		l.space(gap)
+	}
+	if s := strings.TrimSpace(lt.Subject); s != "" {
+		l.paragraph([]run{{bold, s}}, fs, lh, l.left())
+		l.space(gap)
+	}
+	for i, para := range lt.paragraphs() {
+		if i > 0 {
+			l.space(gap)
+		}
+		for _, line := range para {
+			l.paragraph([]run{{reg, line}}, fs, lh, l.left())
+		}
+	}
+	return l
+}
+
+// RenderLetterDOCX writes the cover letter as a Word document with the same
+// template settings as the PDF.
+func RenderLetterDOCX(lt *Letter, opts Options) ([]byte, error) {
+	st, err := resolveStyle(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize.Width == 0 {
+		opts.PageSize = PageLetter
+	}
+
+	d := &docxBody{style: st}
+	d.writeLetter(lt)
+	return d.pack(opts.PageSize, strings.TrimSpace(lt.Name+" Cover Letter"))
+}
+
+func (d *docxBody) writeLetter(lt *Letter) {
+	st := d.style
+
+	if lt.hasLetterhead() {
+		d.para("Title", st.centerHeader, 0, docxRun{text: lt.Name})
+		d.para("Letterhead", st.centerHeader, 0, docxRun{text: lt.contact()})
+	}
+	if lt.Date != "" {
+		d.para("LetterBody", false, 0, docxRun{text: lt.Date})
+	}
+	if lines := lt.recipient(); len(lines) > 0 {
+		d.para("LetterBody", false, 0, lineRuns(lines)...)
+	}
+	if s := strings.TrimSpace(lt.Subject); s != "" {
+		d.para("LetterBody", false, 0, docxRun{text: s, bold: true})
+	}
+	for _, para := range lt.paragraphs() {
+		d.para("LetterBody", false, 0, lineRuns(para)...)
+	}
+}
+
+// lineRuns joins lines with line breaks so they stay in one paragraph.
+func lineRuns(lines []string) []docxRun {
+	runs := make([]docxRun, 0, 2*len(lines))
+	for i, line := range lines {
+		if i > 0 {
+			runs = append(runs, docxRun{br: true})
+		}
+		runs = append(runs, docxRun{text: line})
+	}
+	return runs
+}
@@ -0,0 +1,200 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes in points.
+var (
+	PageLetter = PageSize{Width: 612, Height: 792}
+	PageA4     = PageSize{Width: 595.28, Height: 841.89}
+)
+
+type PageSize struct {
+	Width, Height float64
+}
+
+// pdfDocument writes a minimal PDF 1.4 file: text in standard fonts and
+// stroked lines, one compressed content stream per page.
+type pdfDocument struct {
+	size  PageSize
+	pages []*bytes.Buffer
+	fonts map[string]*Font
+	err   error // first text that could not be encoded
+}
+
+func newPDFDocument(size PageSize) *pdfDocument {
+	return &pdfDocument{size: size, fonts: map[string]*Font{}}
+}
+
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// text draws s with its baseline at (x, y), measured from the bottom-left.
+func (d *pdfDocument) text(font *Font, size, x, y float64, s string) {
+	d.fonts[font.Name] = font
+	encoded, err := encodeWinAnsi(s)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		fontKey(font.Name), num(size), num(x), num(y), escapePDFString(encoded))
+}
+
+func (d *pdfDocument) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// fontKey is the resource name of a font. Keys are fixed per font so content
+// streams can be written before the full set of fonts is known.
+func fontKey(name string) string {
+	for i, n := range standardFonts {
+		if n == name {
+			return "F" + strconv.Itoa(i+1)
+		}
+	}
+	panic("render: not a standard font: " + name)
+}
+
+var standardFonts = []string{
+	"Helvetica", "Helvetica-Bold", "Helvetica-Oblique",
+	"Times-Roman", "Times-Bold", "Times-Italic",
+	"Courier", "Courier-Bold", "Courier-Oblique",
+}
+
+// Metadata is written to the document information dictionary.
+type Metadata struct {
+	Title   string
+	Author  string
+	Subject string
+}
+
+// bytes serialises the document. It fails if any text, including the
+// metadata, has characters the standard fonts cannot show.
+func (d *pdfDocument) bytes(meta Metadata) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	title, err := encodeWinAnsi(meta.Title)
+	if err != nil {
+		return nil, err
+	}
+	author, err := encodeWinAnsi(meta.Author)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := encodeWinAnsi(meta.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+	stream := func(data []byte) int {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and page tree. They are written at the
+	// end, once the page objects are known, so only their numbers are reserved.
+	catalogNum, pagesNum := 1, 2
+	offsets = append(offsets, 0, 0)
+
+	names := make([]string, 0, len(d.fonts))
+	for name := range d.fonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var fontRefs bytes.Buffer
+	for _, name := range names {
+		// Widths are optional for the standard fonts, but text extractors
+		// (and so ATS parsers) need them to place characters and spaces.
+		var widths bytes.Buffer
+		for _, w := range d.fonts[name].widths[32:] {
+			fmt.Fprintf(&widths, "%d ", w)
+		}
+		ref := object(fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding /FirstChar 32 /LastChar 255 /Widths [%s] >>",
+			name, strings.TrimSpace(widths.String())))
+		fmt.Fprintf(&fontRefs, "/%s %d 0 R ", fontKey(name), ref)
+	}
+
+	var kids bytes.Buffer
+	for _, content := range d.pages {
+		contentNum := stream(content.Bytes())
+		pageNum := object(fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> >> /Contents %d 0 R >>",
+			pagesNum, num(d.size.Width), num(d.size.Height), fontRefs.String(), contentNum))
+		fmt.Fprintf(&kids, "%d 0 R ", pageNum)
+	}
+
+	infoNum := object(fmt.Sprintf("<< /Title (%s) /Author (%s) /Subject (%s) /Producer (Trackify) /CreationDate (D:%s) >>",
+		escapePDFString(title), escapePDFString(author),
+		escapePDFString(subject), time.Now().UTC().Format("20060102150405Z")))
+
+	// Fill in the reserved catalog and page tree.
+	offsets[catalogNum-1] = out.Len()
+	fmt.Fprintf(&out, "%d 0 obj\n<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", catalogNum, pagesNum)
+	offsets[pagesNum-1] = out.Len()
+	fmt.Fprintf(&out, "%d 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", pagesNum, kids.String(), len(d.pages))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, catalogNum, infoNum, xref)
+	return out.Bytes(), nil
+}
+
+func escapePDFString(b []byte) string {
+	var out bytes.Buffer
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// num formats a coordinate with at most two decimals.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,327 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"trackify-jobs/models"
+)
+
+var (
+	ErrUnknownTemplate = errors.New("unknown template")
+	ErrUnknownFont     = errors.New("unknown font")
+)
+
+// Bounds for caller-supplied sizes and for one-page fitting.
+const (
+	minFontSize = 8.5
+	maxFontSize = 14
+	minMargin   = 30
+	maxMargin   = 90
+)
+
+// Template is a single-column resume layout. Every template keeps to what ATS
+// parsers read reliably: one column, no tables or images, standard fonts,
+// and section headings they recognise.
+type Template struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Font        string  `json:"font"`
+	FontSize    float64 `json:"font_size"`
+	Margin      float64 `json:"margin"`
+
+	nameScale    float64 // name size relative to FontSize
+	headingScale float64
+	lineHeight   float64 // line advance relative to font size
+	sectionGap   float64 // space before a section, in body lines
+	entryGap     float64 // space between entries, in body lines
+	centerHeader bool
+	headingRule  bool
+	datesRight   bool // dates flush right on the entry line rather than inline
+}
+
+var templates = map[string]Template{
+	"classic": {
+		Name: "classic", Description: "Serif, centred header, ruled section headings",
+		Font: "times", FontSize: 11, Margin: 54,
+		nameScale: 2, headingScale: 1.1, lineHeight: 1.25, sectionGap: 0.9, entryGap: 0.45,
+		centerHeader: true, headingRule: true, datesRight: true,
+	},
+	"modern": {
+		Name: "modern", Description: "Sans-serif, left-aligned header, ruled section headings",
+		Font: "helvetica", FontSize: 10.5, Margin: 50,
+		nameScale: 2.1, headingScale: 1.1, lineHeight: 1.3, sectionGap: 1, entryGap: 0.5,
+		headingRule: true, datesRight: true,
+	},
+	"compact": {
+		Name: "compact", Description: "Dense sans-serif layout for long histories",
+		Font: "helvetica", FontSize: 9.5, Margin: 40,
+		nameScale: 1.8, headingScale: 1.05, lineHeight: 1.2, sectionGap: 0.7, entryGap: 0.3,
+	},
+}
+
+// DefaultTemplate is used when no template is requested.
+const DefaultTemplate = "modern"
+
+// Templates lists the available templates by name.
+func Templates() []Template {
+	out := make([]Template, 0, len(templates))
+	for _, t := range templates {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Options override a template. Zero values keep the template's settings.
+type Options struct {
+	Template   string
+	Font       string
+	FontSize   float64
+	Margin     float64
+	PageSize   PageSize
+	FitOnePage bool // tighten spacing, then margins, then type size to fit one page
+}
+
+// Result is a rendered PDF and the settings it ended up with.
+type Result struct {
+	PDF      []byte
+	Pages    int
+	FontSize float64
+	Margin   float64
+	Fitted   bool // true when tightening was needed and it fit on one page
+}
+
+// style is a template resolved against the options; fitting adjusts it.
+type style struct {
+	Template
+	family   FontFamily
+	gapScale float64
+}
+
+func resolveStyle(opts Options) (style, error) {
+	name := opts.Template
+	if name == "" {
+		name = DefaultTemplate
+	}
+	t, ok := templates[name]
+	if !ok {
+		return style{}, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+	}
+	if opts.Font != "" {
+		t.Font = strings.ToLower(opts.Font)
+	}
+	family, ok := fontFamilies[t.Font]
+	if !ok {
+		return style{}, fmt.Errorf("%w: %q", ErrUnknownFont, t.Font)
+	}
+	if opts.FontSize != 0 {
+		t.FontSize = clamp(opts.FontSize, minFontSize, maxFontSize)
+	}
+	if opts.Margin != 0 {
+		t.Margin = clamp(opts.Margin, minMargin, maxMargin)
+	}
+	return style{Template: t, family: family, gapScale: 1}, nil
+}
+
+// tighten returns the next, denser style to try, or false once nothing is
+// left to give: first the gaps between sections, then line spacing, then the
+// margins and finally the type size.
+func (s style) tighten() (style, bool) {
+	switch {
+	case s.gapScale > 0.5:
+		s.gapScale -= 0.25
+	case s.lineHeight > 1.12:
+		s.lineHeight -= 0.04
+	case s.Margin > 36:
+		s.Margin = max(36, s.Margin-6)
+	case s.FontSize > minFontSize:
+		s.FontSize = max(minFontSize, s.FontSize-0.25)
+	default:
+		return s, false
+	}
+	return s, true
+}
+
+// RenderResume lays out a structured resume as a PDF with real text.
+func RenderResume(r *models.StructuredResume, opts Options) (*Result, error) {
+	st, err := resolveStyle(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize.Width == 0 {
+		opts.PageSize = PageLetter
+	}
+
+	l := layoutResume(r, st, opts.PageSize)
+	fitted := false
+	for opts.FitOnePage && len(l.doc.pages) > 1 {
+		next, ok := st.tighten()
+		if !ok {
+			break
+		}
+		st = next
+		l = layoutResume(r, st, opts.PageSize)
+		fitted = len(l.doc.pages) == 1
+	}
+
+	meta := Metadata{Title: "Resume", Author: r.Name, Subject: "Resume"}
+	if r.Name != "" {
+		meta.Title = r.Name + " - Resume"
+	}
+	pdf, err := l.doc.bytes(meta)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		PDF:      pdf,
+		Pages:    len(l.doc.pages),
+		FontSize: st.FontSize,
+		Margin:   st.Margin,
+		Fitted:   fitted,
+	}, nil
+}
+
+func layoutResume(r *models.StructuredResume, st style, size PageSize) *pageLayout {
+	l := newPageLayout(size, st.Margin)
+	fs, lh := st.FontSize, st.lineHeight
+	reg, bold, ital := st.family.Regular, st.family.Bold, st.family.Italic
+
+	layoutHeader(l, st, r.Name, contactLine(r.Email, r.Phone, r.LinkedIn, r.GitHub))
+
+	heading := func(title string) {
+		hs := fs * st.headingScale
+		l.space(fs * st.sectionGap * st.gapScale)
+		// Keep a heading with at least two lines of its section.
+		l.ensure(hs*lh + 2*fs*lh + 4)
+		l.paragraph([]run{{bold, strings.ToUpper(title)}}, hs, lh, l.left())
+		if st.headingRule {
+			l.rule(0.6, 4)
+		}
+	}
+	entryGap := func(i int) {
+		if i > 0 {
+			l.space(fs * st.entryGap * st.gapScale)
+		}
+	}
+	bullets := func(items []string) {
+		indent := l.left() + fs
+		textX := indent + fs
+		for _, b := range items {
+			lines := wrapRuns([]run{{reg, b}}, fs, l.right()-textX)
+			for i, line := range lines {
+				if i == 0 {
+					l.ensure(fs * lh)
+					l.doc.text(reg, fs, indent, l.y-fs*0.8-(lh-1)*fs/2, "•")
+				}
+				l.drawLine(line, fs, lh, textX)
+			}
+		}
+	}
+	entry := func(main []run, date string) {
+		if st.datesRight {
+			l.withRight(main, run{reg, date}, fs, lh)
+			return
+		}
+		if date != "" {
+			main = append(main, run{reg, "  |  " + date})
+		}
+		l.paragraph(main, fs, lh, l.left())
+	}
+
+	if s := strings.TrimSpace(r.Summary); s != "" {
+		heading("Summary")
+		l.paragraph([]run{{reg, s}}, fs, lh, l.left())
+	}
+
+	if len(r.Experience) > 0 {
+		heading("Experience")
+		for i, e := range r.Experience {
+			entryGap(i)
+			main := []run{{bold, e.Title}}
+			if e.Company != "" {
+				if e.Title != "" {
+					main = append(main, run{reg, ", "})
+				}
+				main = append(main, run{ital, e.Company})
+			}
+			entry(main, e.Date)
+			bullets(e.Bullets)
+		}
+	}
+
+	if len(r.Projects) > 0 {
+		heading("Projects")
+		for i, p := range r.Projects {
+			entryGap(i)
+			entry([]run{{bold, p.Name}}, p.Date)
+			bullets(p.Bullets)
+		}
+	}
+
+	if len(r.Education) > 0 {
+		heading("Education")
+		for i, e := range r.Education {
+			entryGap(i)
+			entry([]run{{bold, e.School}}, e.Date)
+			if e.Degree != "" {
+				l.paragraph([]run{{reg, e.Degree}}, fs, lh, l.left())
+			}
+		}
+	}
+
+	if len(r.Skills) > 0 {
+		heading("Skills")
+		categories := make([]string, 0, len(r.Skills))
+		for c := range r.Skills {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		for _, c := range categories {
+			if len(r.Skills[c]) == 0 {
+				continue
+			}
+			l.paragraph([]run{{bold, c + ":"}, {reg, " " + strings.Join(r.Skills[c], ", ")}}, fs, lh, l.left())
+		}
+	}
+	return l
+}
+
+// layoutHeader sets the name and contact line, centred or flush left as the
+// template has it. Resumes and cover letters share it.
+func layoutHeader(l *pageLayout, st style, name, contact string) {
+	fs, lh := st.FontSize, st.lineHeight
+	nameRuns := []run{{st.family.Bold, name}}
+	contactRuns := []run{{st.family.Regular, contact}}
+	if st.centerHeader {
+		l.centered(nameRuns, fs*st.nameScale, 1.15)
+		l.centered(contactRuns, fs, lh)
+	} else {
+		l.paragraph(nameRuns, fs*st.nameScale, 1.15, l.left())
+		l.paragraph(contactRuns, fs, lh, l.left())
+	}
+}
+
+func contactLine(email, phone, linkedIn, gitHub string) string {
+	return joinNonEmpty("  |  ", email, phone, displayURL(linkedIn), displayURL(gitHub))
+}
+
+func displayURL(u string) string {
+	u = strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	return strings.TrimSuffix(strings.TrimPrefix(u, "www."), "/")
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"trackify-jobs/models"
+	"trackify-jobs/render"
+)
+
+// CoverLetterExportOptions choose where the letterhead and recipient block
+// come from. ResumeID picks the resume whose contact details head the
+// letter; empty means the most recently updated resume that can be parsed.
+// Recipient, e.g. the hiring manager's name, goes above the job's company.
+type CoverLetterExportOptions struct {
+	Format    string
+	ResumeID  string
+	Recipient string
+	Render    render.Options
+}
+
+// ExportCoverLetter renders a letter as a PDF or Word document with the
+// user's contact details as a letterhead, today's date, and a recipient
+// block and subject line taken from the linked job.
+func (s *DocumentService) ExportCoverLetter(ctx context.Context, publicID, userID string, opts CoverLetterExportOptions) (*ResumeExport, *models.CoverLetter, error) {
+	if opts.Format != ExportPDF && opts.Format != ExportDOCX {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedExport, opts.Format)
+	}
+	letter, err := s.DB.GetCoverLetterByIDAndUser(publicID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc := &render.Letter{
+		Date:      time.Now().Format("January 2, 2006"),
+		Recipient: []string{opts.Recipient},
+		Body:      letter.Content,
+	}
+	sender, err := s.letterhead(ctx, userID, opts.ResumeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sender != nil {
+		doc.Name, doc.Email, doc.Phone = sender.Name, sender.Email, sender.Phone
+		doc.LinkedIn, doc.GitHub = sender.LinkedIn, sender.GitHub
+	}
+	if letter.JobID != nil {
+		job, err := s.DB.GetJobByID(*letter.JobID, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc.Recipient = append(doc.Recipient, job.Company, job.Location)
+		if job.Title != "" {
+			doc.Subject = "Re: " + job.Title
+		}
+	}
+
+	if opts.Format == ExportDOCX {
+		out, err := render.RenderLetterDOCX(doc, opts.Render)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &ResumeExport{Data: out, ContentType: render.DOCXContentType, Extension: ".docx"}, letter, nil
+	}
+	result, err := render.RenderLetter(doc, opts.Render)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ResumeExport{Data: result.PDF, ContentType: "application/pdf", Extension: ".pdf"}, letter, nil
+}
+
+// letterhead returns the contact details for a letter: from the named resume,
+// or else from the newest resume with structured data. A user without a
+// usable resume gets no letterhead rather than an error.
+func (s *DocumentService) letterhead(ctx context.Context, userID, resumeID string) (*models.StructuredResume, error) {
+	if resumeID != "" {
+		resume, err := s.DB.GetResumeByID(resumeID, userID)
+		if err != nil {
+			return nil, err
+		}
+		structured, err := s.StructuredResume(ctx, resume)
+		if err != nil {
+			return nil, err
+		}
+		return &structured.Data, nil
+	}
+
+	resumes, err := s.DB.GetResumesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resumes {
+		structured, err := s.StructuredResume(ctx, &resumes[i])
+		if errors.Is(err, ErrNoResumeText) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &structured.Data, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"trackify-jobs/models"
+)
+
+var ErrInvalidTemplate = errors.New("invalid cover letter template")
+
+// UnresolvedPlaceholdersError lists placeholders nothing supplied a value for.
+type UnresolvedPlaceholdersError struct {
+	Missing []string
+}
+
+func (e *UnresolvedPlaceholdersError) Error() string {
+	return "unresolved placeholders: " + strings.Join(e.Missing, ", ")
+}
+
+const maxTemplateBody = 20000
+
+var (
+	placeholderRe     = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+	placeholderNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// TemplatePlaceholders returns the distinct placeholder names in body in
+// order of first use. Names are lowercase letters, digits and underscores;
+// anything else between braces, or braces left open, is an error.
+func TemplatePlaceholders(body string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(body, -1) {
+		name := m[1]
+		if !placeholderNameRe.MatchString(name) {
+			return nil, fmt.Errorf("%w: %q is not a valid placeholder name", ErrInvalidTemplate, m[0])
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if rest := placeholderRe.ReplaceAllString(body, ""); strings.Contains(rest, "{{") {
+		return nil, fmt.Errorf("%w: a placeholder is missing its closing }}", ErrInvalidTemplate)
+	}
+	return names, nil
+}
+
+// jobPlaceholderValues are the values a tracked job supplies. hiring_manager
+// and custom fields come from the request or the template's defaults.
+func jobPlaceholderValues(job *models.Job, now time.Time) map[string]string {
+	values := map[string]string{"date": now.Format("January 2, 2006")}
+	if job != nil {
+		values["company"] = job.Company
+		values["role"] = job.Title
+		values["location"] = job.Location
+		values["job_url"] = job.URL
+	}
+	return values
+}
+
+// FillCoverLetterTemplate substitutes values into body. Placeholders without
+// a non-empty value are left as written and returned in missing.
+func FillCoverLetterTemplate(body string, values map[string]string) (content string, missing []string) {
+	seen := map[string]bool{}
+	content = placeholderRe.ReplaceAllStringFunc(body, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if v := strings.TrimSpace(values[name]); v != "" {
+			return v
+		}
+		if !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+		return m
+	})
+	return content, missing
+}
+
+func (s *DocumentService) validateTemplate(t *models.CoverLetterTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Tone = strings.ToLower(strings.TrimSpace(t.Tone))
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	case strings.TrimSpace(t.Body) == "":
+		return fmt.Errorf("%w: body is required", ErrInvalidTemplate)
+	case len(t.Body) > maxTemplateBody:
+		return fmt.Errorf("%w: body is longer than %d characters", ErrInvalidTemplate, maxTemplateBody)
+	case !coverLetterTones[t.Tone]:
+		return fmt.Errorf("%w: unknown tone %q", ErrInvalidTemplate, t.Tone)
+	}
+	if t.Defaults == nil {
+		t.Defaults = map[string]string{}
+	}
+	for name := range t.Defaults {
+		if !placeholderNameRe.MatchString(name) {
+			return fmt.Errorf("%w: %q is not a valid placeholder name", ErrInvalidTemplate, name)
+		}
+	}
+	var err error
+	t.Placeholders, err = TemplatePlaceholders(t.Body)
+	return err
+}
+
+// withPlaceholders fills in the derived placeholder list. Stored bodies were
+// validated on save, so a parse error here leaves the list empty.
+func withPlaceholders(t *models.CoverLetterTemplate) *models.CoverLetterTemplate {
+	t.Placeholders, _ = TemplatePlaceholders(t.Body)
+	if t.Placeholders == nil {
+		t.Placeholders = []string{}
+	}
+	return t
+}
+
+func (s *DocumentService) CreateCoverLetterTemplate(userID string, t models.CoverLetterTemplate) (*models.CoverLetterTemplate, error) {
+	t.UserID = userID
+	if err := s.validateTemplate(&t); err != nil {
+		return nil, err
+	}
+	created, err := s.DB.CreateCoverLetterTemplate(&t)
+	if err != nil {
+		return nil, err
+	}
+	return withPlaceholders(created), nil
+}
+
+// UpdateCoverLetterTemplate replaces a template's name, body, tone and defaults.
+func (s *DocumentService) UpdateCoverLetterTemplate(publicID, userID string, t models.CoverLetterTemplate) (*models.CoverLetterTemplate, error) {
+	t.PublicID, t.UserID = publicID, userID
+	if err := s.validateTemplate(&t); err != nil {
+		return nil, err
+	}
+	updated, err := s.DB.UpdateCoverLetterTemplate(&t)
+	if err != nil {
+		return nil, err
+	}
+	return withPlaceholders(updated), nil
+}
+
+func (s *DocumentService) GetCoverLetterTemplates(userID string) ([]models.CoverLetterTemplate, error) {
+	templates, err := s.DB.GetCoverLetterTemplates(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		withPlaceholders(&templates[i])
+	}
+	return templates, nil
+}
+
+func (s *DocumentService) GetCoverLetterTemplate(publicID, userID string) (*models.CoverLetterTemplate, error) {
+	t, err := s.DB.GetCoverLetterTemplate(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return withPlaceholders(t), nil
+}
+
+func (s *DocumentService) DeleteCoverLetterTemplate(publicID, userID string) error {
+	return s.DB.DeleteCoverLetterTemplate(publicID, userID)
+}
+
+// PreviewCoverLetterTemplate fills in a template for a job without saving
+// anything. Values are taken from the request first, then the job (jobID 0
+// for none), then the template's defaults.
+func (s *DocumentService) PreviewCoverLetterTemplate(publicID, userID string, jobID int, values map[string]string) (*models.CoverLetterPreview, *models.CoverLetterTemplate, *models.Job, error) {
+	t, err := s.DB.GetCoverLetterTemplate(publicID, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var job *models.Job
+	if jobID != 0 {
+		job, err = s.DB.GetJobByID(jobID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil, fmt.Errorf("%w: job %d not found", ErrInvalidTemplate, jobID)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	resolved := map[string]string{}
+	for _, layer := range []map[string]string{t.Defaults, jobPlaceholderValues(job, time.Now()), values} {
+		for k, v := range layer {
+			if strings.TrimSpace(v) != "" {
+				resolved[k] = v
+			}
+		}
+	}
+
+	preview := &models.CoverLetterPreview{Values: map[string]string{}, Missing: []string{}}
+	var missing []string
+	preview.Content, missing = FillCoverLetterTemplate(t.Body, resolved)
+	if missing != nil {
+		preview.Missing = missing
+	}
+	names, _ := TemplatePlaceholders(t.Body)
+	for _, name := range names {
+		if v, ok := resolved[name]; ok {
+			preview.Values[name] = v
+		}
+	}
+	return preview, t, job, nil
+}
+
+// CreateCoverLetterFromTemplate fills in a template for a job and saves the
+// result as a new cover letter linked to the job. Every placeholder must
+// resolve; otherwise an *UnresolvedPlaceholdersError lists the missing ones.
+func (s *DocumentService) CreateCoverLetterFromTemplate(publicID, userID string, jobID int, values map[string]string, title string) (*models.CoverLetter, error) {
+	preview, t, job, err := s.PreviewCoverLetterTemplate(publicID, userID, jobID, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(preview.Missing) > 0 {
+		missing := append([]string(nil), preview.Missing...)
+		sort.Strings(missing)
+		return nil, &UnresolvedPlaceholdersError{Missing: missing}
+	}
+
+	letter := models.CoverLetter{
+		Title:   strings.TrimSpace(title),
+		Tone:    t.Tone,
+		Source:  models.CoverLetterHandwritten,
+		Content: preview.Content,
+	}
+	if job != nil {
+		letter.JobID = &job.ID
+		if letter.Title == "" {
+			letter.Title = fmt.Sprintf("%s at %s", job.Title, job.Company)
+		}
+	}
+	if letter.Title == "" {
+		letter.Title = t.Name
+	}
+	return s.CreateCoverLetter(userID, letter)
+}
@@ -1,61 +1,882 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 	"trackify-jobs/database"
 	"trackify-jobs/models"
+	"trackify-jobs/render"
+	"trackify-jobs/scanner"
+	"trackify-jobs/storage"
+)
+
+// downloadURLTTL is how long a signed download link stays valid.
+const downloadURLTTL = 5 * time.Minute
+
+// maxResumeBytes bounds how much of a stored resume is read back from storage.
+const maxResumeBytes = 20 << 20
+
+var (
+	ErrExpiredToken = errors.New("token has expired")
+	ErrNoDiffBase   = errors.New("resume has no parent version to compare against")
+	ErrNoResumeText = errors.New("no text could be extracted from this resume")
+	ErrUserEdited   = errors.New("structured resume was edited by the user")
+
+	ErrInvalidCoverLetter = errors.New("invalid cover letter")
+)
+
+// repairThreshold is the parse confidence below which a field is sent to the
+// LLM repair pass; repairedConfidence is what a repaired field is scored.
+const (
+	repairThreshold    = 0.7
+	repairedConfidence = 0.75
 )
 
 type DocumentService struct {
-	DB           *database.PostgresDB
-	ResumeFolder string
+	DB        *database.PostgresDB
+	Blobs     storage.BlobStore
+	Scanner   scanner.Scanner // Malware scan of stored files before they are served
+	LLM       *LLMService     // Optional repair pass for structured parses
+	Downloads *TokenSigner    // Signs short-lived download links
+	PublicURL string          // Base URL of this API, used to build download links
+
+	scanning sync.Map // storage keys with a scan in progress
+}
+
+func NewDocumentService(db *database.PostgresDB, blobs storage.BlobStore, scan scanner.Scanner, llm *LLMService) *DocumentService {
+	return &DocumentService{
+		DB:        db,
+		Blobs:     blobs,
+		Scanner:   scan,
+		LLM:       llm,
+		Downloads: NewTokenSigner(os.Getenv("DOWNLOAD_URL_SECRET")),
+		PublicURL: strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"),
+	}
 }
 
-func NewDocumentService(db *database.PostgresDB, folder string) *DocumentService {
-	return &DocumentService{DB: db, ResumeFolder: folder}
+// DownloadClaims identify one document for one owner until Expires (unix seconds).
+type DownloadClaims struct {
+	Kind     string `json:"k"`
+	PublicID string `json:"id"`
+	UserID   string `json:"uid"`
+	Expires  int64  `json:"exp"`
 }
 
 //
 // Resume logic
 //
 
-func (s *DocumentService) CreateResume(userID, filename string) (*models.Resume, error) {
-	return s.DB.CreateResume(&models.Resume{
-		UserID:   userID,
-		Filename: filename,
+// ResumeUpload describes a new resume version. DocumentID and ParentID are
+// public IDs; with neither set the upload starts a new document.
+type ResumeUpload struct {
+	Filename    string
+	ContentType string // kept only for Structured versions; uploads are sniffed
+	File        io.Reader
+	DocumentID  string // add a version to this document
+	ParentID    string // version this one was derived from, e.g. for a tailored variant
+	Label       string
+	NewVersion  bool // store a version even if the same file is already stored
+
+	// Structured is set when the version comes from structured data, such as
+	// a JSON Resume import or an export of another version. Its text is
+	// generated instead of extracted.
+	Structured *models.ResumeStructured
+}
+
+// CreateResume stores the upload under a generated per-user key and records it
+// as a new version. The client's filename is kept only as metadata. Uploaded
+// files are identified by content; versions generated from structured data
+// keep the type they were created with. Either way the user's plan must have
+// room for the file.
+//
+// Files are hashed and each distinct file is stored once per user. Uploading a
+// file identical to an existing version returns that version with
+// DuplicateOf set, unless NewVersion asks for a version regardless; that
+// version then shares the stored file.
+//
+// Uploaded PDFs with JavaScript or embedded files are rejected outright. Newly
+// stored files are malware scanned in the background and cannot be
+// downloaded until the scan passes.
+func (s *DocumentService) CreateResume(ctx context.Context, userID string, upload ResumeUpload) (*models.Resume, error) {
+	usage, err := s.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(io.LimitReader(upload.File, usage.Limits.MaxFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading upload: %w", err)
+	}
+	if err := checkFileSize(usage, int64(len(content))); err != nil {
+		return nil, err
+	}
+
+	contentType := upload.ContentType
+	if upload.Structured == nil {
+		if contentType, err = DetectResumeType(content); err != nil {
+			return nil, err
+		}
+		if contentType == "application/pdf" {
+			if err := checkPDFActiveContent(content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sum := sha256.Sum256(content)
+	resume := &models.Resume{
+		UserID:      userID,
+		Label:       strings.TrimSpace(upload.Label),
+		Filename:    storage.SafeFilename(upload.Filename),
+		ContentType: contentType,
+		SizeBytes:   int64(len(content)),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+	if resume.ContentType == "" {
+		resume.ContentType = "application/octet-stream"
+	}
+
+	if upload.DocumentID != "" {
+		doc, err := s.DB.GetResumeDocument(upload.DocumentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		resume.DocumentID = doc.ID
+	}
+	if upload.ParentID != "" {
+		parent, err := s.DB.GetResumeByID(upload.ParentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		resume.ParentID = &parent.ID
+	}
+
+	if upload.Structured == nil && !upload.NewVersion {
+		existing, err := s.DB.FindResumeByHash(userID, resume.SHA256, resume.DocumentID)
+		if err == nil {
+			existing.DuplicateOf = existing.PublicID
+			return existing, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	resume.StorageKey, err = s.DB.GetResumeBlobKey(userID, resume.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	newBytes := resume.SizeBytes
+	if resume.StorageKey != "" {
+		newBytes = 0
+	}
+	if err := checkResumeQuota(usage, newBytes); err != nil {
+		return nil, err
+	}
+
+	documentName := resume.Label
+	if documentName == "" {
+		documentName = strings.TrimSuffix(resume.Filename, filepath.Ext(resume.Filename))
+	}
+
+	uploadedKey := ""
+	if resume.StorageKey == "" {
+		uploadedKey = storage.NewKey("resumes", userID)
+		err = s.Blobs.Put(ctx, uploadedKey, bytes.NewReader(content), resume.SizeBytes, resume.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		resume.StorageKey = uploadedKey
+	}
+
+	if upload.Structured != nil {
+		resume.ExtractedText = FormatResumeText(&upload.Structured.Data)
+	} else if resume.ExtractedText, err = ExtractResumeText(content, resume.ContentType); err != nil {
+		log.Printf("Text extraction failed for %s: %v", resume.StorageKey, err)
+		resume.ExtractionError = err.Error()
+	}
+
+	created, err := s.DB.CreateResume(resume, documentName)
+	if uploadedKey != "" && (err != nil || created.StorageKey != uploadedKey) {
+		if delErr := s.Blobs.Delete(ctx, uploadedKey); delErr != nil {
+			log.Printf("Failed to clean up blob %s: %v", uploadedKey, delErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if created.StorageKey == uploadedKey {
+		s.startScan(uploadedKey, content)
+	}
+
+	if upload.Structured != nil {
+		err = s.DB.SaveResumeStructured(created.ID, upload.Structured)
+	} else if strings.TrimSpace(resume.ExtractedText) != "" {
+		_, err = s.parseStructured(created.ID, resume.ExtractedText)
+	}
+	if err != nil {
+		log.Printf("Failed to store structured resume %d: %v", created.ID, err)
+	}
+	return created, nil
+}
+
+// ImportJSONResume validates a jsonresume.org document and stores it as a new
+// resume version. The original JSON is kept as the version's file.
+func (s *DocumentService) ImportJSONResume(ctx context.Context, userID string, data []byte, documentID, label string) (*models.Resume, error) {
+	var doc models.JSONResume
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, &JSONResumeError{Problems: []string{err.Error()}}
+	}
+	if err := ValidateJSONResume(&doc); err != nil {
+		return nil, err
+	}
+	structured := FromJSONResume(&doc)
+	if structured.Name == "" && len(structured.Experience) == 0 && len(structured.Education) == 0 {
+		return nil, &JSONResumeError{Problems: []string{"document has no basics.name, work or education"}}
+	}
+
+	if label == "" {
+		label = structured.Name
+	}
+	return s.CreateResume(ctx, userID, ResumeUpload{
+		Filename:    "resume.json",
+		ContentType: "application/json",
+		File:        bytes.NewReader(data),
+		DocumentID:  documentID,
+		Label:       label,
+		Structured:  certainStructured(structured, models.StructuredSourceImport),
 	})
 }
 
+// ExportJSONResume returns the structured resume as a validated JSON Resume
+// document.
+func (s *DocumentService) ExportJSONResume(ctx context.Context, resume *models.Resume) (*models.JSONResume, error) {
+	structured, err := s.StructuredResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	doc := ToJSONResume(&structured.Data, structured.UpdatedAt)
+	if err := ValidateJSONResume(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// OpenResume returns the stored file for a resume. The caller closes it.
+func (s *DocumentService) OpenResume(ctx context.Context, resume *models.Resume) (io.ReadCloser, error) {
+	return s.Blobs.Get(ctx, resume.StorageKey)
+}
+
 func (s *DocumentService) GetUserResumes(userID string) ([]models.Resume, error) {
 	return s.DB.GetResumesByUserID(userID)
 }
 
-func (s *DocumentService) GetResumeByID(id int) (*models.Resume, error) {
-	return s.DB.GetResumeByID(id)
+func (s *DocumentService) GetResumeByID(publicID, userID string) (*models.Resume, error) {
+	return s.DB.GetResumeByID(publicID, userID)
+}
+
+func (s *DocumentService) SetResumeLabel(publicID, userID, label string) error {
+	return s.DB.SetResumeLabel(publicID, userID, strings.TrimSpace(label))
+}
+
+func (s *DocumentService) GetResumeDocuments(userID string) ([]models.ResumeDocument, error) {
+	return s.DB.GetResumeDocumentsByUserID(userID)
+}
+
+// GetResumeDocument returns a document with its versions, newest first.
+func (s *DocumentService) GetResumeDocument(publicID, userID string) (*models.ResumeDocument, error) {
+	return s.DB.GetResumeDocument(publicID, userID)
+}
+
+func (s *DocumentService) RenameResumeDocument(publicID, userID, name string) error {
+	return s.DB.RenameResumeDocument(publicID, userID, strings.TrimSpace(name))
+}
+
+// DiffResumes compares the extracted text of a version against another one,
+// or against its parent when againstID is empty.
+func (s *DocumentService) DiffResumes(ctx context.Context, publicID, againstID, userID string) (*models.ResumeDiff, error) {
+	resume, err := s.DB.GetResumeByID(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *models.Resume
+	switch {
+	case againstID != "":
+		base, err = s.DB.GetResumeByID(againstID, userID)
+	case resume.ParentID != nil:
+		base, err = s.DB.GetResumeByInternalID(*resume.ParentID, userID)
+	default:
+		return nil, ErrNoDiffBase
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	oldText, err := s.ResumeText(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	newText, err := s.ResumeText(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ResumeDiff{
+		From:     base.PublicID,
+		To:       resume.PublicID,
+		Sections: DiffResumeText(oldText, newText),
+	}, nil
+}
+
+// ResumeText returns the text extracted from a resume on upload. Resumes
+// uploaded before extraction existed are extracted now and the result stored.
+func (s *DocumentService) ResumeText(ctx context.Context, resume *models.Resume) (string, error) {
+	text, extracted, err := s.DB.GetResumeText(resume.ID)
+	if err != nil {
+		return "", err
+	}
+	if !extracted {
+		data, err := s.ReadResume(ctx, resume)
+		if err != nil {
+			return "", err
+		}
+		errMsg := ""
+		text, err = ExtractResumeText(data, resume.ContentType)
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if err := s.DB.SetResumeText(resume.ID, text, errMsg); err != nil {
+			return "", err
+		}
+		resume.ExtractionError = errMsg
+	}
+
+	if strings.TrimSpace(text) == "" {
+		if resume.ExtractionError != "" {
+			return "", fmt.Errorf("%w: %s", ErrNoResumeText, resume.ExtractionError)
+		}
+		return "", ErrNoResumeText
+	}
+	return text, nil
+}
+
+// StructuredResume returns the structured form of a resume, parsing the
+// extracted text on first use.
+func (s *DocumentService) StructuredResume(ctx context.Context, resume *models.Resume) (*models.ResumeStructured, error) {
+	rs, err := s.DB.GetResumeStructured(resume.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		text, textErr := s.ResumeText(ctx, resume)
+		if textErr != nil {
+			return nil, textErr
+		}
+		rs, err = s.parseStructured(resume.ID, text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rs.ResumeID = resume.PublicID
+	return rs, nil
+}
+
+func (s *DocumentService) parseStructured(resumeID int, text string) (*models.ResumeStructured, error) {
+	data, confidence := ParseResumeText(text)
+	rs := &models.ResumeStructured{
+		Data:       *data,
+		Confidence: confidence,
+		Source:     models.StructuredSourceParser,
+	}
+	if err := s.DB.SaveResumeStructured(resumeID, rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// UpdateStructuredResume stores the user's corrections. Every field is then
+// taken as certain.
+func (s *DocumentService) UpdateStructuredResume(publicID, userID string, data *models.StructuredResume) (*models.ResumeStructured, error) {
+	resume, err := s.DB.GetResumeByID(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rs := certainStructured(data, models.StructuredSourceUser)
+	if err := s.DB.SaveResumeStructured(resume.ID, rs); err != nil {
+		return nil, err
+	}
+	rs.ResumeID = resume.PublicID
+	return rs, nil
 }
 
-func (s *DocumentService) DeleteResumeByID(id int, userID string) error {
-	return s.DB.DeleteResumeByID(id, userID)
+// certainStructured wraps data that did not come from the parser, so every
+// field is taken as certain.
+func certainStructured(data *models.StructuredResume, source string) *models.ResumeStructured {
+	if data.Skills == nil {
+		data.Skills = map[string][]string{}
+	}
+	confidence := make(map[string]float64, len(structuredFields))
+	for _, f := range structuredFields {
+		confidence[f] = 1
+	}
+	return &models.ResumeStructured{
+		Data:       *data,
+		Confidence: confidence,
+		Source:     source,
+	}
+}
+
+// RepairStructuredResume runs the LLM over the fields the parser scored below
+// repairThreshold and keeps the rest of the parse. User edits are never
+// overwritten.
+func (s *DocumentService) RepairStructuredResume(ctx context.Context, publicID, userID string) (*models.ResumeStructured, error) {
+	resume, err := s.DB.GetResumeByID(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := s.StructuredResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	if rs.Source == models.StructuredSourceUser {
+		return nil, ErrUserEdited
+	}
+
+	var weak []string
+	for _, f := range structuredFields {
+		if rs.Confidence[f] < repairThreshold {
+			weak = append(weak, f)
+		}
+	}
+	if len(weak) == 0 || s.LLM == nil {
+		return rs, nil
+	}
+
+	text, err := s.ResumeText(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	repaired, gen, err := s.LLM.RepairStructuredResume(ctx, text, &rs.Data, weak)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range weak {
+		if copyStructuredField(&rs.Data, repaired, f) {
+			rs.Confidence[f] = repairedConfidence
+		}
+	}
+	rs.Source = models.StructuredSourceLLMRepair
+	rs.PromptVersion, rs.Model = gen.PromptVersion, gen.Model
+	if err := s.DB.SaveResumeStructured(resume.ID, rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// structuredFields are the top-level fields confidence is reported for.
+var structuredFields = []string{"name", "email", "phone", "linkedin", "github", "summary",
+	"education", "experience", "projects", "skills"}
+
+// copyStructuredField copies one top-level field from src into dst and
+// reports whether src had a value for it.
+func copyStructuredField(dst, src *models.StructuredResume, field string) bool {
+	switch field {
+	case "name":
+		dst.Name = src.Name
+		return src.Name != ""
+	case "email":
+		dst.Email = src.Email
+		return src.Email != ""
+	case "phone":
+		dst.Phone = src.Phone
+		return src.Phone != ""
+	case "linkedin":
+		dst.LinkedIn = src.LinkedIn
+		return src.LinkedIn != ""
+	case "github":
+		dst.GitHub = src.GitHub
+		return src.GitHub != ""
+	case "summary":
+		dst.Summary = src.Summary
+		return src.Summary != ""
+	case "education":
+		dst.Education = src.Education
+		return len(src.Education) > 0
+	case "experience":
+		dst.Experience = src.Experience
+		return len(src.Experience) > 0
+	case "projects":
+		dst.Projects = src.Projects
+		return len(src.Projects) > 0
+	case "skills":
+		if src.Skills != nil {
+			dst.Skills = src.Skills
+		}
+		return len(src.Skills) > 0
+	}
+	return false
+}
+
+// ResumeExport is a structured resume rendered to a file format.
+type ResumeExport struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// Export formats accepted by ExportResume.
+const (
+	ExportPDF   = "pdf"
+	ExportDOCX  = "docx"
+	ExportLaTeX = "latex"
+)
+
+var ErrUnsupportedExport = errors.New("unsupported export format")
+
+// ExportResume renders the structured resume as a PDF, a Word document or a
+// zipped LaTeX source bundle.
+func (s *DocumentService) ExportResume(ctx context.Context, resume *models.Resume, format string, opts render.Options) (*ResumeExport, error) {
+	structured, err := s.StructuredResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	return exportStructured(&structured.Data, format, opts)
+}
+
+func exportStructured(data *models.StructuredResume, format string, opts render.Options) (*ResumeExport, error) {
+	switch format {
+	case ExportPDF:
+		result, err := render.RenderResume(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ResumeExport{Data: result.PDF, ContentType: "application/pdf", Extension: ".pdf"}, nil
+	case ExportDOCX:
+		out, err := render.RenderResumeDOCX(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ResumeExport{Data: out, ContentType: render.DOCXContentType, Extension: ".docx"}, nil
+	case ExportLaTeX:
+		out, err := render.RenderResumeLaTeX(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ResumeExport{Data: out, ContentType: render.LaTeXBundleContentType, Extension: "-latex.zip"}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedExport, format)
+}
+
+// SaveResumeExport renders a version and stores the file as a new version of
+// the same document, derived from it, so it can be attached to jobs like any
+// upload. The new version keeps the source's structured data.
+func (s *DocumentService) SaveResumeExport(ctx context.Context, publicID, userID, format, label string, opts render.Options) (*models.Resume, error) {
+	resume, err := s.DB.GetResumeByID(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	structured, err := s.StructuredResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	export, err := exportStructured(&structured.Data, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if label == "" {
+		label = resume.Label
+		if label == "" {
+			label = strings.TrimSuffix(resume.Filename, filepath.Ext(resume.Filename))
+		}
+		label = fmt.Sprintf("%s (%s)", label, strings.ToUpper(format))
+	}
+	return s.CreateResume(ctx, userID, ResumeUpload{
+		Filename:    strings.TrimSuffix(resume.Filename, filepath.Ext(resume.Filename)) + export.Extension,
+		ContentType: export.ContentType,
+		File:        bytes.NewReader(export.Data),
+		DocumentID:  resume.DocumentRef,
+		ParentID:    resume.PublicID,
+		Label:       label,
+		Structured: &models.ResumeStructured{
+			Data:          structured.Data,
+			Confidence:    structured.Confidence,
+			Source:        structured.Source,
+			PromptVersion: structured.PromptVersion,
+			Model:         structured.Model,
+		},
+	})
+}
+
+// RenderResume lays out the structured resume as a PDF.
+func (s *DocumentService) RenderResume(ctx context.Context, resume *models.Resume, opts render.Options) (*render.Result, error) {
+	structured, err := s.StructuredResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	return render.RenderResume(&structured.Data, opts)
+}
+
+// LintResume checks the stored file and its extracted text for problems an
+// applicant tracking system would have with it. A file with no extractable
+// text is still linted; that is usually what is wrong with it.
+func (s *DocumentService) LintResume(ctx context.Context, resume *models.Resume) (*models.LintReport, error) {
+	data, err := s.ReadResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	text, err := s.ResumeText(ctx, resume)
+	if err != nil && !errors.Is(err, ErrNoResumeText) {
+		return nil, err
+	}
+
+	report := LintResume(data, resume.ContentType, text)
+	report.ResumeID = resume.PublicID
+	return report, nil
+}
+
+// ReadResume returns the stored file's bytes.
+func (s *DocumentService) ReadResume(ctx context.Context, resume *models.Resume) ([]byte, error) {
+	file, err := s.OpenResume(ctx, resume)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxResumeBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error reading resume %s: %w", resume.PublicID, err)
+	}
+	return data, nil
+}
+
+// ResumeDownloadURL returns a link that serves the file without a bearer token
+// until it expires.
+func (s *DocumentService) ResumeDownloadURL(resume *models.Resume) (string, time.Time, error) {
+	if err := s.downloadable(resume); err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(downloadURLTTL)
+	token, err := s.Downloads.Sign(DownloadClaims{
+		Kind:     "resume",
+		PublicID: resume.PublicID,
+		UserID:   resume.UserID,
+		Expires:  expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fmt.Sprintf("%s/api/files/%s", s.PublicURL, url.PathEscape(token)), expires, nil
+}
+
+// ResolveDownloadToken verifies a signed link and loads the resume it names,
+// still scoped to the owner recorded in the token.
+func (s *DocumentService) ResolveDownloadToken(token string) (*models.Resume, error) {
+	var claims DownloadClaims
+	if err := s.Downloads.Verify(token, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Kind != "resume" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() > claims.Expires {
+		return nil, ErrExpiredToken
+	}
+	return s.DB.GetResumeByID(claims.PublicID, claims.UserID)
+}
+
+// DeleteResumeByID removes the row, then its blob. A blob that fails to delete
+// is logged rather than failing the request since the row is already gone.
+func (s *DocumentService) DeleteResumeByID(ctx context.Context, publicID, userID string) error {
+	key, err := s.DB.DeleteResumeByID(publicID, userID)
+	if err != nil || key == "" {
+		return err
+	}
+	if err := s.Blobs.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete blob %s for resume %s: %v", key, publicID, err)
+	}
+	return nil
 }
 
 //
 // Cover letter logic
 //
 
-func (s *DocumentService) CreateCoverLetter(userID string, content string) (*models.CoverLetter, error) {
-	return s.DB.CreateCoverLetter(&models.CoverLetter{
-		UserID:  userID,
-		Content: content,
+// coverLetterTones are the tones a letter can be labelled with.
+var coverLetterTones = map[string]bool{
+	"": true, "professional": true, "formal": true, "friendly": true,
+	"enthusiastic": true, "confident": true, "concise": true,
+}
+
+const maxCoverLetterTitle = 200
+
+// CreateCoverLetter stores a new letter as version 1. Source defaults to
+// handwritten.
+func (s *DocumentService) CreateCoverLetter(userID string, letter models.CoverLetter) (*models.CoverLetter, error) {
+	letter.UserID = userID
+	if letter.Source == "" {
+		letter.Source = models.CoverLetterHandwritten
+	}
+	if err := s.validateCoverLetter(&letter); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCoverLetterQuota(usage); err != nil {
+		return nil, err
+	}
+	return s.DB.CreateCoverLetter(&letter)
+}
+
+// UpdateCoverLetter applies patch and saves the result as a new version. A
+// patch that changes nothing returns the letter as it is.
+func (s *DocumentService) UpdateCoverLetter(publicID, userID string, patch models.CoverLetterPatch) (*models.CoverLetter, error) {
+	letter, err := s.DB.GetCoverLetterByIDAndUser(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *letter
+	if patch.Title != nil {
+		updated.Title = strings.TrimSpace(*patch.Title)
+	}
+	if patch.JobID != nil {
+		updated.JobID = patch.JobID
+		if *patch.JobID == 0 {
+			updated.JobID = nil
+		}
+	}
+	if patch.Tone != nil {
+		updated.Tone = strings.ToLower(strings.TrimSpace(*patch.Tone))
+	}
+	if patch.Source != nil {
+		updated.Source = *patch.Source
+	}
+	if patch.PromptVersion != nil {
+		updated.PromptVersion = *patch.PromptVersion
+	}
+	if patch.Model != nil {
+		updated.Model = *patch.Model
+	}
+	if patch.Content != nil {
+		updated.Content = *patch.Content
+	}
+	if updated.Source == models.CoverLetterHandwritten {
+		updated.PromptVersion, updated.Model = "", ""
+	}
+	if updated.Title == letter.Title && equalJobIDs(updated.JobID, letter.JobID) && updated.Tone == letter.Tone &&
+		updated.Source == letter.Source && updated.PromptVersion == letter.PromptVersion &&
+		updated.Model == letter.Model && updated.Content == letter.Content {
+		return letter, nil
+	}
+
+	if err := s.validateCoverLetter(&updated); err != nil {
+		return nil, err
+	}
+	return s.DB.UpdateCoverLetter(&updated)
+}
+
+// GetCoverLetterVersions lists every saved version of a letter, newest first.
+func (s *DocumentService) GetCoverLetterVersions(publicID, userID string) ([]models.CoverLetterVersion, error) {
+	letter, err := s.DB.GetCoverLetterByIDAndUser(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.DB.GetCoverLetterVersions(letter.ID)
+}
+
+func (s *DocumentService) GetCoverLetterVersion(publicID, userID string, version int) (*models.CoverLetterVersion, error) {
+	letter, err := s.DB.GetCoverLetterByIDAndUser(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.DB.GetCoverLetterVersion(letter.ID, version)
+}
+
+// RestoreCoverLetterVersion makes an old version current again by saving a
+// copy of it as the newest version, so no history is lost.
+func (s *DocumentService) RestoreCoverLetterVersion(publicID, userID string, version int) (*models.CoverLetter, error) {
+	letter, err := s.DB.GetCoverLetterByIDAndUser(publicID, userID)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.DB.GetCoverLetterVersion(letter.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID := 0
+	if old.JobID != nil {
+		jobID = *old.JobID
+	}
+	return s.UpdateCoverLetter(publicID, userID, models.CoverLetterPatch{
+		Title:         &old.Title,
+		JobID:         &jobID,
+		Tone:          &old.Tone,
+		Source:        &old.Source,
+		PromptVersion: &old.PromptVersion,
+		Model:         &old.Model,
+		Content:       &old.Content,
 	})
 }
 
+func (s *DocumentService) validateCoverLetter(letter *models.CoverLetter) error {
+	letter.Title = strings.TrimSpace(letter.Title)
+	letter.Tone = strings.ToLower(strings.TrimSpace(letter.Tone))
+	switch {
+	case strings.TrimSpace(letter.Content) == "":
+		return fmt.Errorf("%w: content is required", ErrInvalidCoverLetter)
+	case len(letter.Title) > maxCoverLetterTitle:
+		return fmt.Errorf("%w: title is longer than %d characters", ErrInvalidCoverLetter, maxCoverLetterTitle)
+	case !coverLetterTones[letter.Tone]:
+		return fmt.Errorf("%w: unknown tone %q", ErrInvalidCoverLetter, letter.Tone)
+	case letter.Source != models.CoverLetterGenerated && letter.Source != models.CoverLetterHandwritten:
+		return fmt.Errorf("%w: source must be %q or %q", ErrInvalidCoverLetter, models.CoverLetterGenerated, models.CoverLetterHandwritten)
+	}
+	if letter.Source == models.CoverLetterHandwritten {
+		letter.PromptVersion, letter.Model = "", ""
+	}
+	if letter.JobID != nil {
+		ok, err := s.DB.JobExists(*letter.JobID, letter.UserID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: job %d not found", ErrInvalidCoverLetter, *letter.JobID)
+		}
+	}
+	return nil
+}
+
+func equalJobIDs(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (s *DocumentService) GetUserCoverLetters(userID string) ([]models.CoverLetter, error) {
 	return s.DB.GetCoverLettersByUserID(userID)
 }
 
-func (s *DocumentService) GetUserCoverLetterByID(id int, userID string) (*models.CoverLetter, error) {
-	return s.DB.GetCoverLetterByIDAndUser(id, userID)
+func (s *DocumentService) GetUserCoverLetterByID(publicID, userID string) (*models.CoverLetter, error) {
+	return s.DB.GetCoverLetterByIDAndUser(publicID, userID)
 }
 
-func (s *DocumentService) DeleteCoverLetterByID(id int, userID string) error {
-	return s.DB.DeleteCoverLetterByID(id, userID)
+func (s *DocumentService) DeleteCoverLetterByID(publicID, userID string) error {
+	return s.DB.DeleteCoverLetterByID(publicID, userID)
 }
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strings"
+	"trackify-jobs/models"
+)
+
+// classificationRule scores a classification by phrase matches. Subject hits
+// count double since recruiters and ATSs put the outcome there.
+type classificationRule struct {
+	Class   string
+	Weight  float64
+	Phrases []string
+}
+
+// Rejections and offers usually quote the application acknowledgement, so they
+// outweigh auto-ack phrases when both appear.
+var classificationRules = []classificationRule{
+	{
+		Class:  models.EmailClassRejection,
+		Weight: 3,
+		Phrases: []string{
+			"unfortunately", "not moving forward", "not be moving forward", "will not be moving forward",
+			"move forward with other candidates", "moving forward with other candidates",
+			"pursue other candidates", "other candidates whose", "decided not to proceed",
+			"will not be proceeding", "not to move forward", "position has been filled",
+			"regret to inform", "no longer under consideration", "not selected", "not a match at this time",
+		},
+	},
+	{
+		Class:  models.EmailClassOffer,
+		Weight: 3,
+		Phrases: []string{
+			"pleased to offer", "excited to offer", "extend an offer", "extend you an offer",
+			"offer letter", "offer of employment", "formal offer", "compensation package",
+			"verbal offer", "accept the offer",
+		},
+	},
+	{
+		Class:  models.EmailClassInterviewRequest,
+		Weight: 2,
+		Phrases: []string{
+			"schedule an interview", "schedule a call", "set up a call", "set up a time",
+			"phone screen", "technical screen", "next round", "onsite", "on-site",
+			"your availability", "share your availability", "calendly.com", "goodtime.io",
+			"would love to chat", "speak with you", "time to connect", "invite you to interview",
+			"interview with",
+		},
+	},
+	{
+		Class:  models.EmailClassAutoAck,
+		Weight: 1,
+		Phrases: []string{
+			"thank you for applying", "thanks for applying", "received your application",
+			"application has been received", "application was received", "we have received your application",
+			"application received", "successfully submitted", "thank you for your interest",
+			"your application for", "we will review your application", "do not reply",
+		},
+	},
+}
+
+// ClassifyEmailHeuristic assigns a classification using phrase rules. Confidence
+// grows with the winning score and shrinks when the runner-up is close.
+func ClassifyEmailHeuristic(subject, body string) (string, float64) {
+	subject = strings.ToLower(subject)
+	body = strings.ToLower(body)
+
+	scores := make(map[string]float64)
+	for _, rule := range classificationRules {
+		for _, phrase := range rule.Phrases {
+			if strings.Contains(subject, phrase) {
+				scores[rule.Class] += 2 * rule.Weight
+			}
+			if strings.Contains(body, phrase) {
+				scores[rule.Class] += rule.Weight
+			}
+		}
+	}
+
+	best, bestScore, runnerUp := models.EmailClassUnknown, 0.0, 0.0
+	for _, rule := range classificationRules {
+		score := scores[rule.Class]
+		if score > bestScore {
+			best, bestScore, runnerUp = rule.Class, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+	if bestScore == 0 {
+		return models.EmailClassUnknown, 0
+	}
+
+	confidence := 0.4 + 0.1*bestScore
+	if confidence > 0.95 {
+		confidence = 0.95
+	}
+	confidence *= bestScore / (bestScore + runnerUp)
+	return best, confidence
+}
+
+// statusForClassification maps a classification to the job status it implies.
+func statusForClassification(class string) string {
+	switch class {
+	case models.EmailClassRejection:
+		return models.JobStatusRejected
+	case models.EmailClassInterviewRequest:
+		return models.JobStatusInterview
+	case models.EmailClassOffer:
+		return models.JobStatusOffer
+	case models.EmailClassAutoAck:
+		return models.JobStatusApplied
+	}
+	return ""
+}
+
+// jobStatusRank orders statuses so proposals never move a job backwards.
+var jobStatusRank = map[string]int{
+	models.JobStatusNeedToApply: 0,
+	models.JobStatusApplied:     1,
+	models.JobStatusInterview:   2,
+	models.JobStatusOffer:       3,
+	models.JobStatusRejected:    3,
+}
+
+// isStatusProgression reports whether moving from current to proposed is forward.
+// Unknown statuses are treated as user-defined and always allow a proposal.
+func isStatusProgression(current, proposed string) bool {
+	if current == proposed {
+		return false
+	}
+	curRank, okCur := jobStatusRank[current]
+	newRank, okNew := jobStatusRank[proposed]
+	if !okCur || !okNew {
+		return true
+	}
+	if proposed == models.JobStatusRejected {
+		return true
+	}
+	return newRank > curRank
+}
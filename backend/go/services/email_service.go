@@ -2,24 +2,50 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
 	"time"
+	"trackify-jobs/database"
+	"trackify-jobs/models"
 
 	"github.com/resend/resend-go/v2"
 )
 
+// Tag names used to carry routing information on queued emails. Resend echoes
+// tags back in webhook payloads, so they double as bounce attribution.
+const (
+	emailTagCategory = "category"
+	emailTagUserID   = "user_id"
+)
+
 // EmailService handles sending emails and respects the rate-limiting of the Resend API.
 type EmailService struct {
-	Client          *resend.Client                   // Resend API client
-	emailQueue      chan []*resend.SendEmailRequest // Queue for emails (single or batch)
-	stopChannel     chan bool                        // Channel to stop the goroutine
-	rateLimitTime   time.Duration                   // Time between requests (1 request per second)
+	Client        *resend.Client                  // Resend API client
+	DB            *database.PostgresDB            // Preferences and suppression list
+	Unsubscribe   *TokenSigner                    // Signs List-Unsubscribe tokens
+	PublicURL     string                          // Base URL the unsubscribe endpoint is reachable at
+	emailQueue    chan []*resend.SendEmailRequest // Queue for emails (single or batch)
+	stopChannel   chan bool                       // Channel to stop the goroutine
+	rateLimitTime time.Duration                   // Time between requests (1 request per second)
+}
+
+// UnsubscribeClaims is the payload carried by a signed unsubscribe token.
+type UnsubscribeClaims struct {
+	UserID   string `json:"uid"`
+	Email    string `json:"email"`
+	Category string `json:"cat"`
 }
 
 // NewEmailService initializes the email service with the Resend client and queue.
-func NewEmailService(apiKey string) *EmailService {
+func NewEmailService(apiKey string, db *database.PostgresDB) *EmailService {
 	client := resend.NewClient(apiKey)
 	service := &EmailService{
 		Client:        client,
+		DB:            db,
+		Unsubscribe:   NewTokenSigner(os.Getenv("EMAIL_UNSUBSCRIBE_SECRET")),
+		PublicURL:     strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"),
 		emailQueue:    make(chan []*resend.SendEmailRequest, 100), // Queue to hold emails as to not reach rate limit
 		stopChannel:   make(chan bool),
 		rateLimitTime: time.Second, // 1 request per second (Resend can handle 2 per second, but still)
@@ -31,7 +57,7 @@ func NewEmailService(apiKey string) *EmailService {
 	return service
 }
 
-func (s *EmailService) CreateEmail(to, subject, body string) (*resend.SendEmailRequest) {
+func (s *EmailService) CreateEmail(to, subject, body string) *resend.SendEmailRequest {
 	// Create a single email request without sending it
 	emailData := &resend.SendEmailRequest{
 		From:    "email@example.com", // Replace with  actual sender email
@@ -43,23 +69,51 @@ func (s *EmailService) CreateEmail(to, subject, body string) (*resend.SendEmailR
 	return emailData
 }
 
-// SendEmail adds a single email to the queue.
-func (s *EmailService) SendEmail(to, subject, body string) error {
-	// Create a single email request
-	emailData := &resend.SendEmailRequest{
-		From:    "email@example.com", // Sender email
-		To:      []string{to},
-		Subject: subject,
-		Text:    body,
+// CreateCategorizedEmail builds an email for an opt-out-able category. It is tagged
+// with the user and category so the queue can honor preferences, and carries RFC 8058
+// one-click List-Unsubscribe headers when a signing secret is configured.
+func (s *EmailService) CreateCategorizedEmail(userID, category, to, subject, body string) *resend.SendEmailRequest {
+	emailData := s.CreateEmail(to, subject, body)
+	emailData.Tags = []resend.Tag{
+		{Name: emailTagCategory, Value: category},
+		{Name: emailTagUserID, Value: userID},
 	}
 
-	// Add the email to the queue
-	select {
-	case s.emailQueue <- []*resend.SendEmailRequest{emailData}: // Send as a batch with one email
-		return nil
-	case <-time.After(time.Second): // Timeout if queue is full
-		return fmt.Errorf("email queue is full, try again later")
+	link, err := s.UnsubscribeURL(userID, to, category)
+	if err != nil {
+		log.Printf("Sending %s email without unsubscribe link: %v", category, err)
+		return emailData
+	}
+
+	body += "\n\n--\nUnsubscribe from these emails: " + link
+	emailData.Text = body
+	emailData.Headers = map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return emailData
+}
+
+// UnsubscribeURL returns the signed one-click unsubscribe link for a recipient.
+func (s *EmailService) UnsubscribeURL(userID, email, category string) (string, error) {
+	if s.PublicURL == "" {
+		return "", fmt.Errorf("PUBLIC_API_URL is not set")
+	}
+	token, err := s.Unsubscribe.Sign(UnsubscribeClaims{UserID: userID, Email: strings.ToLower(email), Category: category})
+	if err != nil {
+		return "", err
 	}
+	return s.PublicURL + "/api/email/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+// SendEmail adds a single email to the queue.
+func (s *EmailService) SendEmail(to, subject, body string) error {
+	return s.SendEmailBatch([]*resend.SendEmailRequest{s.CreateEmail(to, subject, body)})
+}
+
+// SendCategorizedEmail adds a single email in an opt-out-able category to the queue.
+func (s *EmailService) SendCategorizedEmail(userID, category, to, subject, body string) error {
+	return s.SendEmailBatch([]*resend.SendEmailRequest{s.CreateCategorizedEmail(userID, category, to, subject, body)})
 }
 
 // SendEmailBatch adds a batch of emails to the queue.
@@ -73,7 +127,7 @@ func (s *EmailService) SendEmailBatch(batch []*resend.SendEmailRequest) error {
 	}
 }
 
-// processEmailQueue processes the email queue, sending one request every second.
+// ProcessEmailQueue processes the email queue, sending one request every second.
 func (s *EmailService) ProcessEmailQueue() {
 	ticker := time.NewTicker(s.rateLimitTime) // Rate-limiting to 1 request per second
 	defer ticker.Stop()
@@ -82,13 +136,10 @@ func (s *EmailService) ProcessEmailQueue() {
 		select {
 		case batch := <-s.emailQueue:
 			// Process a batch of emails (single or multiple emails in a batch)
-			err := s.SendEmailBatch(batch)
-			if err != nil {
-				// Handle the error (you could log it, for example)
-				fmt.Printf("Error sending email batch: %v\n", err)
+			if err := s.deliverBatch(batch); err != nil {
+				log.Printf("Error sending email batch: %v", err)
 			}
-		case <-ticker.C:
-			// Nothing needs to be done here, the select case handles email sending
+			<-ticker.C
 		case <-s.stopChannel:
 			// Stop the goroutine when signaled
 			return
@@ -96,7 +147,168 @@ func (s *EmailService) ProcessEmailQueue() {
 	}
 }
 
+// deliverBatch drops suppressed or opted-out recipients and sends what is left.
+func (s *EmailService) deliverBatch(batch []*resend.SendEmailRequest) error {
+	var allowed []*resend.SendEmailRequest
+	for _, email := range batch {
+		ok, err := s.isDeliverable(email)
+		if err != nil {
+			// Fail closed: a recipient we cannot check is not mailed.
+			log.Printf("Skipping email %q: %v", email.Subject, err)
+			continue
+		}
+		if ok {
+			allowed = append(allowed, email)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	_, err := s.Client.Batch.Send(allowed)
+	return err
+}
+
+// isDeliverable filters the recipients of an email against the suppression list
+// and, for categorized emails, the user's preferences.
+func (s *EmailService) isDeliverable(email *resend.SendEmailRequest) (bool, error) {
+	if s.DB == nil {
+		return true, nil
+	}
+
+	category, userID := models.EmailCategoryAll, ""
+	for _, tag := range email.Tags {
+		switch tag.Name {
+		case emailTagCategory:
+			category = tag.Value
+		case emailTagUserID:
+			userID = tag.Value
+		}
+	}
+
+	if userID != "" && category != models.EmailCategoryAll {
+		enabled, err := s.DB.IsEmailCategoryEnabled(userID, category)
+		if err != nil {
+			return false, err
+		}
+		if !enabled {
+			return false, nil
+		}
+	}
+
+	var recipients []string
+	for _, to := range email.To {
+		suppressed, err := s.DB.IsEmailSuppressed(to, category)
+		if err != nil {
+			return false, err
+		}
+		if !suppressed {
+			recipients = append(recipients, to)
+		}
+	}
+	email.To = recipients
+
+	return len(recipients) > 0, nil
+}
+
+//
+// Preference & suppression logic
+//
+
+// GetPreferences returns every known category with its effective state.
+func (s *EmailService) GetPreferences(userID string) ([]models.EmailPreference, error) {
+	stored, err := s.DB.GetEmailPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byCategory := make(map[string]models.EmailPreference, len(stored))
+	for _, p := range stored {
+		byCategory[p.Category] = p
+	}
+
+	prefs := make([]models.EmailPreference, 0, len(models.EmailCategories))
+	for _, category := range models.EmailCategories {
+		p, ok := byCategory[category]
+		if !ok {
+			p = models.EmailPreference{UserID: userID, Category: category, Enabled: true}
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences applies a category -> enabled map. Re-enabling a category also
+// lifts any suppression the user created by unsubscribing from it.
+func (s *EmailService) UpdatePreferences(userID string, updates map[string]bool) error {
+	for category := range updates {
+		if !IsEmailCategory(category) {
+			return fmt.Errorf("unknown email category %q", category)
+		}
+	}
+
+	for category, enabled := range updates {
+		if err := s.DB.SetEmailPreference(userID, category, enabled); err != nil {
+			return err
+		}
+		if enabled {
+			if err := s.DB.RemoveUnsubscribeSuppression(userID, category); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnsubscribeWithToken verifies a signed token and opts the recipient out of its category.
+func (s *EmailService) UnsubscribeWithToken(token string) (*UnsubscribeClaims, error) {
+	var claims UnsubscribeClaims
+	if err := s.Unsubscribe.Verify(token, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Email == "" || (claims.Category != models.EmailCategoryAll && !IsEmailCategory(claims.Category)) {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserID != "" && claims.Category != models.EmailCategoryAll {
+		if err := s.DB.SetEmailPreference(claims.UserID, claims.Category, false); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.DB.AddEmailSuppression(&models.EmailSuppression{
+		Email:    claims.Email,
+		Category: claims.Category,
+		Reason:   "unsubscribe",
+		UserID:   claims.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// SuppressAddress blocks all mail to an address, e.g. after a hard bounce or complaint.
+func (s *EmailService) SuppressAddress(email, reason string) error {
+	return s.DB.AddEmailSuppression(&models.EmailSuppression{
+		Email:    email,
+		Category: models.EmailCategoryAll,
+		Reason:   reason,
+	})
+}
+
 // Stop stops the email processing goroutine and cleans up resources.
 func (s *EmailService) Stop() {
 	close(s.stopChannel)
 }
+
+// IsEmailCategory reports whether category is one users can manage.
+func IsEmailCategory(category string) bool {
+	for _, c := range models.EmailCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
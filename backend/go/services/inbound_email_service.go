@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"trackify-jobs/database"
+	"trackify-jobs/models"
+)
+
+// llmFallbackThreshold is the heuristic confidence below which the LLM is asked.
+const llmFallbackThreshold = 0.6
+
+// llmClassifyTimeout bounds the LLM fallback. It runs inside the inbound
+// webhook, which should answer long before the provider gives up on it.
+const llmClassifyTimeout = 10 * time.Second
+
+// minJobMatchScore is the lowest score at which a message is attached to a job.
+const minJobMatchScore = 2
+
+var ErrUnknownRecipient = errors.New("no user owns this inbound address")
+
+// atsDomains send mail on behalf of many companies, so their sender domain
+// says nothing about which job a message is about.
+var atsDomains = []string{
+	"greenhouse.io", "greenhouse-mail.io", "lever.co", "hire.lever.co", "myworkday.com",
+	"myworkdayjobs.com", "ashbyhq.com", "smartrecruiters.com", "icims.com", "jobvite.com",
+	"workablemail.com", "workable.com", "bamboohr.com", "successfactors.com", "taleo.net",
+	"linkedin.com", "indeed.com", "gmail.com", "outlook.com", "yahoo.com",
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// InboundEmailService turns forwarded recruiter email into job status proposals.
+type InboundEmailService struct {
+	DB         *database.PostgresDB
+	LLMService *LLMService
+	Domain     string // domain forwarding addresses are issued on
+	UseLLM     bool   // ask the LLM when the heuristics are unsure
+}
+
+func NewInboundEmailService(db *database.PostgresDB, llm *LLMService) *InboundEmailService {
+	return &InboundEmailService{
+		DB:         db,
+		LLMService: llm,
+		Domain:     strings.ToLower(os.Getenv("INBOUND_EMAIL_DOMAIN")),
+		UseLLM:     os.Getenv("INBOUND_EMAIL_LLM_FALLBACK") == "true",
+	}
+}
+
+// GetForwardingAddress returns the user's forwarding address, issuing one on first use.
+func (s *InboundEmailService) GetForwardingAddress(userID string) (string, error) {
+	if s.Domain == "" {
+		return "", fmt.Errorf("INBOUND_EMAIL_DOMAIN is not set")
+	}
+
+	token, err := s.DB.GetInboundToken(userID)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		token = strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		if token, err = s.DB.CreateInboundToken(userID, token); err != nil {
+			return "", err
+		}
+	}
+	return token + "@" + s.Domain, nil
+}
+
+// resolveRecipient finds the user whose forwarding address the email was sent to.
+// Plus-addressing (token+anything@domain) is accepted.
+func (s *InboundEmailService) resolveRecipient(email *ParsedEmail) (string, error) {
+	for _, to := range email.To {
+		local, domain, ok := strings.Cut(to, "@")
+		if !ok || domain != s.Domain {
+			continue
+		}
+		token, _, _ := strings.Cut(local, "+")
+		userID, err := s.DB.GetUserIDByInboundToken(token)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return userID, nil
+	}
+	return "", ErrUnknownRecipient
+}
+
+// Process stores an inbound email for its recipient, matches it to a tracked job
+// and queues a status proposal when the classification implies a change.
+// It returns (nil, nil) for messages that were already processed.
+// ctx bounds the LLM classification fallback.
+func (s *InboundEmailService) Process(ctx context.Context, email *ParsedEmail) (*models.InboundMessage, error) {
+	userID, err := s.resolveRecipient(email)
+	if err != nil {
+		return nil, err
+	}
+	return s.ProcessForUser(ctx, userID, email)
+}
+
+// ProcessForUser is Process for a recipient that is already known.
+func (s *InboundEmailService) ProcessForUser(ctx context.Context, userID string, email *ParsedEmail) (*models.InboundMessage, error) {
+	// Check for redelivery first so a repeat never costs an LLM call. The
+	// insert below still catches a duplicate that races with this one.
+	seen, err := s.DB.InboundMessageExists(userID, email.MessageID)
+	if err != nil || seen {
+		return nil, err
+	}
+
+	class, confidence := ClassifyEmailHeuristic(email.Subject, email.Text)
+	if confidence < llmFallbackThreshold && s.UseLLM && s.LLMService != nil {
+		classifyCtx, cancel := context.WithTimeout(ctx, llmClassifyTimeout)
+		result, err := s.LLMService.ClassifyRecruiterEmail(classifyCtx, email.Subject, email.Text)
+		cancel()
+		if err != nil {
+			log.Printf("LLM email classification failed, keeping heuristic result: %v", err)
+		} else if result.Confidence > confidence {
+			class, confidence = result.Classification, result.Confidence
+		}
+	}
+
+	jobs, err := s.DB.GetJobsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.matchJob(userID, email, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &models.InboundMessage{
+		UserID:         userID,
+		MessageID:      email.MessageID,
+		FromAddress:    email.FromEmail,
+		FromName:       email.FromName,
+		Subject:        email.Subject,
+		Body:           email.Text,
+		Classification: class,
+		Confidence:     confidence,
+		ReceivedAt:     email.Date,
+	}
+	if job != nil {
+		msg.JobID = &job.ID
+	}
+
+	msg, err = s.DB.CreateInboundMessage(msg)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	if job == nil {
+		return msg, nil
+	}
+	proposed := statusForClassification(class)
+	if proposed == "" || !isStatusProgression(job.Status, proposed) {
+		return msg, nil
+	}
+
+	_, err = s.DB.CreateJobStatusProposal(&models.JobStatusProposal{
+		UserID:           userID,
+		JobID:            job.ID,
+		InboundMessageID: &msg.ID,
+		CurrentStatus:    job.Status,
+		ProposedStatus:   proposed,
+		Classification:   class,
+		Confidence:       confidence,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// matchJob scores each tracked job against the email. Senders we have matched
+// before are the strongest signal, then the sender's domain, then the company
+// name appearing in the subject or body.
+func (s *InboundEmailService) matchJob(userID string, email *ParsedEmail, jobs []models.Job) (*models.Job, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	scores := make(map[int]float64, len(jobs))
+
+	contactJobs, err := s.DB.GetJobIDsByContact(userID, email.FromEmail)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range contactJobs {
+		scores[id] += 5 - float64(i) // earlier = more frequent
+	}
+
+	scoreJobsByContent(scores, email, jobs)
+
+	var best *models.Job
+	bestScore := 0.0
+	for i := range jobs {
+		if score := scores[jobs[i].ID]; score > bestScore {
+			best, bestScore = &jobs[i], score
+		}
+	}
+	if bestScore < minJobMatchScore {
+		return nil, nil
+	}
+	return best, nil
+}
+
+// scoreJobsByContent adds domain and company-name evidence to scores.
+func scoreJobsByContent(scores map[int]float64, email *ParsedEmail, jobs []models.Job) {
+	senderDomain := email.FromDomain()
+	fromATS := isATSDomain(senderDomain)
+	senderLabel := registrableLabel(senderDomain)
+	subject := normalizeCompany(email.Subject)
+	body := normalizeCompany(email.Text)
+
+	for _, job := range jobs {
+		company := normalizeCompany(job.Company)
+		if company == "" {
+			continue
+		}
+		compact := strings.ReplaceAll(company, " ", "")
+
+		if !fromATS && senderLabel != "" {
+			if senderLabel == compact || strings.HasPrefix(senderLabel, compact) {
+				scores[job.ID] += 3
+			} else if host := jobURLHost(job.URL); host != "" && registrableLabel(host) == senderLabel {
+				scores[job.ID] += 3
+			}
+		}
+		if containsWord(subject, company) {
+			scores[job.ID] += 2
+		} else if containsWord(body, company) {
+			scores[job.ID] += 1
+		}
+		if job.Title != "" && containsWord(subject+" "+body, normalizeCompany(job.Title)) {
+			scores[job.ID] += 0.5
+		}
+	}
+}
+
+// normalizeCompany lowercases and strips punctuation and legal suffixes.
+func normalizeCompany(s string) string {
+	s = nonAlphanumeric.ReplaceAllString(strings.ToLower(s), " ")
+	fields := strings.Fields(s)
+	for len(fields) > 1 {
+		switch fields[len(fields)-1] {
+		case "inc", "llc", "ltd", "corp", "corporation", "co", "gmbh", "plc":
+			fields = fields[:len(fields)-1]
+			continue
+		}
+		break
+	}
+	return strings.Join(fields, " ")
+}
+
+func containsWord(haystack, needle string) bool {
+	return needle != "" && strings.Contains(" "+haystack+" ", " "+needle+" ")
+}
+
+// registrableLabel returns the label left of the public suffix, e.g.
+// "mail.stripe.com" -> "stripe". Two-part suffixes like co.uk are handled.
+func registrableLabel(domain string) string {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	i := len(labels) - 2
+	if len(labels) >= 3 && len(labels[i+1]) == 2 {
+		switch labels[i] {
+		case "co", "com", "org", "net", "ac", "gov", "edu":
+			i-- // e.g. example.co.uk
+		}
+	}
+	return labels[i]
+}
+
+func isATSDomain(domain string) bool {
+	for _, ats := range atsDomains {
+		if domain == ats || strings.HasSuffix(domain, "."+ats) {
+			return true
+		}
+	}
+	return false
+}
+
+func jobURLHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || isATSDomain(strings.ToLower(u.Hostname())) {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+//
+// Proposal logic
+//
+
+func (s *InboundEmailService) GetProposals(userID, status string) ([]models.JobStatusProposal, error) {
+	return s.DB.GetJobStatusProposals(userID, status)
+}
+
+func (s *InboundEmailService) ResolveProposal(id int, userID string, accept bool) (*models.JobStatusProposal, error) {
+	return s.DB.ResolveJobStatusProposal(id, userID, accept)
+}
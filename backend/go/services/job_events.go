@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"trackify-jobs/models"
+
+	"github.com/lib/pq"
+)
+
+// jobEventsChannel is the channel the background_jobs trigger notifies on
+// (see migration 000022).
+const jobEventsChannel = "background_job_events"
+
+// listenerPingInterval is how often an idle listener checks its connection,
+// so that a dead one is noticed and replaced.
+const listenerPingInterval = 90 * time.Second
+
+// JobEvents delivers job events to subscribers on this instance. Events come
+// from Postgres LISTEN/NOTIFY rather than from this instance's workers, so a
+// client sees a job's progress whichever instance runs it.
+type JobEvents struct {
+	listener *pq.Listener
+
+	mu     sync.Mutex
+	subs   map[string]map[*JobSubscription]struct{} // by job ID
+	closed bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+// JobSubscription receives one job's events.
+//
+// Events carry the job's whole state rather than a difference, so only the
+// latest matters: one that has not been read yet is replaced by the next.
+// A value on Resync means events may have been missed, while the listener
+// was reconnecting; the subscriber should load the job again. Both channels
+// are closed when the subscription ends.
+type JobSubscription struct {
+	Events <-chan models.JobEvent
+	Resync <-chan struct{}
+
+	events chan models.JobEvent
+	resync chan struct{}
+	jobID  string
+	hub    *JobEvents
+}
+
+// NewJobEvents connects a listener to the database at connStr and starts
+// delivering events. The listener reconnects by itself if the connection
+// drops.
+func NewJobEvents(connStr string) (*JobEvents, error) {
+	e := &JobEvents{
+		subs: map[string]map[*JobSubscription]struct{}{},
+		done: make(chan struct{}),
+	}
+	e.listener = pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Job events listener: %v", err)
+		}
+	})
+	if err := e.listener.Listen(jobEventsChannel); err != nil {
+		e.listener.Close()
+		return nil, err
+	}
+	go e.dispatch()
+	return e, nil
+}
+
+// Subscribe starts receiving jobID's events. Call Close on the subscription
+// when done with it.
+func (e *JobEvents) Subscribe(jobID string) *JobSubscription {
+	events := make(chan models.JobEvent, 1)
+	resync := make(chan struct{}, 1)
+	sub := &JobSubscription{Events: events, Resync: resync, events: events, resync: resync, jobID: jobID, hub: e}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		close(events)
+		close(resync)
+		return sub
+	}
+	if e.subs[jobID] == nil {
+		e.subs[jobID] = map[*JobSubscription]struct{}{}
+	}
+	e.subs[jobID][sub] = struct{}{}
+	return sub
+}
+
+// Close ends the subscription.
+func (s *JobSubscription) Close() {
+	e := s.hub
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.subs[s.jobID][s]; !ok {
+		return
+	}
+	delete(e.subs[s.jobID], s)
+	if len(e.subs[s.jobID]) == 0 {
+		delete(e.subs, s.jobID)
+	}
+	close(s.events)
+	close(s.resync)
+}
+
+// Close stops the listener and ends every subscription, so that streams
+// waiting on them finish. It is safe to call more than once.
+func (e *JobEvents) Close() {
+	e.once.Do(func() {
+		close(e.done)
+		e.listener.Close()
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.closed = true
+		for _, subs := range e.subs {
+			for sub := range subs {
+				close(sub.events)
+				close(sub.resync)
+			}
+		}
+		e.subs = nil
+	})
+}
+
+func (e *JobEvents) dispatch() {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			// Ping reports a dead connection, which the listener then replaces.
+			go e.listener.Ping()
+		case n, ok := <-e.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Reconnected: anything sent meanwhile is lost.
+				e.resyncAll()
+				continue
+			}
+			var ev models.JobEvent
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				log.Printf("Job events: bad payload %q: %v", n.Extra, err)
+				continue
+			}
+			e.publish(ev)
+		}
+	}
+}
+
+func (e *JobEvents) publish(ev models.JobEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for sub := range e.subs[ev.JobID] {
+		// Replace an unread event rather than block the dispatcher on a slow
+		// client. Only this goroutine sends, so the second send cannot block.
+		select {
+		case <-sub.events:
+		default:
+		}
+		sub.events <- ev
+	}
+}
+
+func (e *JobEvents) resyncAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, subs := range e.subs {
+		for sub := range subs {
+			select {
+			case sub.resync <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mrand "math/rand/v2"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+	"trackify-jobs/database"
+	"trackify-jobs/models"
+
+	"github.com/google/uuid"
+)
+
+// JobHandler runs one background job. Its result is stored as the job's
+// result; an error fails the attempt, and the job is retried with backoff
+// unless the error is permanent (see PermanentJobError) or attempts are used
+// up. ctx is cancelled when the job's owner cancels it, or the worker loses
+// the job or shuts down. progress reports the steps the handler goes
+// through, which the job's owner can follow as they happen.
+type JobHandler func(ctx context.Context, job *models.BackgroundJob, input []byte, progress JobProgressFunc) (interface{}, error)
+
+// JobProgressFunc records the stage a running job has reached and how far
+// along it is, in percent. A failure to record it is logged, not returned:
+// progress is for show, and the job goes on regardless.
+type JobProgressFunc func(stage string, percent int)
+
+// JobQueueOptions tune the workers. Zero values take the defaults.
+type JobQueueOptions struct {
+	Workers      int           // jobs run at once by this instance; default 2
+	Lease        time.Duration // how long a claim lasts without a heartbeat; default 1m
+	PollInterval time.Duration // how often idle workers look for due jobs; default 2s
+	MaxAttempts  int           // attempts per job, including the first; default 3
+	Retention    time.Duration // how long finished jobs and results are kept; default 7 days
+}
+
+// purgeInterval is how often each instance deletes expired jobs.
+const purgeInterval = time.Hour
+
+// ErrJobNotCancellable means the job finished before it could be cancelled.
+var ErrJobNotCancellable = errors.New("job has already finished")
+
+// Causes of a running job's context ending early.
+var (
+	errJobCancelled = errors.New("job cancelled by its owner")
+	errLeaseLost    = errors.New("job lease lost")
+)
+
+// JobQueue runs background jobs stored in Postgres. Jobs survive restarts:
+// a worker that stops or crashes leaves its jobs to be claimed again once
+// their lease lapses, by this instance or any other.
+type JobQueue struct {
+	DB   *database.PostgresDB
+	key  []byte
+	opts JobQueueOptions
+
+	worker   string // identifies this instance's leases
+	handlers map[models.JobKind]JobHandler
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc // jobs this instance is running, by ID
+
+	wake    chan struct{}
+	stop    chan struct{}
+	ctx     context.Context // parent of every running job
+	abandon context.CancelFunc
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewJobQueue returns a stopped queue. key is the 32-byte AES-256 key that
+// job inputs and results are encrypted with.
+func NewJobQueue(db *database.PostgresDB, key []byte, opts JobQueueOptions) (*JobQueue, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("job encryption key must be 32 bytes, got %d", len(key))
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobQueue{
+		DB:       db,
+		key:      key,
+		opts:     opts,
+		worker:   fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8]),
+		handlers: map[models.JobKind]JobHandler{},
+		running:  map[string]context.CancelCauseFunc{},
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		abandon:  cancel,
+	}, nil
+}
+
+// Register sets the handler for a kind. Call it before Start.
+func (q *JobQueue) Register(kind models.JobKind, h JobHandler) {
+	q.handlers[kind] = h
+}
+
+// Enqueue stores a job for userID with input, marshalled to JSON and
+// encrypted. The job is durable once Enqueue returns.
+func (q *JobQueue) Enqueue(userID string, kind models.JobKind, input interface{}) (*models.BackgroundJob, error) {
+	if _, ok := q.handlers[kind]; !ok {
+		return nil, fmt.Errorf("no handler registered for job kind %q", kind)
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := encryptAESGCM(q.key, data)
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.DB.EnqueueBackgroundJob(&models.BackgroundJob{
+		UserID:      userID,
+		Kind:        kind,
+		Input:       enc,
+		MaxAttempts: q.opts.MaxAttempts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Get returns one of userID's jobs with its result decrypted. Other users'
+// jobs are reported as sql.ErrNoRows, the same as jobs that do not exist.
+func (q *JobQueue) Get(id, userID string) (*models.BackgroundJob, error) {
+	job, err := q.DB.GetBackgroundJob(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(job.Result) > 0 {
+		if job.Result, err = decryptAESGCM(q.key, job.Result); err != nil {
+			return nil, fmt.Errorf("error decrypting job result: %w", err)
+		}
+	}
+	q.setExpiry(job)
+	return job, nil
+}
+
+// List returns userID's jobs, newest first, without their results.
+func (q *JobQueue) List(userID string) ([]models.BackgroundJob, error) {
+	jobs, err := q.DB.GetBackgroundJobsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		q.setExpiry(&jobs[i])
+	}
+	return jobs, nil
+}
+
+// Cancel stops one of userID's queued or running jobs and returns it. A job
+// running on this instance is stopped at once; one running elsewhere stops
+// at its worker's next heartbeat. It returns ErrJobNotCancellable if the job
+// has already finished, and sql.ErrNoRows if userID has no such job.
+func (q *JobQueue) Cancel(id, userID string) (*models.BackgroundJob, error) {
+	job, err := q.DB.CancelBackgroundJob(id, userID)
+	if err == sql.ErrNoRows {
+		if _, err := q.DB.GetBackgroundJob(id, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobNotCancellable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error cancelling job: %w", err)
+	}
+
+	q.mu.Lock()
+	cancel := q.running[id]
+	q.mu.Unlock()
+	if cancel != nil {
+		cancel(errJobCancelled)
+	}
+	q.setExpiry(job)
+	return job, nil
+}
+
+// Delete removes one of userID's finished jobs and its result ahead of
+// expiry. It returns sql.ErrNoRows if userID has no such finished job.
+func (q *JobQueue) Delete(id, userID string) error {
+	return q.DB.DeleteBackgroundJob(id, userID)
+}
+
+func (q *JobQueue) setExpiry(job *models.BackgroundJob) {
+	if job.FinishedAt != nil {
+		expires := job.FinishedAt.Add(q.opts.Retention)
+		job.ExpiresAt = &expires
+	}
+}
+
+// Start launches the workers.
+func (q *JobQueue) Start() {
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.purge()
+	log.Printf("Job queue started: %d workers as %s", q.opts.Workers, q.worker)
+}
+
+// Stop stops claiming jobs and waits for running ones to finish. If ctx ends
+// first, running jobs are cancelled and handed back to the queue, so another
+// instance can pick them up at once instead of waiting out their lease.
+func (q *JobQueue) Stop(ctx context.Context) error {
+	q.once.Do(func() { close(q.stop) })
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.abandon()
+		return nil
+	case <-ctx.Done():
+		log.Printf("Job queue drain timed out, releasing running jobs")
+		q.abandon()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// purge deletes jobs whose retention has run out, now and every
+// purgeInterval. Every instance purges; the deletes are idempotent.
+func (q *JobQueue) purge() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := q.DB.PurgeBackgroundJobs(q.opts.Retention); err != nil {
+			log.Printf("Job queue: %v", err)
+		} else if n > 0 {
+			log.Printf("Job queue: purged %d expired jobs", n)
+		}
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, string(kind))
+	}
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.DB.ClaimBackgroundJob(q.worker, kinds, q.opts.Lease)
+		if err != nil {
+			log.Printf("Job queue: %v", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+// run executes one claimed job, heartbeating while it runs, and records how
+// it ended.
+func (q *JobQueue) run(job *models.BackgroundJob) {
+	if job.Attempts > job.MaxAttempts {
+		// Only a job whose worker died mid-attempt gets here: the claim that
+		// would have been its last retry already counted.
+		q.finish(job, q.DB.FailBackgroundJob(job.ID, q.worker, "job was abandoned by its worker too many times"))
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(q.ctx)
+	defer cancel(nil)
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+	}()
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		q.heartbeat(ctx, job, cancel)
+	}()
+
+	result, err := q.execute(ctx, job)
+	cause := context.Cause(ctx)
+	cancel(nil)
+	<-heartbeatDone
+
+	switch cause {
+	case errJobCancelled:
+		log.Printf("Job %s (%s) cancelled by its owner", job.ID, job.Kind)
+		return
+	case errLeaseLost:
+		// Cancelled from another instance, or taken over after a stall;
+		// either way the row is no longer this worker's to update.
+		return
+	}
+
+	if err == nil {
+		var data, enc []byte
+		if data, err = json.Marshal(result); err == nil {
+			if enc, err = encryptAESGCM(q.key, data); err == nil {
+				q.progress(job, models.JobStageSaving, 95)
+				q.finish(job, q.DB.CompleteBackgroundJob(job.ID, q.worker, enc))
+				return
+			}
+		}
+	}
+	if q.ctx.Err() != nil {
+		// Shutting down: the job did not fail, it was interrupted.
+		q.finish(job, q.DB.ReleaseBackgroundJob(job.ID, q.worker))
+		return
+	}
+
+	var permanent *permanentJobError
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %s (%s) failed after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+		q.finish(job, q.DB.FailBackgroundJob(job.ID, q.worker, err.Error()))
+		return
+	}
+	delay := retryDelay(job.Attempts)
+	log.Printf("Job %s (%s) attempt %d failed, retrying in %s: %v", job.ID, job.Kind, job.Attempts, delay, err)
+	q.finish(job, q.DB.RetryBackgroundJob(job.ID, q.worker, err.Error(), delay))
+}
+
+// execute decrypts the job's input and calls its handler, turning a panic
+// into a permanent failure rather than a crashed server.
+func (q *JobQueue) execute(ctx context.Context, job *models.BackgroundJob) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s (%s) panicked: %v\n%s", job.ID, job.Kind, r, debug.Stack())
+			err = PermanentJobError(fmt.Errorf("job panicked: %v", r))
+		}
+	}()
+	input, err := decryptAESGCM(q.key, job.Input)
+	if err != nil {
+		return nil, PermanentJobError(fmt.Errorf("error decrypting job input: %w", err))
+	}
+	return q.handlers[job.Kind](ctx, job, input, func(stage string, percent int) {
+		q.progress(job, stage, percent)
+	})
+}
+
+// heartbeat extends the job's lease until ctx ends, and cancels the job if
+// the lease turns out to be lost.
+func (q *JobQueue) heartbeat(ctx context.Context, job *models.BackgroundJob, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(q.opts.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := q.DB.ExtendBackgroundJobLease(job.ID, q.worker, q.opts.Lease)
+			if err != nil {
+				// Keep going; the lease has room for a missed beat or two.
+				log.Printf("Job %s heartbeat failed: %v", job.ID, err)
+				continue
+			}
+			if !held {
+				log.Printf("Job %s was cancelled or taken over, stopping it", job.ID)
+				cancel(errLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+func (q *JobQueue) progress(job *models.BackgroundJob, stage string, percent int) {
+	percent = min(max(percent, 0), 100)
+	if err := q.DB.UpdateBackgroundJobProgress(job.ID, q.worker, stage, percent); err != nil {
+		log.Printf("Job %s: %v", job.ID, err)
+	}
+}
+
+func (q *JobQueue) finish(job *models.BackgroundJob, err error) {
+	if err == sql.ErrNoRows {
+		log.Printf("Job %s was cancelled or taken over; dropping this attempt's outcome", job.ID)
+	} else if err != nil {
+		log.Printf("Job %s: %v", job.ID, err)
+	}
+}
+
+// retryDelay backs off exponentially from 15s, capped at 10 minutes, with
+// jitter so that jobs failing together do not retry together.
+func retryDelay(attempt int) time.Duration {
+	d := 15 * time.Second << min(attempt-1, 6)
+	d = min(d, 10*time.Minute)
+	return d/2 + mrand.N(d/2)
+}
+
+type permanentJobError struct{ err error }
+
+func (e *permanentJobError) Error() string { return e.err.Error() }
+func (e *permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError marks a job failure that retrying cannot fix.
+func PermanentJobError(err error) error {
+	return &permanentJobError{err: err}
+}
+
+func encryptAESGCM(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	// ciphertext = nonce || gcm(nonce, plaintext)
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decryptAESGCM(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := gcm.NonceSize()
+	if len(ciphertext) < ns {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, data := ciphertext[:ns], ciphertext[ns:]
+	return gcm.Open(nil, nonce, data, nil)
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"trackify-jobs/models"
+)
+
+const jsonResumeSchemaURL = "https://raw.githubusercontent.com/jsonresume/resume-schema/v1.0.0/schema.json"
+
+// jsonResumeDateRe is the schema's iso8601 pattern: YYYY, YYYY-MM or YYYY-MM-DD.
+var jsonResumeDateRe = regexp.MustCompile(`^([1-2][0-9]{3}-[0-1][0-9]-[0-3][0-9]|[1-2][0-9]{3}-[0-1][0-9]|[1-2][0-9]{3})$`)
+
+var monthNumbers = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// JSONResumeError lists every schema problem found in a document.
+type JSONResumeError struct {
+	Problems []string
+}
+
+func (e *JSONResumeError) Error() string {
+	return "invalid JSON Resume: " + strings.Join(e.Problems, "; ")
+}
+
+// ToJSONResume maps a structured resume onto the JSON Resume schema. Each
+// skills category becomes one skill whose keywords are the category's entries.
+func ToJSONResume(r *models.StructuredResume, lastModified time.Time) *models.JSONResume {
+	out := &models.JSONResume{
+		Schema: jsonResumeSchemaURL,
+		Basics: models.JSONResumeBasics{
+			Name:    r.Name,
+			Email:   r.Email,
+			Phone:   r.Phone,
+			Summary: r.Summary,
+		},
+		Meta: &models.JSONResumeMeta{
+			Version:      "v1.0.0",
+			LastModified: lastModified.UTC().Format("2006-01-02T15:04:05"),
+		},
+	}
+	if r.LinkedIn != "" {
+		out.Basics.Profiles = append(out.Basics.Profiles, profileFromURL("LinkedIn", r.LinkedIn))
+	}
+	if r.GitHub != "" {
+		out.Basics.Profiles = append(out.Basics.Profiles, profileFromURL("GitHub", r.GitHub))
+	}
+
+	for _, e := range r.Experience {
+		start, end := isoDateRange(e.Date, false)
+		out.Work = append(out.Work, models.JSONResumeWork{
+			Name:       e.Company,
+			Position:   e.Title,
+			StartDate:  start,
+			EndDate:    end,
+			Highlights: e.Bullets,
+		})
+	}
+	for _, e := range r.Education {
+		studyType, area := splitDegree(e.Degree)
+		start, end := isoDateRange(e.Date, true)
+		out.Education = append(out.Education, models.JSONResumeEducation{
+			Institution: e.School,
+			StudyType:   studyType,
+			Area:        area,
+			StartDate:   start,
+			EndDate:     end,
+		})
+	}
+	for _, p := range r.Projects {
+		start, end := isoDateRange(p.Date, false)
+		out.Projects = append(out.Projects, models.JSONResumeProject{
+			Name:       p.Name,
+			StartDate:  start,
+			EndDate:    end,
+			Highlights: p.Bullets,
+		})
+	}
+
+	categories := make([]string, 0, len(r.Skills))
+	for c := range r.Skills {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	for _, c := range categories {
+		out.Skills = append(out.Skills, models.JSONResumeSkill{Name: c, Keywords: r.Skills[c]})
+	}
+	return out
+}
+
+// FromJSONResume maps a JSON Resume document onto the structured schema. A
+// skill without keywords is itself a keyword and goes under "Skills".
+func FromJSONResume(j *models.JSONResume) *models.StructuredResume {
+	out := &models.StructuredResume{
+		Name:    strings.TrimSpace(j.Basics.Name),
+		Email:   strings.TrimSpace(j.Basics.Email),
+		Phone:   strings.TrimSpace(j.Basics.Phone),
+		Summary: strings.TrimSpace(j.Basics.Summary),
+		Skills:  map[string][]string{},
+	}
+	for _, p := range j.Basics.Profiles {
+		switch strings.ToLower(p.Network) {
+		case "linkedin":
+			out.LinkedIn = profileURL(p, "https://www.linkedin.com/in/")
+		case "github":
+			out.GitHub = profileURL(p, "https://github.com/")
+		}
+	}
+
+	for _, w := range j.Work {
+		bullets := w.Highlights
+		if s := strings.TrimSpace(w.Summary); s != "" {
+			bullets = append([]string{s}, bullets...)
+		}
+		out.Experience = append(out.Experience, models.ExperienceEntry{
+			Title:   w.Position,
+			Company: w.Name,
+			Date:    displayDateRange(w.StartDate, w.EndDate),
+			Bullets: bullets,
+		})
+	}
+	for _, e := range j.Education {
+		out.Education = append(out.Education, models.EducationEntry{
+			Degree: joinDegree(e.StudyType, e.Area),
+			School: e.Institution,
+			Date:   displayDateRange(e.StartDate, e.EndDate),
+		})
+	}
+	for _, p := range j.Projects {
+		bullets := p.Highlights
+		if d := strings.TrimSpace(p.Description); d != "" {
+			bullets = append([]string{d}, bullets...)
+		}
+		out.Projects = append(out.Projects, models.ProjectEntry{
+			Name:    p.Name,
+			Date:    displayDateRange(p.StartDate, p.EndDate),
+			Bullets: bullets,
+		})
+	}
+	for _, s := range j.Skills {
+		name := strings.TrimSpace(s.Name)
+		if len(s.Keywords) == 0 {
+			if name != "" {
+				out.Skills["Skills"] = append(out.Skills["Skills"], name)
+			}
+			continue
+		}
+		if name == "" {
+			name = "Skills"
+		}
+		out.Skills[name] = append(out.Skills[name], s.Keywords...)
+	}
+	return out
+}
+
+// ValidateJSONResume checks the formats the schema constrains: dates, the
+// email address and URLs.
+func ValidateJSONResume(j *models.JSONResume) error {
+	var problems []string
+	checkDate := func(field, v string) {
+		if v != "" && !jsonResumeDateRe.MatchString(v) {
+			problems = append(problems, fmt.Sprintf("%s %q is not YYYY, YYYY-MM or YYYY-MM-DD", field, v))
+		}
+	}
+	checkURL := func(field, v string) {
+		if v == "" {
+			return
+		}
+		if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s %q is not an absolute URL", field, v))
+		}
+	}
+
+	if j.Basics.Email != "" {
+		if _, err := mail.ParseAddress(j.Basics.Email); err != nil {
+			problems = append(problems, fmt.Sprintf("basics.email %q is not a valid address", j.Basics.Email))
+		}
+	}
+	checkURL("basics.url", j.Basics.URL)
+	for i, p := range j.Basics.Profiles {
+		checkURL(fmt.Sprintf("basics.profiles[%d].url", i), p.URL)
+	}
+	for i, w := range j.Work {
+		checkDate(fmt.Sprintf("work[%d].startDate", i), w.StartDate)
+		checkDate(fmt.Sprintf("work[%d].endDate", i), w.EndDate)
+		checkURL(fmt.Sprintf("work[%d].url", i), w.URL)
+	}
+	for i, e := range j.Education {
+		checkDate(fmt.Sprintf("education[%d].startDate", i), e.StartDate)
+		checkDate(fmt.Sprintf("education[%d].endDate", i), e.EndDate)
+	}
+	for i, p := range j.Projects {
+		checkDate(fmt.Sprintf("projects[%d].startDate", i), p.StartDate)
+		checkDate(fmt.Sprintf("projects[%d].endDate", i), p.EndDate)
+		checkURL(fmt.Sprintf("projects[%d].url", i), p.URL)
+	}
+	if j.Meta != nil {
+		checkURL("meta.canonical", j.Meta.Canonical)
+	}
+
+	if len(problems) > 0 {
+		return &JSONResumeError{Problems: problems}
+	}
+	return nil
+}
+
+//
+// Field mapping helpers
+//
+
+func profileFromURL(network, raw string) models.JSONResumeProfile {
+	link := raw
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	username := ""
+	if u, err := url.Parse(link); err == nil {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		username = parts[len(parts)-1]
+	}
+	return models.JSONResumeProfile{Network: network, Username: username, URL: link}
+}
+
+func profileURL(p models.JSONResumeProfile, base string) string {
+	if p.URL != "" {
+		return p.URL
+	}
+	if p.Username != "" {
+		return base + p.Username
+	}
+	return ""
+}
+
+// isoDateRange converts a display range such as "Jan 2021 - Present" into
+// schema dates. A single date is the start of a role but the end of a degree.
+// Dates that cannot be read are left out.
+func isoDateRange(display string, singleIsEnd bool) (start, end string) {
+	display = strings.TrimSpace(display)
+	if display == "" {
+		return "", ""
+	}
+	if loc := dateRangeRe.FindStringIndex(display); loc != nil {
+		from, to, _ := strings.Cut(normalizeDash(display[loc[0]:loc[1]]), " - ")
+		return isoDate(from), isoDate(to) // "Present" has no end date
+	}
+	if singleIsEnd {
+		return "", isoDate(display)
+	}
+	return isoDate(display), ""
+}
+
+var (
+	monthYearRe = regexp.MustCompile(`(?i)^(?:expected\s+)?([a-z]{3})[a-z]*\.?\s*'?(\d{2}|\d{4})$`)
+	numericRe   = regexp.MustCompile(`^(\d{1,2})/(\d{2}|\d{4})$`)
+	yearRe      = regexp.MustCompile(`^(?:expected\s+)?((?:19|20)\d{2})$`)
+)
+
+// isoDate converts one display date ("Jan 2021", "06/2018", "2018") to
+// YYYY-MM or YYYY, or "" if it cannot be read.
+func isoDate(s string) string {
+	s = strings.TrimSpace(s)
+	if jsonResumeDateRe.MatchString(s) {
+		return s
+	}
+	if m := monthYearRe.FindStringSubmatch(s); m != nil {
+		if month, ok := monthNumbers[strings.ToLower(m[1])]; ok {
+			return fmt.Sprintf("%s-%02d", fullYear(m[2]), month)
+		}
+	}
+	if m := numericRe.FindStringSubmatch(s); m != nil {
+		if month, _ := strconv.Atoi(m[1]); month >= 1 && month <= 12 {
+			return fmt.Sprintf("%s-%02d", fullYear(m[2]), month)
+		}
+	}
+	if m := yearRe.FindStringSubmatch(strings.ToLower(s)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func fullYear(y string) string {
+	if len(y) == 2 {
+		return "20" + y
+	}
+	return y
+}
+
+// displayDateRange is the inverse of isoDateRange.
+func displayDateRange(start, end string) string {
+	start, end = displayDate(start), displayDate(end)
+	switch {
+	case start != "" && end != "":
+		return start + " - " + end
+	case start != "":
+		return start + " - Present"
+	}
+	return end
+}
+
+func displayDate(iso string) string {
+	iso = strings.TrimSpace(iso)
+	if !jsonResumeDateRe.MatchString(iso) {
+		return iso
+	}
+	if len(iso) == 4 {
+		return iso
+	}
+	t, err := time.Parse("2006-01", iso[:7])
+	if err != nil {
+		return iso
+	}
+	return t.Format("Jan 2006")
+}
+
+// splitDegree separates "B.S. Computer Science" or "Bachelor of Arts in
+// History" into the schema's studyType and area.
+func splitDegree(degree string) (studyType, area string) {
+	degree = strings.TrimSpace(degree)
+	if before, after, ok := strings.Cut(degree, " in "); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after)
+	}
+	if before, after, ok := strings.Cut(degree, ", "); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after)
+	}
+	if first, rest, ok := strings.Cut(degree, " "); ok && isDegreeAbbreviation(first) {
+		return first, strings.TrimSpace(rest)
+	}
+	return degree, ""
+}
+
+func joinDegree(studyType, area string) string {
+	studyType, area = strings.TrimSpace(studyType), strings.TrimSpace(area)
+	switch {
+	case studyType == "":
+		return area
+	case area == "":
+		return studyType
+	case isDegreeAbbreviation(studyType):
+		return studyType + " " + area
+	}
+	return studyType + " in " + area
+}
+
+// isDegreeAbbreviation matches forms like "B.S.", "BSc", "MBA" and "PhD".
+func isDegreeAbbreviation(s string) bool {
+	letters := strings.ReplaceAll(s, ".", "")
+	if len(letters) < 2 || len(letters) > 4 {
+		return false
+	}
+	return letters[0] >= 'A' && letters[0] <= 'Z' && containsAny(strings.ToLower(s)+" ", degreeWords)
+}
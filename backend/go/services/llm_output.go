@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"trackify-jobs/llm"
+	"trackify-jobs/models"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// ErrInvalidLLMOutput means the model kept replying in the wrong shape after
+// being shown what was wrong.
+var ErrInvalidLLMOutput = errors.New("model output did not match the expected format")
+
+// maxRepairAttempts is how many times a reply that fails its schema goes
+// back to the model, with the problems listed, before the call gives up.
+const maxRepairAttempts = 2
+
+// Limits the resume rewrite prompt asks for, enforced again in code because
+// models drift past them.
+const (
+	rewriteMaxSummaryWords    = 50
+	rewriteMaxBulletWords     = 20
+	rewriteMaxBulletsPerEntry = 3
+	rewriteMaxBullets         = 25
+	rewriteMaxSkillCategories = 4
+	rewriteMaxSkills          = 20
+)
+
+func stringArraySchema() jsonschema.Definition {
+	return jsonschema.Definition{Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}}
+}
+
+var suggestionsSchema = &llm.Schema{
+	Name:   "suggestions",
+	Strict: true,
+	Definition: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"missing_skills":       stringArraySchema(),
+			"keyword_gaps":         stringArraySchema(),
+			"experience_alignment": stringArraySchema(),
+			"general_advice":       stringArraySchema(),
+		},
+		Required:             []string{"missing_skills", "keyword_gaps", "experience_alignment", "general_advice"},
+		AdditionalProperties: false,
+	},
+}
+
+var coverLetterSchema = &llm.Schema{
+	Name:   "cover_letter",
+	Strict: true,
+	Definition: jsonschema.Definition{
+		Type:                 jsonschema.Object,
+		Properties:           map[string]jsonschema.Definition{"cover_letter": {Type: jsonschema.String}},
+		Required:             []string{"cover_letter"},
+		AdditionalProperties: false,
+	},
+}
+
+var emailClassificationSchema = &llm.Schema{
+	Name:   "email_classification",
+	Strict: true,
+	Definition: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"classification": {
+				Type: jsonschema.String,
+				Enum: []string{"rejection", "interview_request", "offer", "auto_ack", "unknown"},
+			},
+			"confidence": {Type: jsonschema.Number},
+		},
+		Required:             []string{"classification", "confidence"},
+		AdditionalProperties: false,
+	},
+}
+
+// structuredResumeSchema matches models.StructuredResume. Skills is keyed by
+// category, which strict mode cannot express.
+var structuredResumeSchema = func() *llm.Schema {
+	str := jsonschema.Definition{Type: jsonschema.String}
+	entry := func(fields ...string) jsonschema.Definition {
+		d := jsonschema.Definition{
+			Type:                 jsonschema.Object,
+			Properties:           map[string]jsonschema.Definition{},
+			Required:             fields,
+			AdditionalProperties: false,
+		}
+		for _, f := range fields {
+			d.Properties[f] = str
+		}
+		if fields[len(fields)-1] == "bullets" {
+			d.Properties["bullets"] = stringArraySchema()
+		}
+		return d
+	}
+	education := entry("degree", "school", "date")
+	experience := entry("title", "company", "date", "bullets")
+	projects := entry("name", "date", "bullets")
+	return &llm.Schema{
+		Name: "structured_resume",
+		Definition: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"name": str, "email": str, "phone": str, "linkedin": str, "github": str, "summary": str,
+				"education":  {Type: jsonschema.Array, Items: &education},
+				"experience": {Type: jsonschema.Array, Items: &experience},
+				"projects":   {Type: jsonschema.Array, Items: &projects},
+				"skills":     {Type: jsonschema.Object, AdditionalProperties: stringArraySchema()},
+			},
+			Required:             []string{"name", "email", "phone", "linkedin", "github", "summary", "education", "experience", "projects", "skills"},
+			AdditionalProperties: false,
+		},
+	}
+}()
+
+// generateJSON runs a feature whose reply must match schema and decodes it
+// into out. A reply that does not match is sent back with the problems
+// listed, up to maxRepairAttempts times.
+func (s *LLMService) generateJSON(ctx context.Context, feature, subject string, schema *llm.Schema, data map[string]interface{}, out interface{}, stream *Stream) (models.Generation, error) {
+	p, req, err := s.prepare(feature, subject, data)
+	if err != nil {
+		return models.Generation{}, err
+	}
+	req.JSON = true
+	req.Schema = schema
+
+	var gen models.Generation
+	for attempt := 0; ; attempt++ {
+		resp, err := s.run(ctx, p, req, stream)
+		if err != nil {
+			return gen, err
+		}
+		gen = models.Generation{Feature: feature, PromptVersion: p.Version, Model: resp.Model}
+
+		verr := schema.Validate(resp.Content)
+		if verr == nil {
+			if err := json.Unmarshal([]byte(resp.Content), out); err != nil {
+				return gen, fmt.Errorf("failed to parse LLM output: %w", err)
+			}
+			return gen, nil
+		}
+		var serr *llm.SchemaError
+		if !errors.As(verr, &serr) {
+			return gen, verr
+		}
+		if attempt == maxRepairAttempts {
+			return gen, fmt.Errorf("%w: %s (%d repair attempts)", ErrInvalidLLMOutput, strings.Join(serr.Problems, "; "), maxRepairAttempts)
+		}
+		log.Printf("LLM %s (prompt %s): reply failed validation, asking for a repair: %v", feature, p.Version, verr)
+		if stream != nil && stream.Retry != nil {
+			if err := stream.Retry(serr.Problems); err != nil {
+				return gen, err
+			}
+		}
+		req.Messages = append(req.Messages,
+			llm.Message{Role: llm.RoleAssistant, Content: resp.Content},
+			llm.Message{Role: llm.RoleUser, Content: repairMessage(serr.Problems)},
+		)
+	}
+}
+
+func repairMessage(problems []string) string {
+	var b strings.Builder
+	b.WriteString("Your reply does not match the required JSON format:\n")
+	for _, p := range problems {
+		b.WriteString("- " + p + "\n")
+	}
+	b.WriteString("\nReply again with only the corrected JSON object. Keep everything else as it was.")
+	return b.String()
+}
+
+// Post-processing. The prompts state these rules, but a model follows them
+// most of the time rather than always, so outputs are brought in line here.
+
+var dashRe = regexp.MustCompile(`\s*[—―]+\s*`)
+
+// stripDashes replaces em dashes, which the suggestion and cover letter
+// prompts forbid, with a comma.
+func stripDashes(s string) string {
+	s = dashRe.ReplaceAllString(s, ", ")
+	s = strings.NewReplacer(", .", ".", ", ,", ",", ", !", "!", ", ?", "?", ",\n", "\n").Replace(s)
+	return strings.TrimSuffix(strings.TrimPrefix(s, ", "), ", ")
+}
+
+func cleanList(items []string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(stripDashes(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func postprocessSuggestions(sg *models.Suggestions) {
+	sg.MissingSkills = cleanList(sg.MissingSkills)
+	sg.KeywordGaps = cleanList(sg.KeywordGaps)
+	sg.ExperienceAlignment = cleanList(sg.ExperienceAlignment)
+	sg.GeneralAdvice = cleanList(sg.GeneralAdvice)
+}
+
+func postprocessCoverLetter(cl *models.GeneratedCoverLetter) {
+	text := strings.ReplaceAll(cl.CoverLetter, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(stripDashes(line))
+	}
+	cl.CoverLetter = strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func postprocessClassification(c *EmailClassification) {
+	c.Confidence = min(max(c.Confidence, 0), 1)
+}
+
+// postprocessRewrite enforces the rewrite prompt's length limits: word
+// counts on the summary and bullets, bullets per entry and in total, and
+// the number of skill categories and skills.
+func postprocessRewrite(r *models.StructuredResume) {
+	r.Summary = limitWords(r.Summary, rewriteMaxSummaryWords)
+
+	budget := rewriteMaxBullets
+	trim := func(bullets []string) []string {
+		var out []string
+		for _, b := range bullets {
+			if len(out) == rewriteMaxBulletsPerEntry || budget == 0 {
+				break
+			}
+			if b = limitWords(b, rewriteMaxBulletWords); b != "" {
+				out = append(out, b)
+				budget--
+			}
+		}
+		return out
+	}
+	for i := range r.Experience {
+		r.Experience[i].Bullets = trim(r.Experience[i].Bullets)
+	}
+	for i := range r.Projects {
+		r.Projects[i].Bullets = trim(r.Projects[i].Bullets)
+	}
+
+	// Keep the largest categories, then fill the skill budget from them in
+	// that order, so a trimmed list keeps what the model emphasised most.
+	categories := make([]string, 0, len(r.Skills))
+	for c, skills := range r.Skills {
+		if strings.TrimSpace(c) != "" && len(skills) > 0 {
+			categories = append(categories, c)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := len(r.Skills[categories[i]]), len(r.Skills[categories[j]])
+		if a != b {
+			return a > b
+		}
+		return categories[i] < categories[j]
+	})
+	if len(categories) > rewriteMaxSkillCategories {
+		categories = categories[:rewriteMaxSkillCategories]
+	}
+	skills := map[string][]string{}
+	left := rewriteMaxSkills
+	for _, c := range categories {
+		list := r.Skills[c]
+		if len(list) > left {
+			list = list[:left]
+		}
+		if len(list) > 0 {
+			skills[c] = list
+			left -= len(list)
+		}
+	}
+	r.Skills = skills
+}
+
+// limitWords cuts s to at most n words, ending it as a sentence.
+func limitWords(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+	out := strings.TrimRight(strings.Join(words[:n], " "), ",;:-")
+	if !strings.HasSuffix(out, ".") {
+		out += "."
+	}
+	return out
+}
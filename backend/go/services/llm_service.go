@@ -4,288 +4,179 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"log"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	openai "github.com/sashabaranov/go-openai"
+	"trackify-jobs/llm"
+	"trackify-jobs/models"
+	"trackify-jobs/prompts"
 )
 
-type LLMService struct {
-	APIKey string
-}
-
-func NewLLMService() *LLMService {
-	return &LLMService{}
-}
+// Features name each kind of LLM call. Each has an entry in the prompt
+// config and a directory of prompt versions.
+const (
+	FeatureSuggestions         = "suggestions"
+	FeatureResumeRewrite       = "resume_rewrite"
+	FeatureCoverLetter         = "cover_letter"
+	FeatureEmailClassification = "email_classification"
+	FeatureResumeRepair        = "resume_repair"
+)
 
-type RewrittenResume struct {
-	Name      string `json:"name"`
-	Email     string `json:"email"`
-	Phone     string `json:"phone"`
-	LinkedIn  string `json:"linkedin"`
-	GitHub    string `json:"github"`
-	Summary   string `json:"summary"`
-	Education []struct {
-		Degree string `json:"degree"`
-		School string `json:"school"`
-		Date   string `json:"date"`
-	} `json:"education"`
-	Experience []struct {
-		Title   string   `json:"title"`
-		Company string   `json:"company"`
-		Date    string   `json:"date"`
-		Bullets []string `json:"bullets"`
-	} `json:"experience"`
-	Projects []struct {
-		Name    string   `json:"name"`
-		Date    string   `json:"date"`
-		Bullets []string `json:"bullets"`
-	} `json:"projects"`
-	Skills map[string][]string `json:"skills"`
+type LLMService struct {
+	Provider llm.Provider
+	Prompts  *prompts.Set
 }
 
-// Resume Suggestions
-func (s *LLMService) GetSuggestions(resumeText, jobDescription string) (json.RawMessage, error) {
-	client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-
-	systemPrompt := `You are an expert resume analyst. Your task is to review a candidate's resume and evaluate how well it aligns with a given job description. You will be talking directly to the candidate. You should use easy to understand and specific language.
-
-You must return **only** a valid JSON object in the exact format below:
-
-{
-  "missing_skills": string[],
-  "keyword_gaps": string[],
-  "experience_alignment": string[],
-  "general_advice": string[]
+func NewLLMService(provider llm.Provider, p *prompts.Set) *LLMService {
+	return &LLMService{Provider: provider, Prompts: p}
 }
 
-Definitions:
-- "missing_skills": Skills mentioned in the job description that are not clearly present in the resume.
-- "keyword_gaps": Relevant terms or phrases from the job description that are absent in the resume.
-- "experience_alignment": Specific ways the candidate's experience either matches or partially matches the job requirements.
-- "general_advice": Actionable suggestions for improving alignment, excluding skills or keywords.
-
-Strict instructions:
-- Do not fabricate experience or qualifications not present in the resume.
-- Be concrete and precise. Avoid generic or vague suggestions.
-- Do not include markdown, labels, commentary, or formatting outside the JSON object.
-- You must include at least 1 item per key.
-- Do not use em dashes or non-standard punctuation.
-- Output **only** the JSON object. Nothing more.`
-
-	userPrompt := fmt.Sprintf("Resume:\n%s\n\nJob Description:\n%s", resumeText, jobDescription)
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4Dot1Mini,
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-			},
-			Temperature: 0.3,
-		},
-	)
+// prepare renders the feature's prompt for subject (the user, for prompt
+// experiments; "" when there is none) as a request to the provider.
+func (s *LLMService) prepare(feature, subject string, data map[string]interface{}) (*prompts.Prompt, llm.Request, error) {
+	p, err := s.Prompts.Render(feature, subject, data)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI call failed: %w", err)
+		return nil, llm.Request{}, err
 	}
-
-	return json.RawMessage(resp.Choices[0].Message.Content), nil
+	return p, llm.Request{
+		Feature: feature,
+		Model:   p.Model,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: p.System},
+			{Role: llm.RoleUser, Content: p.User},
+		},
+		Temperature: p.Temperature,
+		MaxTokens:   p.MaxTokens,
+	}, nil
 }
 
-func (s *LLMService) RewriteResume(resumeText string, jobDescription string, missingSkills []string) (*RewrittenResume, error) {
-	client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-
-	systemPrompt := `You are a senior technical resume rewriting specialist.  
-Your job is to transform the provided resume into a **highly targeted, job-specific version** that excels with both **Applicant Tracking Systems (ATS)** and human recruiters.  
-This is a **complete rewrite**, not an edit — restructure and reword for maximum **relevance, precision, and measurable impact** based on the given job description.
-
----
-
-## Primary Goals
-1. **Tightly align** the resume with the provided job description.
-2. **Maximize ATS match** by integrating job-specific keywords, action verbs, and relevant domain terms naturally.
-3. Use **concise, results-focused language** that highlights measurable business value and technical execution.
-4. Maintain a **modern, one-page format** with ruthless prioritization of relevance.
-
----
-
-## Rewrite Rules
-- Rewrite the following sections entirely:  
-  summary, experience[].bullets, projects[].bullets, skills
-- Follow **X–Y–Z structure** for every bullet:  
-  - **X (Action):** What was done  
-  - **Y (Tool/Method):** How it was done  
-  - **Z (Impact):** Why it mattered or what it achieved
-- 1–3 bullets per role or project.  
-- Each bullet should contain **specific, measurable results** where reasonable. Never invent unsupported numbers.
-- Use **complete sentences**, max 20 words each.
-- Remove vague or soft-skill-focused bullets — prioritize **technical depth and results**.
-
----
-
-## Skill Integration
-You are given {{missingSkills}}.  
-These are critical skills from the job description that are missing or underemphasized in the original resume.  
-
-You must:
-- Include these skills wherever they can be **reasonably inferred** from the candidate’s work.  
-- Use **exact job description phrasing** when integrating them.  
-- Favor technical nouns and strong verbs (e.g., “Built RESTful APIs with Express.js” instead of “Developed backend”).  
-- Do not fabricate experience with skills that cannot be plausibly supported.
-- Ensure dates are all in the same format. Ex, Month Year - Month Year. If a project / experience only has one month year, modify it to be in the same format by adding 1 month to the start date, as the end date.
-- If a job entry lists the company as “Freelance” or similar (e.g., Contract, Independent Work), rewrite the company name as “Independent Contractor” or “Self-employed,” choosing the one that best fits the context. Ensure the job title, company name, location, and dates are on the same line for ATS readability.
-
----
-
-## Section-Specific Guidelines
-### Summary
-- ≤ 50 words.  
-- Include 8–10 job-relevant keywords from the job description.
-
-### Skills
-- Max 20 skills, grouped into **no more than 4 categories**.  
-- Categories should be relevant to the role.  
-- Skills must be comma-separated within each category.
-- Group skills into no more than 4 conventional resume skill category titles that are common across professional resumes (examples: Technical Skills, Tools & Software, Certifications & Training, Soft Skills). Use only widely accepted category names and avoid creative or niche headings.
-
-### Experience & Projects
-- Total combined bullets ≤ 25 across all roles and projects.  
-- Reverse chronological order.  
-- Combine or remove low-relevance entries.  
-- Emphasize tech stack, architecture, integrations, performance, deployment, and user impact.  
-- Reorder or relabel job titles if it improves clarity and alignment with the job description.
-
----
-
-## Prohibited
-- Keeping any original phrasing or structure.
-- Adding new top-level sections.
-- Outputting anything except the required JSON.
-
----
-
-## Output Format
-Return **only** a valid JSON object in this structure:
-
-{
-  "name": string,
-  "email": string,
-  "phone": string,
-  "linkedin": string,
-  "github": string,
-  "summary": string,
-  "education": [{degree: string, school: string, date: string}],
-  "experience": [
-    {
-      "title": string,
-      "company": string,
-      "date": string,
-      "bullets": string[]
-    }
-  ],
-  "projects": [
-    {
-      "name": string,
-      "date": string,
-      "bullets": string[]
-    }
-  ],
-  "skills": {category: string[]}
+// Stream follows a generation as the model writes it. Delta receives each
+// piece of the reply. Retry is called when a finished reply failed
+// validation and the model is being asked again: everything Delta received
+// so far is void, and the corrected reply follows. Either may be nil.
+type Stream struct {
+	Delta func(text string) error
+	Retry func(problems []string) error
 }
 
-`
-
-	missingSkillsText := strings.Join(missingSkills, ", ")
-
-	injectedPrompt := strings.Replace(systemPrompt, "{{missingSkills}}", missingSkillsText, 1)
-
-	userPrompt := fmt.Sprintf("Resume:\n%s\n\nJob Description:\n%s", resumeText, jobDescription)
-
+// run sends one request, streamed when stream has a Delta, and logs the model
+// and tokens it used.
+func (s *LLMService) run(ctx context.Context, p *prompts.Prompt, req llm.Request, stream *Stream) (*llm.Response, error) {
 	start := time.Now()
+	var resp *llm.Response
+	var err error
+	if stream != nil && stream.Delta != nil {
+		resp, err = s.Provider.Stream(ctx, req, stream.Delta)
+	} else {
+		resp, err = s.Provider.Complete(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LLM call failed: %w", err)
+	}
+	log.Printf("LLM %s (prompt %s): %s via %s, %d prompt + %d completion tokens in %s", p.Feature, p.Version, resp.Model,
+		s.Provider.Name(), resp.Usage.PromptTokens, resp.Usage.CompletionTokens, time.Since(start).Round(time.Millisecond))
+	return resp, nil
+}
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4Dot1Mini,
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: injectedPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-			},
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: "json_object",
-			},
-		},
-	)
-
-	duration := time.Since(start)
-	fmt.Printf("OpenAI call took %s\n", duration)
-
+// RewrittenResume is the rewrite's output, in the same schema the parser produces.
+type RewrittenResume = models.StructuredResume
+
+// GetSuggestions compares a resume with a job description. A non-nil stream
+// follows the reply as it is written; the returned value is still the
+// validated, post-processed result.
+func (s *LLMService) GetSuggestions(ctx context.Context, userID, resumeText, jobDescription string, stream *Stream) (*models.Suggestions, models.Generation, error) {
+	var out models.Suggestions
+	gen, err := s.generateJSON(ctx, FeatureSuggestions, userID, suggestionsSchema, map[string]interface{}{
+		"Resume":         resumeText,
+		"JobDescription": jobDescription,
+	}, &out, stream)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI call failed: %w", err)
+		return nil, gen, err
 	}
+	postprocessSuggestions(&out)
+	return &out, gen, nil
+}
 
+func (s *LLMService) RewriteResume(ctx context.Context, userID, resumeText, jobDescription string, missingSkills []string, stream *Stream) (*RewrittenResume, models.Generation, error) {
 	var rewritten RewrittenResume
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &rewritten); err != nil {
-		return nil, fmt.Errorf("failed to parse LLM output: %w", err)
+	gen, err := s.generateJSON(ctx, FeatureResumeRewrite, userID, structuredResumeSchema, map[string]interface{}{
+		"Resume":         resumeText,
+		"JobDescription": jobDescription,
+		"MissingSkills":  strings.Join(missingSkills, ", "),
+	}, &rewritten, stream)
+	if err != nil {
+		return nil, gen, err
 	}
-	return &rewritten, nil
+	postprocessRewrite(&rewritten)
+	return &rewritten, gen, nil
 }
 
-func (s *LLMService) GenerateCoverLetter(resumeText, jobDescription string) (json.RawMessage, error) {
-	client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-
-	systemPrompt := `You are a career coach writing professional, personalized cover letters tailored to the applicant’s resume and the job description.
-
-Your task is to create a cover letter that clearly demonstrates why the applicant is a strong fit for the role, using relevant experience, skills, and motivations drawn from the resume and **clearly aligned with the job description**.
-
-### Writing Guidelines:
-- Do **not** fabricate experience, qualifications, or skills that are not clearly supported by the resume.
-- The tone must be professional, confident, and **human**—not inflated, robotic, or overly formal.
-- Write in a way that sounds like a real person: natural, direct, and thoughtfully worded.
-- The letter must reflect genuine interest in the specific job, not read like a generic template.
-- Rephrase and contextualize resume content rather than copying it verbatim.
-- **Incorporate specific language, responsibilities, tools, or qualifications from the job description** to show the applicant read and understood the role.
-- Mention aspects of the job or company that align with the applicant’s background or interests when possible.
-- Avoid vague platitudes or empty enthusiasm. Focus on substance: what the candidate offers, and why they are well-matched to this job.
-- Never include an em dash or any form of dash to separate sentences. You must write using human language.
-
-### Output Format:
-Return only a valid JSON object with a single field:
-{
-  "cover_letter": "string"
+// GenerateCoverLetter drafts a letter for the job, streamed like
+// GetSuggestions.
+func (s *LLMService) GenerateCoverLetter(ctx context.Context, userID, resumeText, jobDescription string, stream *Stream) (*models.GeneratedCoverLetter, models.Generation, error) {
+	var out models.GeneratedCoverLetter
+	gen, err := s.generateJSON(ctx, FeatureCoverLetter, userID, coverLetterSchema, map[string]interface{}{
+		"Resume":         resumeText,
+		"JobDescription": jobDescription,
+	}, &out, stream)
+	if err != nil {
+		return nil, gen, err
+	}
+	postprocessCoverLetter(&out)
+	return &out, gen, nil
 }
 
-The cover letter should include:
-- A greeting
-- A brief, specific opening paragraph (1–2 sentences)
-- A body section (1–2 concise paragraphs) that connects the applicant’s background to the job requirements
-- A short closing paragraph and signoff
-
-Use newline characters (\n) between logical sections.
-
-Never use this character: —.
-Never use an em dash to break up a sentence or punctuation separator. Use regular punctuation.
+// EmailClassification is the LLM's verdict on an inbound recruiter email.
+type EmailClassification struct {
+	Classification string            `json:"classification"`
+	Confidence     float64           `json:"confidence"`
+	Generation     models.Generation `json:"-"`
+}
 
-Do **not** include explanations, markdown, labels, or anything outside the JSON object. Output only the JSON.`
+// ClassifyRecruiterEmail is the fallback for emails the phrase rules cannot place.
+func (s *LLMService) ClassifyRecruiterEmail(ctx context.Context, subject, body string) (*EmailClassification, error) {
+	// Keep the request small; the decision is almost always in the first paragraphs.
+	// Cut on a rune boundary so the provider never gets invalid UTF-8.
+	if len(body) > 4000 {
+		cut := 4000
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut]
+	}
 
-	userPrompt := fmt.Sprintf("Resume:\n%s\n\nJob Description:\n%s", resumeText, jobDescription)
+	var result EmailClassification
+	gen, err := s.generateJSON(ctx, FeatureEmailClassification, "", emailClassificationSchema, map[string]interface{}{
+		"Subject": subject,
+		"Body":    body,
+	}, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Generation = gen
+	postprocessClassification(&result)
+	return &result, nil
+}
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4Dot1Nano,
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-			},
-			Temperature: 0.4,
-		},
-	)
+// RepairStructuredResume asks the model to correct a heuristic parse against
+// the source text. Only fields the parser was unsure about are listed in
+// weakFields; the model is told to leave the others alone.
+func (s *LLMService) RepairStructuredResume(ctx context.Context, resumeText string, draft *models.StructuredResume, weakFields []string) (*models.StructuredResume, models.Generation, error) {
+	draftJSON, err := json.Marshal(draft)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI call failed: %w", err)
+		return nil, models.Generation{}, err
 	}
 
-	return json.RawMessage(resp.Choices[0].Message.Content), nil
+	var repaired models.StructuredResume
+	gen, err := s.generateJSON(ctx, FeatureResumeRepair, "", structuredResumeSchema, map[string]interface{}{
+		"Resume":          resumeText,
+		"Draft":           string(draftJSON),
+		"UncertainFields": strings.Join(weakFields, ", "),
+	}, &repaired, nil)
+	if err != nil {
+		return nil, gen, err
+	}
+	return &repaired, gen, nil
 }
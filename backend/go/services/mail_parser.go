@@ -0,0 +1,384 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxMailBodyBytes caps how much of a single MIME part is read.
+const maxMailBodyBytes = 1 << 20
+
+// ParsedEmail is the normalized form of an email, whether it arrived as raw
+// RFC 822 or as a provider's inbound webhook JSON.
+type ParsedEmail struct {
+	MessageID  string
+	InReplyTo  string
+	References []string
+	FromName   string
+	FromEmail  string
+	To         []string // To, Cc and delivery headers, lowercased
+	Subject    string
+	Date       time.Time
+	Text       string // plain text body with quoted replies removed
+
+	// GmailThreadID is the X-GM-THRID header Google Takeout adds to mbox exports.
+	GmailThreadID string
+}
+
+var mailWordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// ParseRawEmail parses an RFC 822 message, preferring the text/plain part and
+// falling back to a stripped text/html part.
+func ParseRawEmail(r io.Reader) (*ParsedEmail, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC 822 message: %w", err)
+	}
+
+	h := msg.Header
+	parsed := &ParsedEmail{
+		MessageID:  trimMessageID(h.Get("Message-Id")),
+		InReplyTo:  trimMessageID(h.Get("In-Reply-To")),
+		References: splitMessageIDs(h.Get("References")),
+		Subject:    decodeMailHeader(h.Get("Subject")),
+
+		GmailThreadID: strings.TrimSpace(h.Get("X-Gm-Thrid")),
+	}
+
+	addressParser := &mail.AddressParser{WordDecoder: mailWordDecoder}
+	if from, err := addressParser.Parse(h.Get("From")); err == nil {
+		parsed.FromName, parsed.FromEmail = from.Name, strings.ToLower(from.Address)
+	}
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To", "X-Forwarded-To"} {
+		for _, value := range h[key] {
+			list, err := addressParser.ParseList(value)
+			if err != nil {
+				continue
+			}
+			for _, a := range list {
+				parsed.To = appendUnique(parsed.To, strings.ToLower(a.Address))
+			}
+		}
+	}
+	if date, err := h.Date(); err == nil {
+		parsed.Date = date
+	}
+
+	plain, htmlBody, err := readMailBody(h.Get("Content-Type"), h.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	parsed.Text = mailBodyText(plain, htmlBody)
+	parsed.normalize()
+	return parsed, nil
+}
+
+// stringList unmarshals either a JSON string ("a@x, b@y") or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		for _, part := range strings.Split(single, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				*l = append(*l, part)
+			}
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// inboundWebhookPayload accepts a generic JSON shape and Postmark's field names.
+// If Raw is set it is parsed as a full RFC 822 message and everything else is ignored.
+type inboundWebhookPayload struct {
+	Raw        string     `json:"raw"`
+	From       string     `json:"from"`
+	To         stringList `json:"to"`
+	Cc         stringList `json:"cc"`
+	Subject    string     `json:"subject"`
+	Text       string     `json:"text"`
+	HTML       string     `json:"html"`
+	MessageID  string     `json:"message_id"`
+	InReplyTo  string     `json:"in_reply_to"`
+	References string     `json:"references"`
+	Date       string     `json:"date"`
+
+	// Postmark inbound
+	TextBody          string `json:"TextBody"`
+	HtmlBody          string `json:"HtmlBody"`
+	PostmarkMessageID string `json:"MessageID"`
+	OriginalRecipient string `json:"OriginalRecipient"`
+}
+
+// ParseInboundWebhook parses a provider's inbound webhook JSON.
+func ParseInboundWebhook(data []byte) (*ParsedEmail, error) {
+	var p inboundWebhookPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid inbound payload: %w", err)
+	}
+	if p.Raw != "" {
+		return ParseRawEmail(strings.NewReader(p.Raw))
+	}
+
+	parsed := &ParsedEmail{
+		MessageID:  trimMessageID(firstNonEmpty(p.MessageID, p.PostmarkMessageID)),
+		InReplyTo:  trimMessageID(p.InReplyTo),
+		References: splitMessageIDs(p.References),
+		Subject:    p.Subject,
+		Text:       mailBodyText(firstNonEmpty(p.Text, p.TextBody), firstNonEmpty(p.HTML, p.HtmlBody)),
+	}
+	if from, err := mail.ParseAddress(p.From); err == nil {
+		parsed.FromName, parsed.FromEmail = from.Name, strings.ToLower(from.Address)
+	} else {
+		parsed.FromEmail = strings.ToLower(strings.TrimSpace(p.From))
+	}
+	for _, raw := range append(append(append([]string{}, p.To...), p.Cc...), p.OriginalRecipient) {
+		if a, err := mail.ParseAddress(raw); err == nil {
+			parsed.To = appendUnique(parsed.To, strings.ToLower(a.Address))
+		}
+	}
+	if date, err := mail.ParseDate(p.Date); err == nil {
+		parsed.Date = date
+	} else if date, err := time.Parse(time.RFC3339, p.Date); err == nil {
+		parsed.Date = date
+	}
+
+	parsed.normalize()
+	return parsed, nil
+}
+
+// ThreadID returns the root message of the conversation this email belongs to.
+func (e *ParsedEmail) ThreadID() string {
+	if e.GmailThreadID != "" {
+		return "gmail:" + e.GmailThreadID
+	}
+	if len(e.References) > 0 {
+		return e.References[0]
+	}
+	if e.InReplyTo != "" {
+		return e.InReplyTo
+	}
+	return e.MessageID
+}
+
+// FromDomain returns the lowercased domain of the sender address.
+func (e *ParsedEmail) FromDomain() string {
+	if i := strings.LastIndexByte(e.FromEmail, '@'); i >= 0 {
+		return e.FromEmail[i+1:]
+	}
+	return ""
+}
+
+func (e *ParsedEmail) normalize() {
+	if e.MessageID == "" {
+		// Synthesize a stable ID so redelivery is still deduplicated. It is
+		// built from the content only: the date may be missing, and the
+		// fallback below changes on every delivery.
+		e.MessageID = fmt.Sprintf("generated-%x", hashString(e.FromEmail+"\x00"+e.Subject+"\x00"+e.Text))
+	}
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+}
+
+// readMailBody walks a (possibly nested multipart) body and returns the first
+// text/plain and text/html parts, skipping attachments.
+func readMailBody(contentType, transferEncoding string, body io.Reader) (plain, htmlBody string, err error) {
+	mediaType, params, perr := mime.ParseMediaType(contentType)
+	if perr != nil || contentType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return plain, htmlBody, fmt.Errorf("invalid multipart body: %w", err)
+			}
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			p, h, err := readMailBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return plain, htmlBody, err
+			}
+			if plain == "" {
+				plain = p
+			}
+			if htmlBody == "" {
+				htmlBody = h
+			}
+		}
+		return plain, htmlBody, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+
+	var reader io.Reader = io.LimitReader(body, maxMailBodyBytes)
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		reader = quotedprintable.NewReader(reader)
+	case "base64":
+		reader = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: reader})
+	}
+	if cr, err := charsetReader(params["charset"], reader); err == nil {
+		reader = cr
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode body: %w", err)
+	}
+	if mediaType == "text/html" {
+		return "", string(data), nil
+	}
+	return string(data), "", nil
+}
+
+// newlineStripper removes CR and LF so wrapped base64 bodies decode.
+type newlineStripper struct{ r io.Reader }
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	out := p[:0]
+	for _, b := range p[:count] {
+		if b != '\r' && b != '\n' {
+			out = append(out, b)
+		}
+	}
+	return len(out), err
+}
+
+// charsetReader handles the charsets recruiting systems actually send. Latin-1
+// and Windows-1252 are decoded byte-for-byte, which is close enough for classification.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+var (
+	htmlDropPattern  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreakPattern = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h[1-6])[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]+>`)
+	blankRunPattern  = regexp.MustCompile(`\n{3,}`)
+	replyHeaderLine  = regexp.MustCompile(`(?i)^(on .+ wrote:|-+ ?original message ?-+|from: .+)$`)
+)
+
+// htmlToText reduces an HTML body to readable plain text.
+func htmlToText(s string) string {
+	s = htmlDropPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankRunPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func mailBodyText(plain, htmlBody string) string {
+	text := plain
+	if strings.TrimSpace(text) == "" {
+		text = htmlToText(htmlBody)
+	}
+	return stripQuotedReply(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// stripQuotedReply drops the quoted history below a reply so classification only
+// sees what the recruiter wrote.
+func stripQuotedReply(text string) string {
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if replyHeaderLine.MatchString(trimmed) && len(kept) > 0 {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func decodeMailHeader(s string) string {
+	decoded, err := mailWordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
+
+func trimMessageID(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
+
+func splitMessageIDs(s string) []string {
+	var ids []string
+	for _, field := range strings.Fields(s) {
+		if id := trimMessageID(field); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func hashString(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:8]
+}
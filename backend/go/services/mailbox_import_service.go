@@ -0,0 +1,643 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"trackify-jobs/database"
+	"trackify-jobs/models"
+)
+
+// maxImportMessageBytes skips individual messages larger than this (usually
+// attachments we do not need).
+const maxImportMessageBytes = 5 << 20
+
+// importProgressEvery is how many messages are scanned between progress writes.
+const importProgressEvery = 50
+
+// staleImportAge is how long an import may stay unfinished before a restart
+// gives up on it. Scans take minutes; an import this old whose staged file is
+// not on this instance is not going to finish.
+const staleImportAge = 2 * time.Hour
+
+// MailboxImportService scans mbox and .eml exports for job applications and
+// turns them into drafts for the user to review.
+type MailboxImportService struct {
+	DB     *database.PostgresDB
+	Folder string // uploads are staged here until the scan finishes
+}
+
+func NewMailboxImportService(db *database.PostgresDB, folder string) *MailboxImportService {
+	return &MailboxImportService{DB: db, Folder: folder}
+}
+
+// StartImport stages the upload on disk and scans it in the background.
+// The returned import can be polled for progress.
+func (s *MailboxImportService) StartImport(userID, filename string, upload io.Reader) (*models.MailboxImport, error) {
+	imp, err := s.DB.CreateMailboxImport(&models.MailboxImport{UserID: userID, Filename: filename})
+	if err != nil {
+		return nil, err
+	}
+
+	dir := s.stagingDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		s.DB.FinishMailboxImport(imp.ID, 0, "failed to stage upload")
+		return nil, err
+	}
+	path := filepath.Join(dir, imp.ID)
+
+	out, err := os.Create(path)
+	if err != nil {
+		s.DB.FinishMailboxImport(imp.ID, 0, "failed to stage upload")
+		return nil, err
+	}
+	_, err = io.Copy(out, upload)
+	out.Close()
+	if err != nil {
+		os.Remove(path)
+		s.DB.FinishMailboxImport(imp.ID, 0, "failed to stage upload")
+		return nil, err
+	}
+
+	go s.run(imp.ID, userID, path)
+	return imp, nil
+}
+
+// ResumeImports picks up imports that a restart interrupted; call it once at
+// startup. An import whose staged file is still here is scanned again from
+// the start (drafts are upserted, so nothing is doubled). One that is older
+// than staleImportAge and has no staged file here is marked failed. Staged
+// files that no unfinished import owns are deleted.
+func (s *MailboxImportService) ResumeImports() error {
+	imports, err := s.DB.GetUnfinishedMailboxImports()
+	if err != nil {
+		return fmt.Errorf("error loading unfinished mailbox imports: %w", err)
+	}
+
+	dir := s.stagingDir()
+	owned := make(map[string]bool, len(imports))
+	for _, imp := range imports {
+		path := filepath.Join(dir, imp.ID)
+		if _, err := os.Stat(path); err == nil {
+			owned[imp.ID] = true
+			log.Printf("Resuming mailbox import %s", imp.ID)
+			go s.run(imp.ID, imp.UserID, path)
+			continue
+		}
+		if time.Since(imp.CreatedAt) > staleImportAge {
+			log.Printf("Mailbox import %s was interrupted and its upload is gone, marking it failed", imp.ID)
+			if err := s.DB.FinishMailboxImport(imp.ID, imp.ProcessedMessages, "import was interrupted, please upload the file again"); err != nil {
+				log.Printf("Failed to finish mailbox import %s: %v", imp.ID, err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !owned[e.Name()] {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return nil
+}
+
+// stagingDir holds uploads while they are scanned, each named by import ID.
+func (s *MailboxImportService) stagingDir() string {
+	return filepath.Join(s.Folder, "imports")
+}
+
+func (s *MailboxImportService) GetImport(id, userID string) (*models.MailboxImport, error) {
+	imp, err := s.DB.GetMailboxImport(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	imp.Drafts, err = s.DB.GetJobDraftsByImportID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return imp, nil
+}
+
+func (s *MailboxImportService) GetUserImports(userID string) ([]models.MailboxImport, error) {
+	return s.DB.GetMailboxImportsByUserID(userID)
+}
+
+// AcceptDraft turns a pending draft into a tracked job. Non-empty fields in
+// overrides replace the values the scanner guessed.
+func (s *MailboxImportService) AcceptDraft(id int, importID, userID string, overrides models.Job) (*models.Job, error) {
+	draft, err := s.DB.GetPendingJobDraft(id, importID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := fmt.Sprintf("Imported from email: %q from %s", draft.Subject, draft.FromAddress)
+	if draft.AppliedAt != nil {
+		notes += fmt.Sprintf(" (applied %s)", draft.AppliedAt.Format("2006-01-02"))
+	}
+
+	job := &models.Job{
+		UserID:   userID,
+		Title:    firstNonEmpty(overrides.Title, draft.Title),
+		Company:  firstNonEmpty(overrides.Company, draft.Company),
+		Location: overrides.Location,
+		Status:   firstNonEmpty(overrides.Status, draft.JobStatus),
+		Notes:    firstNonEmpty(overrides.Notes, notes),
+		URL:      firstNonEmpty(overrides.URL, draft.ThreadURL),
+	}
+	return s.DB.AcceptJobDraft(draft.ID, job)
+}
+
+func (s *MailboxImportService) DismissDraft(id int, importID, userID string) error {
+	return s.DB.DismissJobDraft(id, importID, userID)
+}
+
+// run scans the staged file, writing progress as it goes, then stores the drafts.
+func (s *MailboxImportService) run(importID, userID, path string) {
+	defer os.Remove(path)
+
+	processed := 0
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Mailbox import %s panicked: %v", importID, r)
+			s.DB.FinishMailboxImport(importID, processed, "internal error while scanning mailbox")
+		}
+	}()
+
+	total, err := countMailboxMessages(path)
+	if err != nil {
+		s.DB.FinishMailboxImport(importID, 0, err.Error())
+		return
+	}
+	if err := s.DB.UpdateMailboxImportProgress(importID, "processing", total, 0); err != nil {
+		log.Printf("Failed to update mailbox import %s: %v", importID, err)
+	}
+
+	threads := make(map[string]*threadDraft)
+	err = forEachMailboxMessage(path, func(raw []byte) {
+		processed++
+		if email, err := ParseRawEmail(bytes.NewReader(raw)); err == nil {
+			collectThreadDraft(threads, email)
+		}
+		if processed%importProgressEvery == 0 {
+			if err := s.DB.UpdateMailboxImportProgress(importID, "processing", total, processed); err != nil {
+				log.Printf("Failed to update mailbox import %s: %v", importID, err)
+			}
+		}
+	})
+	if err != nil {
+		s.DB.FinishMailboxImport(importID, processed, err.Error())
+		return
+	}
+
+	for _, draft := range mergeThreadDrafts(threads) {
+		draft.ImportID, draft.UserID = importID, userID
+		if err := s.DB.UpsertJobDraft(draft); err != nil {
+			s.DB.FinishMailboxImport(importID, processed, "failed to save drafts")
+			log.Printf("Mailbox import %s: %v", importID, err)
+			return
+		}
+	}
+
+	if err := s.DB.FinishMailboxImport(importID, processed, ""); err != nil {
+		log.Printf("Failed to finish mailbox import %s: %v", importID, err)
+	}
+}
+
+//
+// Mailbox readers
+//
+
+type mailboxFormat int
+
+const (
+	formatEML mailboxFormat = iota
+	formatMbox
+	formatZip
+)
+
+func detectMailboxFormat(path string) (mailboxFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 5)
+	n, _ := io.ReadFull(f, head)
+	switch {
+	case n >= 4 && bytes.Equal(head[:4], []byte("PK\x03\x04")):
+		return formatZip, nil
+	case n == 5 && string(head) == "From ":
+		return formatMbox, nil
+	}
+	return formatEML, nil
+}
+
+func countMailboxMessages(path string) (int, error) {
+	format, err := detectMailboxFormat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	switch format {
+	case formatZip:
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return 0, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		defer r.Close()
+		count := 0
+		for _, f := range r.File {
+			if isEMLEntry(f) {
+				count++
+			}
+		}
+		return count, nil
+	case formatMbox:
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		count := 0
+		err = scanMboxLines(f, func(line []byte, separator bool) {
+			if separator {
+				count++
+			}
+		})
+		return count, err
+	}
+	return 1, nil
+}
+
+// forEachMailboxMessage calls fn with the raw bytes of every message in the file.
+func forEachMailboxMessage(path string, fn func(raw []byte)) error {
+	format, err := detectMailboxFormat(path)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case formatZip:
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("invalid zip archive: %w", err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if !isEMLEntry(f) || f.UncompressedSize64 > maxImportMessageBytes {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			raw, err := io.ReadAll(io.LimitReader(rc, maxImportMessageBytes))
+			rc.Close()
+			if err == nil {
+				fn(raw)
+			}
+		}
+		return nil
+
+	case formatMbox:
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var msg bytes.Buffer
+		oversized := false
+		flush := func() {
+			if msg.Len() > 0 && !oversized {
+				fn(msg.Bytes())
+			}
+			msg.Reset()
+			oversized = false
+		}
+		err = scanMboxLines(f, func(line []byte, separator bool) {
+			if separator {
+				flush()
+				return
+			}
+			if oversized {
+				return
+			}
+			msg.Write(unescapeMboxLine(line))
+			msg.WriteString("\r\n")
+			if msg.Len() > maxImportMessageBytes {
+				oversized = true
+			}
+		})
+		flush()
+		return err
+	}
+
+	// A single message: read no more of it than any other message is allowed.
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(io.LimitReader(f, maxImportMessageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(raw) > maxImportMessageBytes {
+		return fmt.Errorf("email is larger than %d MB", maxImportMessageBytes>>20)
+	}
+	fn(raw)
+	return nil
+}
+
+func isEMLEntry(f *zip.File) bool {
+	return !f.FileInfo().IsDir() && strings.EqualFold(filepath.Ext(f.Name), ".eml") &&
+		!strings.HasPrefix(filepath.Base(f.Name), "._") // macOS resource forks
+}
+
+// scanMboxLines walks an mbox file line by line. A "From " line at the start of
+// the file or after a blank line separates messages.
+func scanMboxLines(r io.Reader, fn func(line []byte, separator bool)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	prevBlank := true
+	for scanner.Scan() {
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte("\r"))
+		separator := prevBlank && bytes.HasPrefix(line, []byte("From "))
+		fn(line, separator)
+		prevBlank = len(line) == 0
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read mbox: %w", err)
+	}
+	return nil
+}
+
+// unescapeMboxLine reverses mboxrd quoting (">From " -> "From ").
+func unescapeMboxLine(line []byte) []byte {
+	trimmed := bytes.TrimLeft(line, ">")
+	if len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+		return line[1:]
+	}
+	return line
+}
+
+//
+// Draft extraction
+//
+
+// threadDraft accumulates what the scanner learned about one email thread.
+type threadDraft struct {
+	Key         string
+	Company     string
+	Title       string
+	AppliedAt   *time.Time
+	FirstSeen   time.Time
+	JobStatus   string
+	StatusDate  time.Time
+	Subject     string
+	FromAddress string
+	ThreadURL   string
+	Count       int
+}
+
+var (
+	subjectTitleAtCompany = regexp.MustCompile(`(?i)(?:application for|applying for|applied for|interest in) (?:the )?(.{2,80}?) (?:position |role |opening )?at (.{2,60}?)[!.]?$`)
+	subjectCompanyOnly    = regexp.MustCompile(`(?i)(?:thank you|thanks) for (?:applying|your application|your interest) (?:to|in|at|with) (.{2,60}?)[!.]?$`)
+	subjectApplicationTo  = regexp.MustCompile(`(?i)your application (?:to|at|with) (.{2,60}?)[!.]?$`)
+	subjectApplicationFor = regexp.MustCompile(`(?i)your application for (?:the )?(.{2,80}?)(?: position| role)?[!.]?$`)
+	subjectCompanyPrefix  = regexp.MustCompile(`(?i)^(.{2,60}?)\s*[-|:]\s*application (?:received|confirmation|submitted)`)
+	subjectTitleSuffix    = regexp.MustCompile(`(?i)application (?:received|confirmation|submitted)\s*[-|:]\s*(.{2,80})$`)
+	bodyTitleAtCompany    = regexp.MustCompile(`(?:[Ff]or|[Tt]o) the ([^\n.,!]{2,80}?) (?:position|role|opening) at ([^\n.,!]{2,60})`)
+	bodyInterestCompany   = regexp.MustCompile(`(?i:interest in) ([A-Z][^\n.,!]{1,60})`)
+	replyPrefix           = regexp.MustCompile(`(?i)^((re|fw|fwd)\s*:\s*)+`)
+	senderNoise           = regexp.MustCompile(`(?i)\b(recruiting|recruitment|careers?|talent|acquisition|hiring|team|jobs|hr|people|no-?reply|notifications?)\b|\bvia \w+|@\s*\w+`)
+)
+
+// collectThreadDraft folds one message into the draft for its thread.
+func collectThreadDraft(threads map[string]*threadDraft, email *ParsedEmail) {
+	class, confidence := ClassifyEmailHeuristic(email.Subject, email.Text)
+	company, title := extractApplicationDetails(email)
+
+	key := email.ThreadID()
+	t, seen := threads[key]
+	if !seen {
+		// Only start a thread from messages that look like hiring mail.
+		if class == models.EmailClassUnknown || confidence < 0.3 {
+			return
+		}
+		t = &threadDraft{Key: key, FirstSeen: email.Date, Subject: email.Subject, FromAddress: email.FromEmail}
+		threads[key] = t
+	}
+
+	t.Count++
+	if t.Company == "" {
+		t.Company = company
+	}
+	if t.Title == "" {
+		t.Title = title
+	}
+	if email.Date.Before(t.FirstSeen) {
+		t.FirstSeen = email.Date
+	}
+	if class == models.EmailClassAutoAck && (t.AppliedAt == nil || email.Date.Before(*t.AppliedAt)) {
+		date := email.Date
+		t.AppliedAt = &date
+	}
+	if status := statusForClassification(class); status != "" && !email.Date.Before(t.StatusDate) {
+		t.JobStatus, t.StatusDate = status, email.Date
+	}
+	if t.ThreadURL == "" && email.GmailThreadID != "" {
+		if id, err := strconv.ParseUint(email.GmailThreadID, 10, 64); err == nil {
+			t.ThreadURL = fmt.Sprintf("https://mail.google.com/mail/u/0/#all/%x", id)
+		}
+	}
+}
+
+// mergeThreadDrafts drops threads without a company and merges threads about the
+// same application, e.g. an ATS acknowledgement and a later recruiter thread.
+// Threads without a title join the first titled thread for their company.
+func mergeThreadDrafts(threads map[string]*threadDraft) []*models.JobDraft {
+	keys := make([]string, 0, len(threads))
+	for key := range threads {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return threads[keys[i]].FirstSeen.Before(threads[keys[j]].FirstSeen) })
+
+	type companyGroup struct {
+		byTitle  map[string]*threadDraft
+		titles   []string
+		untitled *threadDraft
+	}
+	groups := make(map[string]*companyGroup)
+	var companies []string
+
+	for _, key := range keys {
+		t := *threads[key]
+		if t.Company == "" {
+			continue
+		}
+		company := normalizeCompany(t.Company)
+		g, ok := groups[company]
+		if !ok {
+			g = &companyGroup{byTitle: make(map[string]*threadDraft)}
+			groups[company] = g
+			companies = append(companies, company)
+		}
+
+		if t.Title == "" {
+			if g.untitled == nil {
+				g.untitled = &t
+			} else {
+				absorbThreadDraft(g.untitled, &t)
+			}
+			continue
+		}
+		title := normalizeCompany(t.Title)
+		if existing, ok := g.byTitle[title]; ok {
+			absorbThreadDraft(existing, &t)
+			continue
+		}
+		g.byTitle[title] = &t
+		g.titles = append(g.titles, title)
+	}
+
+	var drafts []*models.JobDraft
+	for _, company := range companies {
+		g := groups[company]
+		var merged []*threadDraft
+		for _, title := range g.titles {
+			merged = append(merged, g.byTitle[title])
+		}
+		if g.untitled != nil {
+			if len(merged) > 0 {
+				absorbThreadDraft(merged[0], g.untitled)
+			} else {
+				merged = append(merged, g.untitled)
+			}
+		}
+
+		for _, t := range merged {
+			applied := t.AppliedAt
+			if applied == nil {
+				first := t.FirstSeen
+				applied = &first
+			}
+			drafts = append(drafts, &models.JobDraft{
+				Company:      t.Company,
+				Title:        t.Title,
+				JobStatus:    firstNonEmpty(t.JobStatus, models.JobStatusApplied),
+				AppliedAt:    applied,
+				ThreadID:     t.Key,
+				ThreadURL:    t.ThreadURL,
+				Subject:      t.Subject,
+				FromAddress:  t.FromAddress,
+				MessageCount: t.Count,
+			})
+		}
+	}
+	return drafts
+}
+
+// absorbThreadDraft folds src into dst, keeping the earliest application date
+// and the most recent status.
+func absorbThreadDraft(dst, src *threadDraft) {
+	dst.Count += src.Count
+	if src.AppliedAt != nil && (dst.AppliedAt == nil || src.AppliedAt.Before(*dst.AppliedAt)) {
+		dst.AppliedAt = src.AppliedAt
+	}
+	if src.FirstSeen.Before(dst.FirstSeen) {
+		dst.FirstSeen = src.FirstSeen
+	}
+	if src.JobStatus != "" && !src.StatusDate.Before(dst.StatusDate) {
+		dst.JobStatus, dst.StatusDate = src.JobStatus, src.StatusDate
+	}
+	if dst.ThreadURL == "" {
+		dst.ThreadURL = src.ThreadURL
+	}
+}
+
+// extractApplicationDetails guesses the company and job title from the subject,
+// then the body, then the sender.
+func extractApplicationDetails(email *ParsedEmail) (company, title string) {
+	subject := strings.TrimSpace(replyPrefix.ReplaceAllString(email.Subject, ""))
+
+	if m := subjectTitleAtCompany.FindStringSubmatch(subject); m != nil {
+		title, company = m[1], m[2]
+	} else if m := subjectCompanyOnly.FindStringSubmatch(subject); m != nil {
+		company = m[1]
+	} else if m := subjectApplicationTo.FindStringSubmatch(subject); m != nil {
+		company = m[1]
+	} else if m := subjectCompanyPrefix.FindStringSubmatch(subject); m != nil {
+		company = m[1]
+	}
+	if title == "" {
+		if m := subjectApplicationFor.FindStringSubmatch(subject); m != nil && company == "" {
+			title = m[1]
+		} else if m := subjectTitleSuffix.FindStringSubmatch(subject); m != nil {
+			title = m[1]
+		}
+	}
+
+	if m := bodyTitleAtCompany.FindStringSubmatch(email.Text); m != nil {
+		if title == "" {
+			title = m[1]
+		}
+		if company == "" {
+			company = m[2]
+		}
+	}
+	if company == "" {
+		if m := bodyInterestCompany.FindStringSubmatch(email.Text); m != nil {
+			company = m[1]
+		}
+	}
+	if company == "" {
+		company = companyFromSender(email)
+	}
+
+	return cleanExtractedField(company), cleanExtractedField(title)
+}
+
+// companyFromSender uses a display name like "Stripe Recruiting" or, for mail
+// sent from a company's own domain, the domain itself.
+func companyFromSender(email *ParsedEmail) string {
+	if email.FromName != "" {
+		cleaned := strings.TrimSpace(senderNoise.ReplaceAllString(email.FromName, ""))
+		if cleaned != "" && (cleaned != strings.TrimSpace(email.FromName) || isATSDomain(email.FromDomain())) {
+			return cleaned
+		}
+	}
+	if domain := email.FromDomain(); domain != "" && !isATSDomain(domain) {
+		if label := registrableLabel(domain); label != "" {
+			return strings.ToUpper(label[:1]) + label[1:]
+		}
+	}
+	return ""
+}
+
+func cleanExtractedField(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), `"'“”‘’()[]-–:,.!`)
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > 80 {
+		return ""
+	}
+	return s
+}
@@ -7,11 +7,7 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"time"
-
-	openai "github.com/sashabaranov/go-openai"
-	"rsc.io/pdf"
 )
 
 // NLPRequest represents a file + job description to be processed.
@@ -36,21 +32,23 @@ func NewNLPService(maxConcurrent int) *NLPService {
 }
 
 // Analyze sends the file and job description to Flask (with concurrency limit).
-func (s *NLPService) Analyze(file multipart.File, header *multipart.FileHeader, jobDesc string) (string, error) {
+// The caller owns and closes file. Cancelling ctx abandons the wait for a slot
+// and the request to Flask.
+func (s *NLPService) Analyze(ctx context.Context, file io.Reader, filename, jobDesc string) (string, error) {
 	select {
 	case s.semaphore <- struct{}{}:
 		defer func() { <-s.semaphore }()
 	case <-time.After(2 * time.Second):
 		return "", fmt.Errorf("Too many concurrent resume requests. Please try again shortly.")
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
 
-	defer file.Close()
-
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
 	// Attach file
-	formFile, err := writer.CreateFormFile("file", header.Filename)
+	formFile, err := writer.CreateFormFile("file", filename)
 	if err != nil {
 		return "", err
 	}
@@ -68,20 +66,15 @@ func (s *NLPService) Analyze(file multipart.File, header *multipart.FileHeader,
 		return "", err
 	}
 
-	// cleaned_resume, err := ExtractRelevantSectionsWithGPT(file);
-	// fmt.Println(cleaned_resume)
-	
-	// if err != nil {
-	// 	return "", nil
-	// }
-
-	// if err := writer.WriteField("cleaned_resume", cleaned_resume); err != nil {
-	// 	return "", err
-	// }
 	writer.Close()
 
 	// Send POST request to Flask
-	resp, err := http.Post("http://localhost:5000/rank-resumes", writer.FormDataContentType(), &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost:5000/rank-resumes", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -98,62 +91,3 @@ func (s *NLPService) Analyze(file multipart.File, header *multipart.FileHeader,
 
 	return string(respData), nil
 }
-
-func ExtractRelevantSectionsWithGPT(file io.Reader) (string, error) {
-	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, file); err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
-	}
-
-	r, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
-	if err != nil {
-		return "", fmt.Errorf("failed to open PDF: %w", err)
-	}
-
-	var out string
-	for i := 1; i <= r.NumPage(); i++ {
-		page := r.Page(i)
-		if page.V.IsNull() {
-			continue
-		}
-		content := page.Content()
-		for _, txt := range content.Text {
-			out += txt.S + " "
-		}
-	}
-
-	fmt.Println("H")
-	fmt.Println(out)
-
-	// --- Step 2: Ask GPT to isolate sections ---
-	client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-
-	systemPrompt := `You are an assistant that extracts only specific sections from resumes.
-Keep only the text from the following sections if present:
-- Summary / Profile
-- Skills / Technical Skills
-- Projects
-- Experience / Work Experience
-Remove education, awards, extracurriculars, and other irrelevant text.
-Return the result as plain text.`
-
-	userPrompt := fmt.Sprintf("Resume text:\n\n%s", out)
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: "gpt-5-nano", 
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-			},
-			Temperature: 0.0,
-		},
-	)
-	if err != nil {
-		return "", fmt.Errorf("OpenAI call failed: %w", err)
-	}
-
-	return resp.Choices[0].Message.Content, nil
-}
-
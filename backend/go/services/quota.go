@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"trackify-jobs/models"
+)
+
+// ErrFileTooLarge is returned for an upload over the plan's per-file limit.
+var ErrFileTooLarge = errors.New("file is too large")
+
+// defaultPlan applies to users without a billing record and to unknown plans.
+const defaultPlan = "Free"
+
+// planLimits are keyed by the plan name stored in user_stripe.
+var planLimits = map[string]models.PlanLimits{
+	"Free": {
+		Resumes:      10,
+		StorageBytes: 25 << 20,
+		CoverLetters: 20,
+		MaxFileBytes: 5 << 20,
+	},
+	"Pro": {
+		Resumes:      200,
+		StorageBytes: 1 << 30,
+		CoverLetters: 500,
+		MaxFileBytes: 10 << 20,
+	},
+}
+
+// MaxUploadBytes is the largest file any plan accepts, for bounding request
+// bodies before the user's plan is known.
+func MaxUploadBytes() int64 {
+	var n int64
+	for _, l := range planLimits {
+		n = max(n, l.MaxFileBytes)
+	}
+	return n
+}
+
+// QuotaError reports that storing one more item would exceed a plan limit.
+type QuotaError struct {
+	Plan     string
+	Resource string // "resumes", "storage" or "cover letters"
+	Limit    int64
+	Used     int64
+}
+
+func (e *QuotaError) Error() string {
+	if e.Resource == "storage" {
+		return fmt.Sprintf("%s plan storage limit reached: %s of %s used", e.Plan, formatBytes(int(e.Used)), formatBytes(int(e.Limit)))
+	}
+	return fmt.Sprintf("%s plan limit reached: %d of %d %s", e.Plan, e.Used, e.Limit, e.Resource)
+}
+
+// Usage reports what the user has stored and the limits of their plan.
+func (s *DocumentService) Usage(userID string) (*models.Usage, error) {
+	plan, err := s.DB.GetUserPlan(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	limits, ok := planLimits[plan]
+	if !ok {
+		plan, limits = defaultPlan, planLimits[defaultPlan]
+	}
+
+	usage, err := s.DB.GetStorageUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Plan = plan
+	usage.Limits = limits
+	return usage, nil
+}
+
+func checkFileSize(u *models.Usage, size int64) error {
+	if size > u.Limits.MaxFileBytes {
+		return fmt.Errorf("%w: %s plan accepts files up to %s", ErrFileTooLarge, u.Plan, formatBytes(int(u.Limits.MaxFileBytes)))
+	}
+	return nil
+}
+
+// checkResumeQuota returns an error if one more version, adding newBytes of
+// storage, would not fit. A version that shares an already stored file adds
+// no bytes.
+func checkResumeQuota(u *models.Usage, newBytes int64) error {
+	if u.Resumes >= u.Limits.Resumes {
+		return &QuotaError{Plan: u.Plan, Resource: "resumes", Limit: int64(u.Limits.Resumes), Used: int64(u.Resumes)}
+	}
+	if u.StorageBytes+newBytes > u.Limits.StorageBytes {
+		return &QuotaError{Plan: u.Plan, Resource: "storage", Limit: u.Limits.StorageBytes, Used: u.StorageBytes}
+	}
+	return nil
+}
+
+func checkCoverLetterQuota(u *models.Usage) error {
+	if u.CoverLetters >= u.Limits.CoverLetters {
+		return &QuotaError{Plan: u.Plan, Resource: "cover letters", Limit: int64(u.Limits.CoverLetters), Used: int64(u.CoverLetters)}
+	}
+	return nil
+}
@@ -55,8 +55,8 @@ func (s *ReminderService) ProcessImmediateReminders() error {
 	// Prepare the list of emails to be sent
 	var emailBatch []*resend.SendEmailRequest
 	for _, reminder := range reminders {
-		// Create email data for each reminder
-		emailData := s.EmailService.CreateEmail(reminder.Email, reminder.Content, reminder.Content)
+		// Create email data for each reminder; suppressed or opted-out recipients are dropped at send time
+		emailData := s.EmailService.CreateCategorizedEmail(reminder.UserID, models.EmailCategoryReminders, reminder.Email, reminder.Content, reminder.Content)
 		emailBatch = append(emailBatch, emailData)
 	}
 
@@ -87,10 +87,10 @@ func (s *ReminderService) ProcessImmediateReminders() error {
 }
 
 // GetRemindersByUserID retrieves all reminders for a specific user by their user ID.
-func (s *ReminderService) GetRemindersByUserID(userID int) ([]models.Reminder, error) {
+func (s *ReminderService) GetRemindersByUserID(userID string) ([]models.Reminder, error) {
 	reminders, err := s.DB.GetRemindersByUserID(userID)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching reminders for user %d: %w", userID, err)
+		return nil, fmt.Errorf("error fetching reminders for user %s: %w", userID, err)
 	}
 	return reminders, nil
 }
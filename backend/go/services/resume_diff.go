@@ -0,0 +1,121 @@
+package services
+
+import (
+	"strconv"
+	"trackify-jobs/models"
+)
+
+// maxDiffCells caps the LCS table for one section; larger sections are shown
+// as a full replacement rather than spending quadratic time on them.
+const maxDiffCells = 4_000_000
+
+// DiffResumeText compares two extracted resumes section by section. Sections
+// are paired by canonical key in the order they appear in the newer text,
+// followed by sections only the older text has.
+func DiffResumeText(oldText, newText string) []models.ResumeSectionDiff {
+	oldSections := keyedSections(SplitResumeSections(oldText))
+	newSections := keyedSections(SplitResumeSections(newText))
+
+	oldByKey := make(map[string]ResumeSection, len(oldSections))
+	for _, sec := range oldSections {
+		oldByKey[sec.Key] = sec
+	}
+
+	var diffs []models.ResumeSectionDiff
+	seen := make(map[string]bool, len(newSections))
+	for _, sec := range newSections {
+		seen[sec.Key] = true
+		old, ok := oldByKey[sec.Key]
+		if !ok {
+			diffs = append(diffs, models.ResumeSectionDiff{
+				Section: sec.Key,
+				Status:  "added",
+				Lines:   diffOps("+", sec.Lines),
+			})
+			continue
+		}
+		lines := diffLines(old.Lines, sec.Lines)
+		status := "unchanged"
+		for _, l := range lines {
+			if l.Op != "=" {
+				status = "changed"
+				break
+			}
+		}
+		diffs = append(diffs, models.ResumeSectionDiff{Section: sec.Key, Status: status, Lines: lines})
+	}
+	for _, sec := range oldSections {
+		if !seen[sec.Key] {
+			diffs = append(diffs, models.ResumeSectionDiff{
+				Section: sec.Key,
+				Status:  "removed",
+				Lines:   diffOps("-", sec.Lines),
+			})
+		}
+	}
+	return diffs
+}
+
+// keyedSections makes repeated keys unique ("projects", "projects_2") so each
+// occurrence is compared with the same occurrence on the other side.
+func keyedSections(sections []ResumeSection) []ResumeSection {
+	counts := make(map[string]int)
+	for i := range sections {
+		counts[sections[i].Key]++
+		if n := counts[sections[i].Key]; n > 1 {
+			sections[i].Key += "_" + strconv.Itoa(n)
+		}
+	}
+	return sections
+}
+
+// diffLines is a longest-common-subsequence line diff.
+func diffLines(a, b []string) []models.DiffLine {
+	if len(a)*len(b) > maxDiffCells {
+		return append(diffOps("-", a), diffOps("+", b)...)
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []models.DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, models.DiffLine{Op: "=", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, models.DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			out = append(out, models.DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	out = append(out, diffOps("-", a[i:])...)
+	return append(out, diffOps("+", b[j:])...)
+}
+
+func diffOps(op string, lines []string) []models.DiffLine {
+	out := make([]models.DiffLine, len(lines))
+	for i, l := range lines {
+		out[i] = models.DiffLine{Op: op, Text: l}
+	}
+	return out
+}
@@ -0,0 +1,735 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"trackify-jobs/models"
+	"unicode"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// File size and length limits most applicant tracking systems work within.
+const (
+	lintSizeWarnBytes  = 2 << 20
+	lintSizeErrorBytes = 5 << 20
+	lintMaxPages       = 2
+)
+
+// safeFonts are families every ATS and every reviewer's machine renders the
+// same. Names are matched as lowercase prefixes with spaces and hyphens removed.
+var safeFonts = []string{
+	"arial", "helvetica", "times", "calibri", "cambria", "garamond", "georgia",
+	"verdana", "tahoma", "trebuchet", "courier", "palatino", "bookantiqua",
+	"bookman", "centurygothic", "segoeui", "gillsans", "lato", "roboto",
+	"opensans", "sourcesans", "notosans", "notoserif", "dejavu", "liberation",
+	"carlito", "caladea", "inter", "lmroman", "cmr", "cmbx", "cmti", "cmsy",
+	"cmmi", "sfrm", "aptos", "symbolmt",
+}
+
+// iconFonts map characters to pictures, so their glyphs extract as nonsense.
+var iconFonts = []string{
+	"fontawesome", "icomoon", "materialicons", "materialsymbols", "wingdings",
+	"webdings", "zapfdingbats", "dingbats", "glyphicons", "ionicons", "academicons",
+}
+
+var (
+	monthNameDateRe = regexp.MustCompile(`(?i)^(` + monthPattern + `)\s*'?(\d{2,4})$`)
+	numericDateRe   = regexp.MustCompile(`^\d{1,2}/\d{2,4}$`)
+	yearDateRe      = regexp.MustCompile(`^(?:19|20)\d{2}$`)
+	dateishRe       = regexp.MustCompile(`(?i)\b(?:` + monthPattern + `|(?:19|20)\d{2}|present|current)\b`)
+)
+
+// resumeLinter collects findings for one file.
+type resumeLinter struct {
+	findings []models.LintFinding
+}
+
+func (l *resumeLinter) add(check, severity, location, message, suggestion string) {
+	l.findings = append(l.findings, models.LintFinding{
+		Check:      check,
+		Severity:   severity,
+		Location:   location,
+		Message:    message,
+		Suggestion: suggestion,
+	})
+}
+
+// LintResume runs ATS compatibility checks on an uploaded file and the text
+// extracted from it. The checks are deterministic: the same file always gets
+// the same report.
+func LintResume(data []byte, contentType, text string) *models.LintReport {
+	l := &resumeLinter{}
+
+	switch {
+	case len(data) > lintSizeErrorBytes:
+		l.add("file_size", models.LintError, "file",
+			fmt.Sprintf("The file is %s; many applicant tracking systems reject uploads over 5 MB.", formatBytes(len(data))),
+			"Export again without embedded images, or compress the images before exporting.")
+	case len(data) > lintSizeWarnBytes:
+		l.add("file_size", models.LintWarning, "file",
+			fmt.Sprintf("The file is %s; some applicant tracking systems cap uploads at 2 MB.", formatBytes(len(data))),
+			"Remove photos and decorative graphics, or export with image compression.")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(data, []byte("%PDF-")):
+		l.lintPDF(data, text)
+	case mediaType == docxMediaType || bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		l.lintDOCX(data, text)
+	}
+	l.lintText(text)
+
+	return newLintReport(l.findings)
+}
+
+func (l *resumeLinter) has(check, severity string) bool {
+	for _, f := range l.findings {
+		if f.Check == check && f.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func newLintReport(findings []models.LintFinding) *models.LintReport {
+	rank := map[string]int{models.LintError: 0, models.LintWarning: 1, models.LintInfo: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return rank[findings[i].Severity] < rank[findings[j].Severity]
+	})
+
+	report := &models.LintReport{Score: 100, Findings: findings, CheckedAt: time.Now()}
+	if report.Findings == nil {
+		report.Findings = []models.LintFinding{}
+	}
+	for _, f := range findings {
+		switch f.Severity {
+		case models.LintError:
+			report.Errors++
+			report.Score -= 15
+		case models.LintWarning:
+			report.Warnings++
+			report.Score -= 5
+		default:
+			report.Score--
+		}
+	}
+	report.Score = max(report.Score, 0)
+	return report
+}
+
+func formatBytes(n int) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+//
+// PDF
+//
+
+// lintPDF checks layout, images and fonts page by page. A file that cannot
+// be read becomes a finding rather than a failed request.
+func (l *resumeLinter) lintPDF(data []byte, text string) {
+	reader, pages, err := openPDF(data)
+	if err != nil {
+		l.add("file", models.LintError, "file",
+			"The PDF could not be opened.",
+			"Export the PDF again; if it is password protected, remove the protection.")
+		return
+	}
+	l.pageCount(pages)
+
+	fontPages := map[string][]int{}
+	var fontOrder []string
+	for i := 1; i <= pages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			l.add("file", models.LintError, "file",
+				"The PDF could not be read reliably; an ATS may fail on it too.",
+				"Export the PDF again from your editor using \"Save as PDF\" rather than printing to PDF.")
+			return
+		}
+		location := fmt.Sprintf("page %d", i)
+
+		// The layout checks need the page's text; without it they are skipped
+		// and the whole-file text check below still runs.
+		glyphs, err := pdfPageGlyphs(page)
+		if err != nil {
+			log.Printf("Lint: no text for PDF page %d: %v", i, err)
+		} else if _, ok := findColumnGutter(pdfRows(glyphs)); ok {
+			l.add("columns", models.LintWarning, location,
+				"Text is laid out in side-by-side columns; many ATS read straight across and interleave them.",
+				"Use a single-column layout, or keep the sidebar to short items like skills that read fine out of order.")
+		}
+
+		images := pdfPageImages(page)
+		switch {
+		case images > 0 && err == nil && countLetters(glyphs) < 50:
+			l.add("images", models.LintError, location,
+				"The page is an image with little or no selectable text; an ATS will see it as blank.",
+				"Export from the original document instead of scanning, or run OCR and check the text is selectable.")
+		case images > 0:
+			l.add("images", models.LintInfo, location,
+				fmt.Sprintf("The page has %d image(s); any text inside them, such as a logo or skill chart, is invisible to an ATS.", images),
+				"Make sure nothing important appears only in an image.")
+		}
+
+		for _, base := range pdfPageFonts(page) {
+			if _, seen := fontPages[base]; !seen {
+				fontOrder = append(fontOrder, base)
+			}
+			if p := fontPages[base]; len(p) == 0 || p[len(p)-1] != i {
+				fontPages[base] = append(p, i)
+			}
+		}
+	}
+	for _, font := range fontOrder {
+		l.font(font, pagesLocation(fontPages[font]))
+	}
+
+	if pages > 0 && countLettersString(text) == 0 && !l.has("images", models.LintError) {
+		l.add("images", models.LintError, "file",
+			"No text could be extracted from the PDF.",
+			"Export the resume as a text PDF from your editor; scanned or flattened resumes cannot be read by an ATS.")
+	}
+}
+
+// pdfPageImages counts the image XObjects a page references directly.
+func pdfPageImages(page *model.PdfPage) int {
+	if page.Resources == nil {
+		return 0
+	}
+	xobjects, ok := core.GetDict(page.Resources.XObject)
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, name := range xobjects.Keys() {
+		if _, kind := page.Resources.GetXObjectByName(name); kind == model.XObjectTypeImage {
+			n++
+		}
+	}
+	return n
+}
+
+// pdfPageFonts returns the base names of the fonts a page uses, without
+// subset prefixes. Type 3 fonts are prefixed "Type3:".
+func pdfPageFonts(page *model.PdfPage) []string {
+	if page.Resources == nil {
+		return nil
+	}
+	fonts, ok := core.GetDict(page.Resources.Font)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, key := range fonts.Keys() {
+		f, err := model.NewPdfFontFromPdfObject(fonts.Get(key))
+		if err != nil {
+			continue
+		}
+		base := strings.TrimSpace(f.BaseFont())
+		if i := strings.IndexByte(base, '+'); i == 6 {
+			base = base[7:] // subset prefix like ABCDEF+
+		}
+		if f.Subtype() == "Type3" {
+			base = "Type3:" + base
+		}
+		if base != "" {
+			names = append(names, base)
+		}
+	}
+	return names
+}
+
+func countLetters(glyphs []pdfGlyph) int {
+	n := 0
+	for _, g := range glyphs {
+		n += countLettersString(g.S)
+	}
+	return n
+}
+
+func countLettersString(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
+
+func pagesLocation(pages []int) string {
+	if len(pages) == 1 {
+		return fmt.Sprintf("page %d", pages[0])
+	}
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = strconv.Itoa(p)
+	}
+	return "pages " + strings.Join(parts, ", ")
+}
+
+func (l *resumeLinter) pageCount(pages int) {
+	if pages > lintMaxPages {
+		l.add("page_count", models.LintWarning, "file",
+			fmt.Sprintf("The resume is %d pages long; recruiters expect one page, or two with a long career.", pages),
+			"Trim older roles to a line each and cut bullets that do not show impact.")
+	}
+}
+
+// font classifies one font name, as written in the PDF or Word file.
+func (l *resumeLinter) font(name, location string) {
+	if strings.HasPrefix(name, "Type3:") {
+		l.add("fonts", models.LintWarning, location,
+			fmt.Sprintf("%q is a Type 3 font drawn from shapes; its text often extracts as gibberish.", strings.TrimPrefix(name, "Type3:")),
+			"Export with standard TrueType or OpenType fonts embedded.")
+		return
+	}
+	key := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+	for _, icon := range iconFonts {
+		if strings.HasPrefix(key, icon) {
+			l.add("fonts", models.LintWarning, location,
+				fmt.Sprintf("%q is an icon font; its symbols extract as stray letters or boxes.", name),
+				"Replace icons next to your contact details with plain labels such as \"Email:\" and \"Phone:\".")
+			return
+		}
+	}
+	for _, safe := range safeFonts {
+		if strings.HasPrefix(key, safe) {
+			return
+		}
+	}
+	l.add("fonts", models.LintInfo, location,
+		fmt.Sprintf("%q is an uncommon font; unusual fonts can extract with wrong or missing characters.", name),
+		"Use a standard font such as Arial, Calibri, Garamond, Helvetica or Times New Roman.")
+}
+
+//
+// DOCX
+//
+
+// lintDOCX walks the Word XML for structures ATS parsers handle badly:
+// tables, text boxes, drawings, multi-column sections and fonts, plus contact
+// details placed only in the page header or footer.
+func (l *resumeLinter) lintDOCX(data []byte, text string) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		l.add("file", models.LintError, "file",
+			"The Word document could not be opened.",
+			"Save the document again as .docx from Word or Google Docs.")
+		return
+	}
+
+	var doc *zip.File
+	parts := map[string]*zip.File{}
+	for _, f := range zr.File {
+		parts[f.Name] = f
+		if f.Name == "word/document.xml" {
+			doc = f
+		}
+	}
+	if doc == nil {
+		return
+	}
+
+	fonts := map[string]bool{}
+	var fontOrder []string
+	addFont := func(name string) {
+		if name != "" && !fonts[name] {
+			fonts[name] = true
+			fontOrder = append(fontOrder, name)
+		}
+	}
+
+	tables, images, textBoxes, columns := 0, 0, 0, 0
+	tableDepth := 0
+	err = walkDOCXPart(doc, func(t xml.StartElement) {
+		switch t.Name.Local {
+		case "tbl":
+			if tableDepth == 0 {
+				tables++
+			}
+			tableDepth++
+		case "drawing", "pict":
+			images++
+		case "txbxContent":
+			textBoxes++
+		case "cols":
+			if n, _ := strconv.Atoi(docxAttr(t, "num")); n > columns {
+				columns = n
+			}
+		case "rFonts":
+			addFont(docxAttr(t, "ascii"))
+			addFont(docxAttr(t, "hAnsi"))
+		}
+	}, func(t xml.EndElement) {
+		if t.Name.Local == "tbl" {
+			tableDepth--
+		}
+	})
+	if err != nil {
+		l.add("file", models.LintError, "file",
+			"The Word document's content could not be parsed.",
+			"Save the document again as .docx from Word or Google Docs.")
+		return
+	}
+	if styles := parts["word/styles.xml"]; styles != nil {
+		walkDOCXPart(styles, func(t xml.StartElement) {
+			if t.Name.Local == "rFonts" {
+				addFont(docxAttr(t, "ascii"))
+				addFont(docxAttr(t, "hAnsi"))
+			}
+		}, nil)
+	}
+
+	if tables > 0 {
+		l.add("tables", models.LintWarning, "document",
+			fmt.Sprintf("The document uses %d table(s); many ATS read table cells out of order or drop them.", tables),
+			"Replace tables with plain paragraphs, using a right-aligned tab stop for dates.")
+	}
+	if textBoxes > 0 {
+		l.add("text_boxes", models.LintError, "document",
+			fmt.Sprintf("The document has %d text box(es); most ATS skip text inside text boxes entirely.", textBoxes),
+			"Move text out of text boxes into the body of the document.")
+	}
+	if images > textBoxes {
+		l.add("images", models.LintInfo, "document",
+			fmt.Sprintf("The document has %d image or drawing(s); any text inside them is invisible to an ATS.", images-textBoxes),
+			"Make sure nothing important appears only in an image, shape or chart.")
+	}
+	if columns > 1 {
+		l.add("columns", models.LintWarning, "document",
+			fmt.Sprintf("A section is set in %d columns; many ATS read straight across and interleave them.", columns),
+			"Use a single column (Layout > Columns > One).")
+	}
+	for _, font := range fontOrder {
+		l.font(font, "document")
+	}
+
+	var headerText strings.Builder
+	for name, f := range parts {
+		if strings.HasPrefix(name, "word/header") || strings.HasPrefix(name, "word/footer") {
+			walkDOCXText(f, &headerText)
+		}
+	}
+	if hdr := headerText.String(); (emailRe.MatchString(hdr) && !emailRe.MatchString(text)) ||
+		(phoneRe.MatchString(hdr) && !phoneRe.MatchString(text)) {
+		l.add("header_footer", models.LintWarning, "page header/footer",
+			"Contact details appear only in the page header or footer, which many ATS ignore.",
+			"Move your name, email and phone number into the body of the document.")
+	}
+
+	pages := 0
+	if app := parts["docProps/app.xml"]; app != nil {
+		pages = docxPageCount(app)
+	}
+	if pages == 0 {
+		// Roughly 500 words fill a page in a typical resume layout.
+		pages = (len(strings.Fields(text)) + 499) / 500
+	}
+	l.pageCount(pages)
+}
+
+// walkDOCXPart streams the XML of one zip entry to the callbacks.
+func walkDOCXPart(f *zip.File, start func(xml.StartElement), end func(xml.EndElement)) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxResumeBytes))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if start != nil {
+				start(t)
+			}
+		case xml.EndElement:
+			if end != nil {
+				end(t)
+			}
+		}
+	}
+}
+
+// walkDOCXText appends the text runs of one zip entry to b, one paragraph per line.
+func walkDOCXText(f *zip.File, b *strings.Builder) {
+	rc, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxResumeBytes))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inText = t.Name.Local == "t"
+		case xml.EndElement:
+			inText = false
+			if t.Name.Local == "p" {
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+// docxPageCount reads the page count Word saves in docProps/app.xml. It is
+// only as fresh as the last save, and zero when the writer left it out.
+func docxPageCount(f *zip.File) int {
+	rc, err := f.Open()
+	if err != nil {
+		return 0
+	}
+	defer rc.Close()
+
+	var props struct {
+		Pages int `xml:"Pages"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(rc, 1<<20)).Decode(&props); err != nil {
+		return 0
+	}
+	return props.Pages
+}
+
+func docxAttr(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+//
+// Text
+//
+
+// lintText checks what an ATS will actually see: contact details, section
+// headings and dates.
+func (l *resumeLinter) lintText(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	if ratio := garbledRatio(text); ratio > 0.02 {
+		l.add("garbled_text", models.LintError, "file",
+			fmt.Sprintf("About %.0f%% of the extracted characters are unreadable symbols; the fonts lack a Unicode mapping.", ratio*100),
+			"Export again with standard fonts, or use \"Save as PDF\" instead of printing to PDF.")
+	}
+
+	sections := SplitResumeSections(text)
+	l.lintContact(sections, text)
+	l.lintHeadings(sections)
+	l.lintDates(sections)
+}
+
+// garbledRatio is the share of non-space characters that are private-use
+// glyphs, replacement characters or control codes.
+func garbledRatio(text string) float64 {
+	total, bad := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) || unicode.IsControl(r) {
+			bad++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(bad) / float64(total)
+}
+
+func (l *resumeLinter) lintContact(sections []ResumeSection, text string) {
+	var header []string
+	if len(sections) > 0 && sections[0].Key == "header" {
+		header = sections[0].Lines
+	}
+
+	name := false
+	for i, line := range header {
+		if i >= 3 {
+			break
+		}
+		if looksLikeName(line) {
+			name = true
+			break
+		}
+	}
+	if !name {
+		l.add("contact", models.LintWarning, "header",
+			"Your name was not found at the top of the resume.",
+			"Put your full name on its own line as the first line of the resume, not in the page header.")
+	}
+	if !emailRe.MatchString(text) {
+		l.add("contact", models.LintError, "header",
+			"No email address was found.",
+			"Add your email address as plain text near your name, without an icon in place of a label.")
+	}
+	if !phoneRe.MatchString(text) {
+		l.add("contact", models.LintWarning, "header",
+			"No phone number was found.",
+			"Add a phone number in a common format such as (555) 123-4567 or +1 555 123 4567.")
+	}
+	if !linkedInRe.MatchString(text) {
+		l.add("contact", models.LintInfo, "header",
+			"No LinkedIn profile URL was found.",
+			"Add your LinkedIn URL written out in full, e.g. linkedin.com/in/your-name.")
+	}
+}
+
+func (l *resumeLinter) lintHeadings(sections []ResumeSection) {
+	standard := map[string]bool{}
+	for _, key := range sectionHeadings {
+		standard[key] = true
+	}
+
+	seen := map[string]bool{}
+	for _, sec := range sections {
+		seen[sec.Key] = true
+		if sec.Key != "header" && !standard[sec.Key] {
+			l.add("headings", models.LintInfo, "section: "+sec.Heading,
+				fmt.Sprintf("%q is not a standard section heading; an ATS may not know what the section holds.", sec.Heading),
+				"Use a conventional heading such as Experience, Education, Skills, Projects or Certifications.")
+		}
+	}
+	if !seen["experience"] {
+		l.add("headings", models.LintWarning, "document",
+			"No work experience section was recognized.",
+			"Head your work history \"Experience\" or \"Work Experience\" so an ATS can find it.")
+	}
+	if !seen["education"] {
+		l.add("headings", models.LintInfo, "document",
+			"No education section was recognized.",
+			"If you list education, head it \"Education\".")
+	}
+}
+
+// lintDates looks for entries without a readable date and for a mix of date
+// styles across the dated sections.
+func (l *resumeLinter) lintDates(sections []ResumeSection) {
+	styles := map[string][]string{} // style -> examples
+	var styleOrder []string
+	note := func(date string) {
+		for _, point := range strings.Split(normalizeDash(date), "-") {
+			point = strings.TrimSpace(point)
+			style := dateStyle(point)
+			if style == "" {
+				continue
+			}
+			if _, ok := styles[style]; !ok {
+				styleOrder = append(styleOrder, style)
+			}
+			styles[style] = append(styles[style], point)
+		}
+	}
+
+	for _, sec := range sections {
+		if sec.Key != "experience" && sec.Key != "education" && sec.Key != "projects" {
+			continue
+		}
+		undated, unparsed := 0, 0
+		var example string
+		for _, e := range parseEntries(sec.Lines) {
+			if e.date != "" {
+				// A bare graduation year is normal next to month dates elsewhere.
+				if sec.Key != "education" {
+					note(e.date)
+				}
+				continue
+			}
+			if sec.Key == "projects" || len(e.parts) == 0 {
+				continue
+			}
+			heading := strings.Join(e.parts, " - ")
+			if dateishRe.MatchString(heading) {
+				unparsed++
+			} else {
+				undated++
+			}
+			if example == "" {
+				example = heading
+			}
+		}
+		if n := undated + unparsed; n > 0 {
+			msg := fmt.Sprintf("%d entr%s no date, e.g. %q.", n, plural(n, "y has", "ies have"), example)
+			if unparsed > 0 {
+				msg = fmt.Sprintf("%d entr%s a date an ATS may not be able to parse, e.g. %q.", n, plural(n, "y has", "ies have"), example)
+			}
+			l.add("dates", models.LintWarning, "section: "+sec.Heading, msg,
+				"Write dates as \"Jan 2021 - Mar 2023\" (or \"2021 - Present\") on the same line as the title.")
+		}
+	}
+
+	if len(styleOrder) > 1 {
+		examples := make([]string, len(styleOrder))
+		for i, s := range styleOrder {
+			examples[i] = fmt.Sprintf("%q", styles[s][0])
+		}
+		l.add("date_format", models.LintInfo, "document",
+			fmt.Sprintf("Dates are written in %d different styles (%s).", len(styleOrder), strings.Join(examples, ", ")),
+			"Pick one style, such as \"Jan 2021\", and use it for every date.")
+	}
+}
+
+// dateStyle names the format of one date point, or "" for words like "Present".
+func dateStyle(point string) string {
+	switch {
+	case numericDateRe.MatchString(point):
+		return "numeric"
+	case yearDateRe.MatchString(point):
+		return "year"
+	}
+	m := monthNameDateRe.FindStringSubmatch(point)
+	if m == nil {
+		return ""
+	}
+	style := "month"
+	if len(strings.TrimSuffix(m[1], ".")) == 3 {
+		style = "abbreviated month"
+	}
+	if len(m[2]) == 2 {
+		style += ", 2-digit year"
+	}
+	return style
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
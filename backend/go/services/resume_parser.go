@@ -0,0 +1,493 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"trackify-jobs/models"
+	"unicode"
+)
+
+const (
+	monthPattern = `(?:jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+	datePoint    = `(?:` + monthPattern + `\s*'?\d{2,4}|\d{1,2}/\d{2,4}|(?:19|20)\d{2})`
+)
+
+var (
+	dateRangeRe   = regexp.MustCompile(`(?i)` + datePoint + `\s*(?:-|–|—|to)\s*(?:` + datePoint + `|present|current|now|ongoing)`)
+	singleDateRe  = regexp.MustCompile(`(?i)(?:expected\s+)?` + datePoint + `\s*$`)
+	emailRe       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phoneRe       = regexp.MustCompile(`(?:\+?\d{1,3}[\s.\-]?)?\(?\d{3}\)?[\s.\-]?\d{3}[\s.\-]?\d{4}`)
+	linkedInRe    = regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z]{2,3}\.)?linkedin\.com/in/[A-Za-z0-9_\-%]+/?`)
+	gitHubRe      = regexp.MustCompile(`(?i)(?:https?://)?(?:www\.)?github\.com/[A-Za-z0-9_\-]+/?`)
+	bulletRe      = regexp.MustCompile(`^(?:[•●▪◦‣∙·■□➢►✓*]\s*|[\-–—o]\s+|\d{1,2}[.)]\s+)`)
+	headerSplitRe = regexp.MustCompile(`\s+(?:\||—|–|-|@|at)\s+|\s*\|\s*`)
+	locationRe    = regexp.MustCompile(`^(?:remote|hybrid|[A-Z][A-Za-z .]+,\s*(?:[A-Z]{2}|[A-Z][a-z]+))$`)
+)
+
+var jobTitleWords = []string{
+	"engineer", "developer", "manager", "intern", "analyst", "designer", "scientist", "lead",
+	"director", "consultant", "specialist", "architect", "administrator", "coordinator",
+	"assistant", "associate", "officer", "researcher", "programmer", "technician", "founder",
+	"head of", "vp", "president", "teacher", "tutor", "representative", "contractor",
+}
+
+var degreeWords = []string{
+	"bachelor", "master", "b.s", "bs ", "b.a", "ba ", "m.s", "ms ", "m.a", "mba", "ph.d", "phd",
+	"associate", "diploma", "b.sc", "m.sc", "b.eng", "m.eng", "bsc", "msc", "certificate", "degree",
+}
+
+var schoolWords = []string{"university", "college", "institute", "school", "academy", "polytechnic"}
+
+// ParseResumeText turns extracted text into the structured schema using
+// section headings, date patterns and bullet markers. The returned map scores
+// each top-level field from 0 (not found) to 1.
+func ParseResumeText(text string) (*models.StructuredResume, map[string]float64) {
+	out := &models.StructuredResume{Skills: map[string][]string{}}
+	conf := map[string]float64{}
+
+	sections := SplitResumeSections(text)
+	var header []string
+	for _, sec := range sections {
+		switch sec.Key {
+		case "header":
+			header = sec.Lines
+		case "summary":
+			if out.Summary == "" {
+				out.Summary = strings.Join(sec.Lines, " ")
+				conf["summary"] = scoreIf(out.Summary != "", 0.9)
+			}
+		case "experience":
+			entries := parseEntries(sec.Lines)
+			for _, e := range entries {
+				out.Experience = append(out.Experience, experienceFromEntry(e))
+			}
+			conf["experience"] = entriesConfidence(entries, true)
+		case "projects":
+			entries := parseEntries(sec.Lines)
+			for _, e := range entries {
+				out.Projects = append(out.Projects, models.ProjectEntry{
+					Name:    strings.Join(e.parts, " - "),
+					Date:    e.date,
+					Bullets: e.bullets,
+				})
+			}
+			conf["projects"] = entriesConfidence(entries, false)
+		case "education":
+			out.Education = parseEducation(sec.Lines)
+			conf["education"] = educationConfidence(out.Education)
+		case "skills":
+			categorized := parseSkills(sec.Lines, out.Skills)
+			if len(out.Skills) > 0 {
+				conf["skills"] = 0.7
+				if categorized {
+					conf["skills"] = 0.9
+				}
+			}
+		}
+	}
+
+	parseContact(out, conf, header, text)
+
+	for _, field := range structuredFields {
+		conf[field] = math.Round(conf[field]*100) / 100
+	}
+	return out, conf
+}
+
+// OverallConfidence averages the scores of the fields a resume normally has.
+func OverallConfidence(conf map[string]float64) float64 {
+	core := []string{"name", "email", "experience", "education", "skills"}
+	total := 0.0
+	for _, f := range core {
+		total += conf[f]
+	}
+	return total / float64(len(core))
+}
+
+func scoreIf(ok bool, score float64) float64 {
+	if ok {
+		return score
+	}
+	return 0
+}
+
+//
+// Contact details
+//
+
+func parseContact(out *models.StructuredResume, conf map[string]float64, header []string, text string) {
+	// Prefer the header block, then fall back to the whole text.
+	search := strings.Join(header, "\n")
+	find := func(re *regexp.Regexp) (string, bool) {
+		if m := re.FindString(search); m != "" {
+			return m, true
+		}
+		return re.FindString(text), false
+	}
+
+	if m, inHeader := find(emailRe); m != "" {
+		out.Email = m
+		conf["email"] = 0.99
+		if !inHeader {
+			conf["email"] = 0.8
+		}
+	}
+	if m, inHeader := find(phoneRe); m != "" {
+		out.Phone = strings.TrimSpace(m)
+		conf["phone"] = 0.9
+		if !inHeader {
+			conf["phone"] = 0.6
+		}
+	}
+	if m, _ := find(linkedInRe); m != "" {
+		out.LinkedIn = m
+		conf["linkedin"] = 0.95
+	}
+	if m, _ := find(gitHubRe); m != "" {
+		out.GitHub = m
+		conf["github"] = 0.95
+	}
+
+	for i, line := range header {
+		if looksLikeName(line) {
+			out.Name = line
+			conf["name"] = 0.85
+			if i > 0 {
+				conf["name"] = 0.6
+			}
+			return
+		}
+	}
+	if len(header) > 0 {
+		// First line, minus any contact details sharing it.
+		name := strings.TrimSpace(headerSplitRe.Split(header[0], 2)[0])
+		if name != "" && !emailRe.MatchString(name) && !phoneRe.MatchString(name) {
+			out.Name = name
+			conf["name"] = 0.4
+		}
+	}
+}
+
+// looksLikeName accepts 2-4 capitalised words without digits or contact details.
+func looksLikeName(line string) bool {
+	words := strings.Fields(line)
+	if len(words) < 2 || len(words) > 4 || len(line) > 50 {
+		return false
+	}
+	for _, w := range words {
+		r := []rune(w)
+		if !unicode.IsUpper(r[0]) {
+			return false
+		}
+		for _, c := range r {
+			if !unicode.IsLetter(c) && c != '.' && c != '-' && c != '\'' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//
+// Dated entries (experience, projects)
+//
+
+// resumeEntry is one dated block: header parts before the bullets.
+type resumeEntry struct {
+	parts   []string
+	date    string
+	bullets []string
+}
+
+// parseEntries groups lines into entries. A non-bullet line after bullets, or
+// a line carrying a new date range, starts a new entry; unmarked lines that
+// continue a sentence are joined to the previous bullet.
+func parseEntries(lines []string) []resumeEntry {
+	var entries []resumeEntry
+	var cur *resumeEntry
+	for _, line := range lines {
+		if loc := bulletRe.FindStringIndex(line); loc != nil && cur != nil {
+			if b := strings.TrimSpace(line[loc[1]:]); b != "" {
+				cur.bullets = append(cur.bullets, b)
+			}
+			continue
+		}
+
+		date, rest := extractDate(line)
+		if cur != nil && len(cur.bullets) > 0 && date == "" && continuesSentence(line) {
+			cur.bullets[len(cur.bullets)-1] += " " + line
+			continue
+		}
+		if cur == nil || len(cur.bullets) > 0 || (date != "" && cur.date != "") || len(cur.parts) >= 3 {
+			entries = append(entries, resumeEntry{})
+			cur = &entries[len(entries)-1]
+		}
+		if date != "" {
+			cur.date = date
+		}
+		for _, part := range splitEntryHeader(rest) {
+			if !locationRe.MatchString(part) {
+				cur.parts = append(cur.parts, part)
+			}
+		}
+	}
+	return entries
+}
+
+func continuesSentence(line string) bool {
+	r := []rune(line)
+	return len(r) > 0 && (unicode.IsLower(r[0]) || unicode.IsDigit(r[0]))
+}
+
+// extractDate pulls a date range (or a trailing single date) out of line.
+func extractDate(line string) (date, rest string) {
+	if loc := dateRangeRe.FindStringIndex(line); loc != nil {
+		return normalizeDash(line[loc[0]:loc[1]]), line[:loc[0]] + " " + line[loc[1]:]
+	}
+	if loc := singleDateRe.FindStringIndex(line); loc != nil {
+		return strings.TrimSpace(line[loc[0]:loc[1]]), line[:loc[0]]
+	}
+	return "", line
+}
+
+func normalizeDash(s string) string {
+	s = strings.NewReplacer("–", "-", "—", "-").Replace(s)
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(parts[0]) + " - " + strings.TrimSpace(parts[1])
+}
+
+func splitEntryHeader(s string) []string {
+	s = strings.Trim(strings.TrimSpace(s), ",|-–—()")
+	if s == "" {
+		return nil
+	}
+	parts := headerSplitRe.Split(s, -1)
+	if len(parts) == 1 {
+		parts = strings.SplitN(s, ", ", 2)
+	}
+	var out []string
+	for _, p := range parts {
+		if p = strings.Trim(strings.TrimSpace(p), ",|()"); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// experienceFromEntry decides which header part is the title: the one that
+// reads like a job title, otherwise the first.
+func experienceFromEntry(e resumeEntry) models.ExperienceEntry {
+	exp := models.ExperienceEntry{Date: e.date, Bullets: e.bullets}
+	titleIdx := -1
+	for i, p := range e.parts {
+		if containsAny(strings.ToLower(p), jobTitleWords) {
+			titleIdx = i
+			break
+		}
+	}
+	if titleIdx < 0 && len(e.parts) > 0 {
+		titleIdx = 0
+	}
+	for i, p := range e.parts {
+		switch {
+		case i == titleIdx:
+			exp.Title = p
+		case exp.Company == "":
+			exp.Company = p
+		}
+	}
+	return exp
+}
+
+func entriesConfidence(entries []resumeEntry, wantCompany bool) float64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, e := range entries {
+		score := 0.0
+		if e.date != "" {
+			score += 0.4
+		}
+		if len(e.parts) > 0 {
+			score += 0.3
+		}
+		if !wantCompany || len(e.parts) > 1 {
+			score += 0.2
+		}
+		if len(e.bullets) > 0 {
+			score += 0.1
+		}
+		total += score
+	}
+	return total / float64(len(entries))
+}
+
+//
+// Education
+//
+
+func parseEducation(lines []string) []models.EducationEntry {
+	var entries []models.EducationEntry
+	var cur *models.EducationEntry
+	for _, line := range lines {
+		if loc := bulletRe.FindStringIndex(line); loc != nil {
+			continue // coursework and honours are not part of the schema
+		}
+		date, rest := extractDate(line)
+		lower := " " + strings.ToLower(rest) + " "
+		isSchool := containsAny(lower, schoolWords)
+		isDegree := containsAny(lower, degreeWords)
+
+		if cur == nil || (isSchool && cur.School != "") || (isDegree && !isSchool && cur.Degree != "") {
+			entries = append(entries, models.EducationEntry{})
+			cur = &entries[len(entries)-1]
+		}
+		if date != "" && cur.Date == "" {
+			cur.Date = date
+		}
+		for _, part := range splitEntryHeader(rest) {
+			lp := " " + strings.ToLower(part) + " "
+			switch {
+			case locationRe.MatchString(part):
+			case containsAny(lp, schoolWords) && cur.School == "":
+				cur.School = part
+			case containsAny(lp, degreeWords) && cur.Degree == "":
+				cur.Degree = part
+			case cur.School == "":
+				cur.School = part
+			case cur.Degree == "":
+				cur.Degree = part
+			}
+		}
+	}
+	return entries
+}
+
+func educationConfidence(entries []models.EducationEntry) float64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, e := range entries {
+		score := 0.0
+		if e.School != "" {
+			score += 0.4
+		}
+		if e.Degree != "" {
+			score += 0.3
+		}
+		if e.Date != "" {
+			score += 0.3
+		}
+		total += score
+	}
+	return total / float64(len(entries))
+}
+
+//
+// Skills
+//
+
+// parseSkills reads "Category: a, b, c" lines into skills; lines without a
+// category go under "Skills". It reports whether any categories were found.
+func parseSkills(lines []string, skills map[string][]string) bool {
+	categorized := false
+	for _, line := range lines {
+		if loc := bulletRe.FindStringIndex(line); loc != nil {
+			line = line[loc[1]:]
+		}
+		category := "Skills"
+		if name, list, ok := strings.Cut(line, ":"); ok && len(name) <= 40 {
+			category, line = strings.TrimSpace(name), list
+			categorized = true
+		}
+		for _, item := range strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == '|' || r == '•' || r == '·'
+		}) {
+			if item = strings.TrimSpace(item); item != "" {
+				skills[category] = append(skills[category], item)
+			}
+		}
+	}
+	return categorized
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatResumeText lays a structured resume out as plain text that
+// ParseResumeText reads back into the same fields. It is the stored text of
+// resumes that were created from structured data rather than a file.
+func FormatResumeText(r *models.StructuredResume) string {
+	var b strings.Builder
+	line := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			b.WriteString(s)
+			b.WriteByte('\n')
+		}
+	}
+	joinNonEmpty := func(sep string, parts ...string) string {
+		var kept []string
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p != "" {
+				kept = append(kept, p)
+			}
+		}
+		return strings.Join(kept, sep)
+	}
+
+	line(r.Name)
+	line(joinNonEmpty(" | ", r.Email, r.Phone, r.LinkedIn, r.GitHub))
+	if r.Summary != "" {
+		line("SUMMARY")
+		line(r.Summary)
+	}
+	if len(r.Experience) > 0 {
+		line("EXPERIENCE")
+		for _, e := range r.Experience {
+			line(joinNonEmpty(" | ", e.Title, e.Company, e.Date))
+			for _, bullet := range e.Bullets {
+				line("• " + bullet)
+			}
+		}
+	}
+	if len(r.Projects) > 0 {
+		line("PROJECTS")
+		for _, p := range r.Projects {
+			line(joinNonEmpty(" | ", p.Name, p.Date))
+			for _, bullet := range p.Bullets {
+				line("• " + bullet)
+			}
+		}
+	}
+	if len(r.Education) > 0 {
+		line("EDUCATION")
+		for _, e := range r.Education {
+			line(joinNonEmpty(" | ", e.School, e.Degree, e.Date))
+		}
+	}
+	if len(r.Skills) > 0 {
+		line("SKILLS")
+		categories := make([]string, 0, len(r.Skills))
+		for c := range r.Skills {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		for _, c := range categories {
+			line(c + ": " + strings.Join(r.Skills[c], ", "))
+		}
+	}
+	return b.String()
+}
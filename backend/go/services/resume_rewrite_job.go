@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"trackify-jobs/models"
+)
+
+// ResumeRewriteInput is everything a rewrite job needs, captured when it is
+// queued so that the job does not depend on the resume staying as it was.
+type ResumeRewriteInput struct {
+	ResumeID       string `json:"resume_id"`
+	Filename       string `json:"filename"`
+	File           []byte `json:"file"`
+	ResumeText     string `json:"resume_text"`
+	JobDescription string `json:"job_description"`
+}
+
+// ResumeRewriteResult is a finished rewrite job's result.
+type ResumeRewriteResult struct {
+	Rewrites   *RewrittenResume  `json:"rewrites"`
+	Generation models.Generation `json:"generation"`
+}
+
+// lowSimilarityThreshold is the analysis score below which a job skill counts
+// as missing from the resume and the rewrite is asked to work it in.
+const lowSimilarityThreshold = 75
+
+// The rewrite step's share of a rewrite job's progress, in percent.
+const (
+	rewriteStartPercent = 20
+	rewriteEndPercent   = 90
+)
+
+// rewriteProgress estimates how far the rewrite has got from how much of the
+// reply has streamed in, taking the reply to be about as long as the resume
+// text. The estimate is reported in steps of 5% at most, to keep database
+// writes (and the events they send) few, and never goes backwards: a reply
+// sent back for repair starts again from zero but the percentage holds.
+func rewriteProgress(resumeLen int, progress JobProgressFunc) *Stream {
+	expected := max(resumeLen, 1000)
+	received, reported := 0, rewriteStartPercent
+	return &Stream{
+		Delta: func(text string) error {
+			received += len(text)
+			span := rewriteEndPercent - rewriteStartPercent
+			percent := rewriteStartPercent + min(received*span/expected, span)
+			if percent >= reported+5 {
+				reported = percent
+				progress(models.JobStageRewriting, percent)
+			}
+			return nil
+		},
+		Retry: func([]string) error {
+			received = 0
+			return nil
+		},
+	}
+}
+
+// ResumeRewriteJob runs the analysis, then the rewrite, for a queued
+// models.JobKindResumeRewrite job. The rewrite is by far the longer step, so
+// its progress advances with the model's reply as it streams in.
+func ResumeRewriteJob(nlp *NLPService, llm *LLMService) JobHandler {
+	return func(ctx context.Context, job *models.BackgroundJob, data []byte, progress JobProgressFunc) (interface{}, error) {
+		var in ResumeRewriteInput
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, PermanentJobError(fmt.Errorf("invalid rewrite input: %w", err))
+		}
+
+		progress(models.JobStageAnalyzing, 5)
+
+		analysis, err := nlp.Analyze(ctx, bytes.NewReader(in.File), in.Filename, in.JobDescription)
+		if err != nil {
+			return nil, fmt.Errorf("NLP analysis failed: %w", err)
+		}
+		var parsed struct {
+			MatchedSkills []struct {
+				JobSkill   string  `json:"job_skill"`
+				Similarity float64 `json:"similarity"`
+			} `json:"matched_skills"`
+		}
+		if err := json.Unmarshal([]byte(analysis), &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse analysis: %w", err)
+		}
+		var missing []string
+		for _, m := range parsed.MatchedSkills {
+			if m.JobSkill != "" && m.Similarity < lowSimilarityThreshold {
+				missing = append(missing, m.JobSkill)
+			}
+		}
+
+		progress(models.JobStageRewriting, rewriteStartPercent)
+		rewrites, gen, err := llm.RewriteResume(ctx, job.UserID, in.ResumeText, in.JobDescription, missing,
+			rewriteProgress(len(in.ResumeText), progress))
+		if err != nil {
+			return nil, fmt.Errorf("resume rewrite failed: %w", err)
+		}
+		return ResumeRewriteResult{Rewrites: rewrites, Generation: gen}, nil
+	}
+}
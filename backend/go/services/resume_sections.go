@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ResumeSection is a run of lines under one heading. Key is the canonical
+// section name ("experience", "skills", ...) or "header" for the lines before
+// the first heading.
+type ResumeSection struct {
+	Key     string
+	Heading string
+	Lines   []string
+}
+
+// sectionHeadings maps normalized heading text to a canonical section key.
+var sectionHeadings = map[string]string{
+	"summary":                 "summary",
+	"professional summary":    "summary",
+	"profile":                 "summary",
+	"about me":                "summary",
+	"objective":               "summary",
+	"career objective":        "summary",
+	"experience":              "experience",
+	"work experience":         "experience",
+	"professional experience": "experience",
+	"employment":              "experience",
+	"employment history":      "experience",
+	"work history":            "experience",
+	"relevant experience":     "experience",
+	"education":               "education",
+	"academic background":     "education",
+	"skills":                  "skills",
+	"technical skills":        "skills",
+	"core competencies":       "skills",
+	"technologies":            "skills",
+	"projects":                "projects",
+	"personal projects":       "projects",
+	"selected projects":       "projects",
+	"certifications":          "certifications",
+	"certificates":            "certifications",
+	"licenses certifications": "certifications",
+	"awards":                  "awards",
+	"honors awards":           "awards",
+	"achievements":            "awards",
+	"publications":            "publications",
+	"volunteer":               "volunteer",
+	"volunteer experience":    "volunteer",
+	"volunteering":            "volunteer",
+	"languages":               "languages",
+	"interests":               "interests",
+	"references":              "references",
+}
+
+// SplitResumeSections splits plain resume text at recognised headings.
+func SplitResumeSections(text string) []ResumeSection {
+	sections := []ResumeSection{{Key: "header"}}
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if key, ok := sectionKey(line); ok {
+			sections = append(sections, ResumeSection{Key: key, Heading: line})
+			continue
+		}
+		last := &sections[len(sections)-1]
+		last.Lines = append(last.Lines, line)
+	}
+	if len(sections[0].Lines) == 0 {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+// sectionKey reports whether line is a section heading and which one. Known
+// headings match in any case; other short all-caps lines become ad-hoc sections.
+func sectionKey(line string) (string, bool) {
+	if len(line) > 40 {
+		return "", false
+	}
+	normalized := normalizeHeading(line)
+	if key, ok := sectionHeadings[normalized]; ok {
+		return key, true
+	}
+	if normalized == "" || len(strings.Fields(normalized)) > 4 {
+		return "", false
+	}
+	letters := 0
+	for _, r := range line {
+		if unicode.IsLower(r) {
+			return "", false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < 4 {
+		return "", false
+	}
+	return normalized, true
+}
+
+// normalizeHeading lowercases and drops punctuation such as "&" and trailing colons.
+func normalizeHeading(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/unidoc/unipdf/v3/common/license"
+	"github.com/unidoc/unipdf/v3/extractor"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+const docxMediaType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+var ErrUnsupportedFormat = errors.New("unsupported resume format")
+
+// ExtractResumeText returns the plain text of an uploaded resume in reading
+// order, one line of the original layout per line of output. PDF, DOCX and
+// plain text are supported; the content is sniffed when the declared type is
+// missing or generic.
+func ExtractResumeText(data []byte, contentType string) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(data, []byte("%PDF-")):
+		return extractPDFText(data)
+	case mediaType == docxMediaType || bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return extractDOCXText(data)
+	case strings.HasPrefix(mediaType, "text/") || isPlainText(data):
+		return decodePlainText(data), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+}
+
+// DetectResumeType identifies an upload from its content, ignoring the type
+// and extension the client declared. Only PDF, DOCX and plain text are
+// accepted; anything else, including legacy .doc files, is rejected.
+func DetectResumeType(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return "application/pdf", nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err == nil {
+			for _, f := range zr.File {
+				if f.Name == "word/document.xml" {
+					return docxMediaType, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("%w: zip file is not a Word document", ErrUnsupportedFormat)
+	case len(data) > 0 && isPlainText(data):
+		return "text/plain; charset=utf-8", nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, http.DetectContentType(data))
+}
+
+//
+// Plain text
+//
+
+func isPlainText(data []byte) bool {
+	if hasUTF16BOM(data) {
+		return true
+	}
+	sample := data
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	control := 0
+	for _, c := range sample {
+		if c == 0 {
+			return false
+		}
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' {
+			control++
+		}
+	}
+	return control*100 <= len(sample)
+}
+
+func hasUTF16BOM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF})
+}
+
+// decodePlainText handles UTF-8 (with or without BOM), UTF-16 with a BOM and,
+// failing those, Windows-1252/Latin-1, which is what most older editors write.
+func decodePlainText(data []byte) string {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text = string(data[3:])
+	case hasUTF16BOM(data):
+		bigEndian := data[0] == 0xFE
+		data = data[2:]
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			} else {
+				units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+			}
+		}
+		text = string(utf16.Decode(units))
+	case utf8.Valid(data):
+		text = string(data)
+	default:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+//
+// DOCX
+//
+
+// extractDOCXText reads word/document.xml: paragraphs and table rows become
+// lines, tabs and cell boundaries become tabs, and explicit breaks start a new line.
+func extractDOCXText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX: %w", err)
+	}
+
+	var doc *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			doc = f
+			break
+		}
+	}
+	if doc == nil {
+		return "", fmt.Errorf("%w: zip file is not a Word document", ErrUnsupportedFormat)
+	}
+
+	rc, err := doc.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read DOCX: %w", err)
+	}
+	defer rc.Close()
+
+	var out, line strings.Builder
+	flush := func() {
+		out.WriteString(strings.TrimRight(line.String(), " \t"))
+		out.WriteByte('\n')
+		line.Reset()
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxResumeBytes))
+	inText := false
+	inTabStops := false // <w:tabs> defines tab stops; its <w:tab> children are not tabs
+	cellDepth := 0      // paragraphs inside a table cell stay on the row's line
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DOCX: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tc":
+				cellDepth++
+			case "tabs":
+				inTabStops = true
+			case "tab":
+				if !inTabStops {
+					line.WriteByte('\t')
+				}
+			case "numPr":
+				// List paragraphs keep a marker so bullets survive extraction.
+				line.WriteString("• ")
+			case "br", "cr":
+				flush()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "tabs":
+				inTabStops = false
+			case "p":
+				if cellDepth > 0 {
+					line.WriteByte(' ')
+				} else {
+					flush()
+				}
+			case "tc":
+				cellDepth--
+				line.WriteString("\t")
+			case "tr":
+				flush()
+			}
+		case xml.CharData:
+			if inText {
+				line.Write(t)
+			}
+		}
+	}
+	if line.Len() > 0 {
+		flush()
+	}
+	return out.String(), nil
+}
+
+//
+// PDF
+//
+
+// SetPDFLicenseKey registers a UniDoc metered license key. unipdf refuses to
+// extract text until one is set, so PDF uploads fail without it.
+func SetPDFLicenseKey(key string) error {
+	return license.SetMeteredKey(key)
+}
+
+// extractPDFText reads each page in layout order.
+func extractPDFText(data []byte) (string, error) {
+	reader, pages, err := openPDF(data)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for i := 1; i <= pages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return "", fmt.Errorf("failed to read PDF page %d: %w", i, err)
+		}
+		glyphs, err := pdfPageGlyphs(page)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract text from PDF page %d: %w", i, err)
+		}
+		for _, line := range pdfPageLines(glyphs) {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String(), nil
+}
+
+// openPDF opens a PDF and returns its page count. Files encrypted with an
+// empty user password, which viewers open without asking, are decrypted.
+func openPDF(data []byte) (reader *model.PdfReader, pages int, err error) {
+	// unipdf can panic on badly malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF: %v", r)
+		}
+	}()
+
+	reader, err = model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	if encrypted, err := reader.IsEncrypted(); err != nil {
+		return nil, 0, fmt.Errorf("failed to open PDF: %w", err)
+	} else if encrypted {
+		if ok, err := reader.Decrypt(nil); err != nil || !ok {
+			return nil, 0, errors.New("PDF is password protected")
+		}
+	}
+	pages, err = reader.GetNumPages()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return reader, pages, nil
+}
+
+// pdfGlyph is a piece of text drawn on a PDF page, usually one character.
+// X and Y are its lower left corner and W its width, in points.
+type pdfGlyph struct {
+	S        string
+	X, Y, W  float64
+	FontSize float64
+}
+
+// pdfPageGlyphs returns the text drawn on a page. Spaces and line breaks
+// unipdf infers are dropped; pdfPageLines works them out from positions.
+func pdfPageGlyphs(page *model.PdfPage) (glyphs []pdfGlyph, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF: %v", r)
+		}
+	}()
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	text, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	for _, mark := range text.Marks().Elements() {
+		if mark.Meta || mark.Text == "" {
+			continue
+		}
+		glyphs = append(glyphs, pdfGlyph{
+			S:        mark.Text,
+			X:        mark.BBox.Llx,
+			Y:        mark.BBox.Lly,
+			W:        mark.BBox.Urx - mark.BBox.Llx,
+			FontSize: mark.FontSize,
+		})
+	}
+	return glyphs, nil
+}
+
+// pdfPageLines orders a page's glyphs for reading. Two-column layouts (a
+// sidebar next to the main body is common in resumes) are read one column at
+// a time instead of interleaving their lines; rows that span the gutter, such
+// as a full-width name header, break the page into blocks.
+func pdfPageLines(glyphs []pdfGlyph) []string {
+	rows := pdfRows(glyphs)
+	gutter, ok := findColumnGutter(rows)
+	if !ok {
+		lines := make([]string, 0, len(rows))
+		for _, row := range rows {
+			lines = appendRowText(lines, row)
+		}
+		return lines
+	}
+
+	var lines, left, right []string
+	flush := func() {
+		lines = append(append(lines, left...), right...)
+		left, right = nil, nil
+	}
+	for _, row := range rows {
+		var l, r []pdfGlyph
+		spans := false
+		for _, g := range row {
+			switch {
+			case g.X < gutter && g.X+g.W > gutter:
+				spans = true
+			case g.X+g.W/2 < gutter:
+				l = append(l, g)
+			default:
+				r = append(r, g)
+			}
+		}
+		if spans {
+			flush()
+			lines = appendRowText(lines, row)
+			continue
+		}
+		left = appendRowText(left, l)
+		right = appendRowText(right, r)
+	}
+	flush()
+	return lines
+}
+
+// pdfRows groups glyphs that share a baseline, top to bottom, each row sorted
+// left to right.
+func pdfRows(glyphs []pdfGlyph) [][]pdfGlyph {
+	sorted := make([]pdfGlyph, len(glyphs))
+	copy(sorted, glyphs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Y > sorted[j].Y }) // PDF y grows upwards
+
+	var rows [][]pdfGlyph
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[start].Y-sorted[end].Y <= baselineTolerance(sorted[start], sorted[end]) {
+			end++
+		}
+		row := sorted[start:end]
+		sort.SliceStable(row, func(i, j int) bool { return row[i].X < row[j].X })
+		rows = append(rows, row)
+		start = end
+	}
+	return rows
+}
+
+// findColumnGutter looks for a vertical band in the middle of the page that
+// almost no row crosses while many rows have text on both sides of it.
+func findColumnGutter(rows [][]pdfGlyph) (float64, bool) {
+	if len(rows) < 10 {
+		return 0, false
+	}
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, row := range rows {
+		for _, g := range row {
+			minX = math.Min(minX, g.X)
+			maxX = math.Max(maxX, g.X+g.W)
+		}
+	}
+	width := maxX - minX
+	if width < 200 {
+		return 0, false
+	}
+
+	// coverage[b] counts the rows with a glyph over bin b.
+	const binWidth = 2.0
+	coverage := make([]int, int(width/binWidth)+1)
+	for _, row := range rows {
+		last := -1
+		for _, g := range row {
+			for b := int((g.X - minX) / binWidth); b <= int((g.X+g.W-minX)/binWidth) && b < len(coverage); b++ {
+				if b > last {
+					coverage[b]++
+					last = b
+				}
+			}
+		}
+	}
+
+	// Widest nearly-empty run between 20% and 80% of the text width.
+	limit := len(rows) / 20
+	bestStart, bestLen := -1, 0
+	lo, hi := int(0.2*float64(len(coverage))), int(0.8*float64(len(coverage)))
+	for b := lo; b < hi; {
+		if coverage[b] > limit {
+			b++
+			continue
+		}
+		start := b
+		for b < hi && coverage[b] <= limit {
+			b++
+		}
+		if b-start > bestLen {
+			bestStart, bestLen = start, b-start
+		}
+	}
+	if bestStart < 0 || float64(bestLen)*binWidth < 12 {
+		return 0, false
+	}
+	gutter := minX + (float64(bestStart)+float64(bestLen)/2)*binWidth
+
+	// Require a real share of rows to have text on both sides.
+	both := 0
+	for _, row := range rows {
+		if row[0].X+row[0].W/2 < gutter && row[len(row)-1].X+row[len(row)-1].W/2 >= gutter {
+			both++
+		}
+	}
+	if float64(both) < 0.3*float64(len(rows)) {
+		return 0, false
+	}
+	return gutter, true
+}
+
+// appendRowText joins a row's glyphs, inserting a space where the horizontal
+// gap is wider than a fraction of the font size, and appends the non-empty result.
+func appendRowText(lines []string, row []pdfGlyph) []string {
+	var b strings.Builder
+	for i, g := range row {
+		if i > 0 && !strings.HasPrefix(g.S, " ") && g.X-(row[i-1].X+row[i-1].W) > 0.15*math.Max(g.FontSize, 1) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(g.S)
+	}
+	if line := strings.Join(strings.Fields(b.String()), " "); line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func baselineTolerance(a, b pdfGlyph) float64 {
+	return 0.3 * math.Max(math.Max(a.FontSize, b.FontSize), 1)
+}
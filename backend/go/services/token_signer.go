@@ -0,0 +1,64 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid or tampered token")
+
+// TokenSigner produces URL-safe tokens of the form payload.signature where the
+// payload is base64url-encoded JSON and the signature is an HMAC-SHA256 over it.
+type TokenSigner struct {
+	secret []byte
+}
+
+func NewTokenSigner(secret string) *TokenSigner {
+	return &TokenSigner{secret: []byte(secret)}
+}
+
+// Sign encodes the payload and appends its signature.
+func (s *TokenSigner) Sign(payload interface{}) (string, error) {
+	if len(s.secret) == 0 {
+		return "", errors.New("token signer has no secret configured")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(data)
+	return body + "." + s.signature(body), nil
+}
+
+// Verify checks the signature and decodes the payload into v.
+func (s *TokenSigner) Verify(token string, v interface{}) error {
+	if len(s.secret) == 0 {
+		return errors.New("token signer has no secret configured")
+	}
+
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.signature(body))) {
+		return ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *TokenSigner) signature(body string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
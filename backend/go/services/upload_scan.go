@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+	"trackify-jobs/models"
+)
+
+var (
+	ErrActiveContent = errors.New("PDF contains JavaScript or embedded files")
+	ErrScanPending   = errors.New("file has not finished malware scanning")
+	ErrQuarantined   = errors.New("file was quarantined by the malware scanner")
+)
+
+// scanTimeout bounds one background scan, including reading the file back
+// from storage for files uploaded before scanning existed.
+const scanTimeout = 2 * time.Minute
+
+// Limits on inflating PDF streams while looking for active content, so a
+// compression bomb cannot exhaust memory.
+const (
+	maxInflatedStream = 8 << 20
+	maxInflatedTotal  = 64 << 20
+)
+
+// activePDFNames are the dictionary names that carry scripts or attachments:
+// document and action JavaScript, and embedded file streams and name trees.
+var activePDFNames = map[string]bool{
+	"JavaScript":    true,
+	"JS":            true,
+	"EmbeddedFile":  true,
+	"EmbeddedFiles": true,
+}
+
+// checkPDFActiveContent rejects PDFs with JavaScript or embedded files. It
+// tokenizes the file and its compressed streams, object streams included,
+// looking at names only, so text such as "TypeScript/JavaScript" in the
+// resume itself does not count.
+func checkPDFActiveContent(data []byte) error {
+	budget := maxInflatedTotal
+	if name := findPDFName(data, &budget, true); name != "" {
+		return fmt.Errorf("%w: found /%s", ErrActiveContent, name)
+	}
+	return nil
+}
+
+// findPDFName returns the first active name in data. Streams are inflated and
+// searched when inflate is set; streams that are not Flate-encoded, such as
+// images, are skipped.
+func findPDFName(data []byte, budget *int, inflate bool) string {
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			i = skipPDFString(data, i)
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			i++
+		case c == '<':
+			for i < len(data) && data[i] != '>' {
+				i++
+			}
+		case c == '/':
+			start := i + 1
+			i = start
+			for i < len(data) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+			if name := decodePDFName(data[start:i]); activePDFNames[name] {
+				return name
+			}
+			i--
+		case c == 's' && inflate && bytes.HasPrefix(data[i:], []byte("stream")) && (i == 0 || isPDFDelimiter(data[i-1])):
+			body := i + len("stream")
+			if body < len(data) && data[body] == '\r' {
+				body++
+			}
+			if body >= len(data) || data[body] != '\n' {
+				continue
+			}
+			body++
+			end := bytes.Index(data[body:], []byte("endstream"))
+			if end < 0 {
+				return ""
+			}
+			if name := findPDFName(inflatePDFStream(data[body:body+end], budget), budget, false); name != "" {
+				return name
+			}
+			i = body + end + len("endstream") - 1
+		}
+	}
+	return ""
+}
+
+// skipPDFString returns the index of the parenthesis closing the literal
+// string that opens at i, honouring nesting and backslash escapes.
+func skipPDFString(data []byte, i int) int {
+	depth := 0
+	for ; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// decodePDFName expands #xx escapes, which can spell /JavaScript as
+// /J#61vaScript.
+func decodePDFName(raw []byte) string {
+	if bytes.IndexByte(raw, '#') < 0 {
+		return string(raw)
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(string(raw[i+1:i+3]), 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(raw[i])
+	}
+	return b.String()
+}
+
+// inflatePDFStream returns as much of a Flate stream as decodes within the
+// budget, or nil for streams in other encodings.
+func inflatePDFStream(body []byte, budget *int) []byte {
+	if *budget <= 0 {
+		return nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	out, _ := io.ReadAll(io.LimitReader(zr, int64(min(*budget, maxInflatedStream))))
+	*budget -= len(out)
+	return out
+}
+
+// startScan scans a stored file in the background and records the verdict.
+// content may be nil, in which case the file is read back from storage. A
+// file already being scanned is not scanned twice.
+func (s *DocumentService) startScan(key string, content []byte) {
+	if _, busy := s.scanning.LoadOrStore(key, true); busy {
+		return
+	}
+	go func() {
+		defer s.scanning.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+		defer cancel()
+		if err := s.scanFile(ctx, key, content); err != nil {
+			log.Printf("Scan of %s failed: %v", key, err)
+			if err := s.DB.SetResumeBlobScan(key, models.ScanFailed, err.Error()); err != nil {
+				log.Printf("Failed to record scan failure for %s: %v", key, err)
+			}
+		}
+	}()
+}
+
+func (s *DocumentService) scanFile(ctx context.Context, key string, content []byte) error {
+	var r io.Reader = bytes.NewReader(content)
+	if content == nil {
+		file, err := s.Blobs.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	result, err := s.Scanner.Scan(ctx, r)
+	if err != nil {
+		return err
+	}
+	if !result.Clean {
+		log.Printf("Quarantined %s: %s", key, result.Threat)
+		return s.DB.SetResumeBlobScan(key, models.ScanQuarantined, result.Threat)
+	}
+	return s.DB.SetResumeBlobScan(key, models.ScanClean, "")
+}
+
+// OpenResumeForDownload is OpenResume for serving a file back to a user. Only
+// files that passed scanning are returned.
+func (s *DocumentService) OpenResumeForDownload(ctx context.Context, resume *models.Resume) (io.ReadCloser, error) {
+	if err := s.downloadable(resume); err != nil {
+		return nil, err
+	}
+	return s.OpenResume(ctx, resume)
+}
+
+// downloadable reports whether a file may be served. A pending file, or one
+// whose scan failed, is queued for scanning and reported as ErrScanPending.
+func (s *DocumentService) downloadable(resume *models.Resume) error {
+	switch resume.ScanStatus {
+	case models.ScanClean:
+		return nil
+	case models.ScanQuarantined:
+		return ErrQuarantined
+	default:
+		s.startScan(resume.StorageKey, nil)
+		return ErrScanPending
+	}
+}
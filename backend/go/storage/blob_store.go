@@ -0,0 +1,103 @@
+// Package storage keeps uploaded documents behind a BlobStore so the service
+// does not care whether files live on local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque objects under slash-separated keys.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStoreFromEnv picks the backend from STORAGE_BACKEND ("local" or "s3").
+// The local backend stores under localRoot.
+func NewBlobStoreFromEnv(localRoot string) (BlobStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		return NewLocalStore(localRoot)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:       os.Getenv("S3_ENDPOINT"),
+			Region:         os.Getenv("S3_REGION"),
+			Bucket:         os.Getenv("S3_BUCKET"),
+			AccessKeyID:    os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey:      os.Getenv("S3_SECRET_ACCESS_KEY"),
+			ForcePathStyle: os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// NewKey returns a fresh key for an object owned by userID, e.g.
+// "resumes/<uid>/<uuid>". Client-supplied names never become part of a key.
+func NewKey(kind, userID string) string {
+	return path.Join(kind, keySegment(userID), uuid.NewString())
+}
+
+// keySegment makes an identifier safe to use as a single key segment.
+func keySegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, s)
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// validKey rejects keys that could escape the store root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// SafeFilename reduces a client-supplied filename to something fit for
+// metadata and Content-Disposition: no directories, control characters or quotes.
+func SafeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > 200 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = string(runes[:200-len([]rune(ext))]) + ext
+	}
+	return name
+}
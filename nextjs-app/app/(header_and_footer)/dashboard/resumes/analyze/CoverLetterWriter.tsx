@@ -1,6 +1,6 @@
-import { readFile } from '@/lib/parsing/main';
 import { getAuth } from 'firebase/auth';
 import { useState } from 'react';
+import { getResumeId } from './uploadResume';
 import {
   Document,
   Page,
@@ -36,9 +36,7 @@ export default function CoverLetterWriter({
       const user = auth.currentUser;
       if (!user) throw new Error('User not authenticated');
       const token = await user.getIdToken();
-
-      const arrayBuffer = await file.arrayBuffer();
-      const resumeText = await readFile(arrayBuffer);
+      const resumeId = await getResumeId(file, token);
 
       const res = await fetch('/api/llm/cover-letter', {
         method: 'POST',
@@ -47,7 +45,7 @@ export default function CoverLetterWriter({
           Authorization: `Bearer ${token}`,
         },
         body: JSON.stringify({
-          resume_text: resumeText,
+          resume_id: resumeId,
           job_description: jobDescription,
         }),
       });
@@ -1,7 +1,7 @@
-import { readFile } from '@/lib/parsing/main';
 import { getAuth } from 'firebase/auth';
 import { compressToEncodedURIComponent } from 'lz-string';
 import { useState } from 'react';
+import { getResumeId } from './uploadResume';
 
 export default function ResumeRewriter({
   file,
@@ -23,24 +23,23 @@ export default function ResumeRewriter({
     setError(null);
 
     try {
-      const arrayBuffer = await file.arrayBuffer();
-      const resumeText = await readFile(arrayBuffer);
-
       const auth = getAuth();
       const user = auth.currentUser;
       if (!user) throw new Error('User not authenticated');
       const token = await user.getIdToken();
+      const resumeId = await getResumeId(file, token);
 
       // Step 1: Submit the job
-      const formData = new FormData();
-      formData.append('resume_file', file);
-      formData.append('resume_text', resumeText);
-      formData.append('job_description', jobDescription);
-
       const submitRes = await fetch('/api/llm/rewrite', {
         method: 'POST',
-        headers: { Authorization: `Bearer ${token}` },
-        body: formData,
+        headers: {
+          'Content-Type': 'application/json',
+          Authorization: `Bearer ${token}`,
+        },
+        body: JSON.stringify({
+          resume_id: resumeId,
+          job_description: jobDescription,
+        }),
       });
 
       if (!submitRes.ok) throw new Error(await submitRes.text());
@@ -80,6 +79,10 @@ export default function ResumeRewriter({
           throw new Error(statusData.error || 'Resume rewrite failed');
         }
 
+        if (statusData.status === 'cancelled') {
+          throw new Error('Resume rewrite was cancelled');
+        }
+
         await new Promise((res) => setTimeout(res, pollInterval));
         attempts++;
       }
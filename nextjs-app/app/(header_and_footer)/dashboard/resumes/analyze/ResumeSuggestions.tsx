@@ -1,4 +1,3 @@
-import { readFile } from '@/lib/parsing/main';
 import { getAuth } from 'firebase/auth';
 import {
   useState,
@@ -6,6 +5,7 @@ import {
   forwardRef,
   ForwardRefRenderFunction,
 } from 'react';
+import { getResumeId } from './uploadResume';
 
 /* Shared UI bits for consistency */
 const Placeholder = ({
@@ -131,14 +131,12 @@ const ResumeSuggestions: ForwardRefRenderFunction<
     setHasRun(true);
 
     try {
-      const arrayBuffer = await file.arrayBuffer();
-      const resumeText = await readFile(arrayBuffer);
-
       const auth = getAuth();
       const user = auth.currentUser;
       if (!user) throw new Error('User not authenticated');
 
       const idToken = await user.getIdToken();
+      const resumeId = await getResumeId(file, idToken);
       const res = await fetch('/api/llm/recommendations', {
         method: 'POST',
         headers: {
@@ -146,7 +144,7 @@ const ResumeSuggestions: ForwardRefRenderFunction<
           Authorization: `Bearer ${idToken}`,
         },
         body: JSON.stringify({
-          resume_text: resumeText,
+          resume_id: resumeId,
           job_description: jobDescription,
         }),
       });
@@ -222,8 +222,8 @@ function SelectResume({
       </p>
 
       <Banner className="mt-4 ">
-        Files are securely sent to our server for processing and are not stored
-        after analysis.
+        Resumes you analyze are saved to your account, so they can be reused
+        without uploading them again.
       </Banner>
 
       <SmartButton
@@ -0,0 +1,31 @@
+// LLM endpoints take the id of a stored resume rather than the file itself.
+// Each File is uploaded once, however many features ask for it; an identical
+// file that was saved before comes back as the existing version.
+const uploads = new WeakMap<File, Promise<string>>();
+
+export function getResumeId(file: File, token: string): Promise<string> {
+  let upload = uploads.get(file);
+  if (!upload) {
+    upload = uploadResume(file, token);
+    uploads.set(file, upload);
+    // Let a failed upload be retried.
+    upload.catch(() => uploads.delete(file));
+  }
+  return upload;
+}
+
+async function uploadResume(file: File, token: string): Promise<string> {
+  const formData = new FormData();
+  formData.append('resume', file);
+
+  const res = await fetch('/api/resumes', {
+    method: 'POST',
+    headers: { Authorization: `Bearer ${token}` },
+    body: formData,
+  });
+  if (!res.ok) throw new Error(await res.text());
+
+  const resume = await res.json();
+  if (!resume.id) throw new Error('No resume id returned');
+  return resume.id;
+}